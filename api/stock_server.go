@@ -1,11 +1,16 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
+	"nofx/notifier"
+	"nofx/stock"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -14,9 +19,14 @@ import (
 
 // StockAPIServer 股票分析API服务器
 type StockAPIServer struct {
-	router  *gin.Engine
-	manager AnalyzerManagerInterface
-	port    int
+	router      *gin.Engine
+	manager     AnalyzerManagerInterface
+	port        int
+	resultStore stock.ResultStore
+	jobManager  *stock.AnalysisJobManager
+	tracker     *stock.SignalTracker
+	queues      []*notifier.DeliveryQueue
+	usage       *stock.UsageTracker
 }
 
 // AnalyzerManagerInterface 分析器管理器接口
@@ -50,6 +60,31 @@ func NewStockAPIServer(manager AnalyzerManagerInterface, port int) *StockAPIServ
 	return server
 }
 
+// SetResultStore 设置分析结果存储（用于最新结果和历史记录查询）
+func (s *StockAPIServer) SetResultStore(store stock.ResultStore) {
+	s.resultStore = store
+}
+
+// SetJobManager 设置手动分析任务管理器
+func (s *StockAPIServer) SetJobManager(jobManager *stock.AnalysisJobManager) {
+	s.jobManager = jobManager
+}
+
+// SetSignalTracker 设置信号结果跟踪器
+func (s *StockAPIServer) SetSignalTracker(tracker *stock.SignalTracker) {
+	s.tracker = tracker
+}
+
+// SetDeliveryQueues 设置通知投递队列（用于查询发件箱和死信）
+func (s *StockAPIServer) SetDeliveryQueues(queues []*notifier.DeliveryQueue) {
+	s.queues = queues
+}
+
+// SetUsageTracker 设置AI用量统计
+func (s *StockAPIServer) SetUsageTracker(usage *stock.UsageTracker) {
+	s.usage = usage
+}
+
 // setupRoutes 设置路由
 func (s *StockAPIServer) setupRoutes() {
 	// 健康检查
@@ -79,6 +114,21 @@ func (s *StockAPIServer) setupRoutes() {
 		// 手动触发分析
 		api.POST("/stock/:code/analyze", s.handleTriggerAnalysis)
 
+		// 查询手动分析任务状态
+		api.GET("/jobs/:id", s.handleGetJob)
+
+		// 取消进行中的分析
+		api.POST("/stock/:code/cancel", s.handleCancelAnalysis)
+
+		// 信号结果跟踪（目标价/止损价/超时）
+		api.GET("/signals/outcomes", s.handleGetSignalOutcomes)
+		api.GET("/signals/summary", s.handleGetSignalSummary)
+
+		// 通知投递队列（待投递和死信）
+		api.GET("/notifications/queue", s.handleGetNotificationQueue)
+		api.GET("/notifications/dead-letters", s.handleGetDeadLetters)
+		api.POST("/notifications/dead-letters/:id/retry", s.handleRetryDeadLetter)
+
 		// 获取系统统计信息
 		api.GET("/statistics", s.handleGetStatistics)
 	}
@@ -129,22 +179,46 @@ func (s *StockAPIServer) handleGetLatestAnalysis(c *gin.Context) {
 		return
 	}
 
-	// TODO: 从analyzer获取最新分析结果
+	if s.resultStore == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"code":    -1,
+			"message": "未启用分析结果存储",
+		})
+		return
+	}
+
+	result, err := s.resultStore.Latest(code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    -1,
+			"message": fmt.Sprintf("读取分析结果失败: %v", err),
+		})
+		return
+	}
+	if result == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    -1,
+			"message": "该股票暂无分析结果",
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"code":    0,
 		"message": "success",
-		"data": gin.H{
-			"stock_code": code,
-			"timestamp":  time.Now(),
-			// 更多数据...
-		},
+		"data":    result,
 	})
 }
 
 // handleGetAnalysisHistory 获取历史分析记录
+// 查询参数:
+//   - limit: 每页条数，默认20，最大500
+//   - page: 页码（从1开始），与offset二选一
+//   - offset: 跳过条数
+//   - start_date/end_date: 日期范围（YYYY-MM-DD或YYYYMMDD，含首尾两天）
+//   - signal: 信号过滤，多个用逗号分隔（如 BUY,SELL）
 func (s *StockAPIServer) handleGetAnalysisHistory(c *gin.Context) {
 	code := c.Param("code")
-	limit := 20 // 默认返回最近20条
 
 	analyzer := s.manager.GetAnalyzer(code)
 	if analyzer == nil {
@@ -155,20 +229,124 @@ func (s *StockAPIServer) handleGetAnalysisHistory(c *gin.Context) {
 		return
 	}
 
-	// TODO: 从日志文件读取历史记录
+	if s.resultStore == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"code":    -1,
+			"message": "未启用分析结果存储",
+		})
+		return
+	}
+
+	query, err := parseHistoryQuery(c, code)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	records, total, err := s.resultStore.Query(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    -1,
+			"message": fmt.Sprintf("读取历史记录失败: %v", err),
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"code":    0,
 		"message": "success",
 		"data": gin.H{
 			"stock_code": code,
-			"count":      0,
-			"limit":      limit,
-			"records":    []gin.H{},
+			"total":      total,
+			"count":      len(records),
+			"offset":     query.Offset,
+			"limit":      query.Limit,
+			"records":    records,
 		},
 	})
 }
 
+// parseHistoryQuery 解析历史记录查询参数
+func parseHistoryQuery(c *gin.Context, code string) (stock.ResultQuery, error) {
+	query := stock.ResultQuery{
+		StockCode: code,
+		Limit:     20, // 默认返回最近20条
+	}
+
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return query, fmt.Errorf("limit参数无效: %s", v)
+		}
+		if limit > 500 {
+			limit = 500
+		}
+		query.Limit = limit
+	}
+
+	if v := c.Query("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return query, fmt.Errorf("offset参数无效: %s", v)
+		}
+		query.Offset = offset
+	} else if v := c.Query("page"); v != "" {
+		page, err := strconv.Atoi(v)
+		if err != nil || page <= 0 {
+			return query, fmt.Errorf("page参数无效: %s", v)
+		}
+		query.Offset = (page - 1) * query.Limit
+	}
+
+	if v := c.Query("start_date"); v != "" {
+		start, err := parseQueryDate(v)
+		if err != nil {
+			return query, fmt.Errorf("start_date参数无效: %s", v)
+		}
+		query.Start = start
+	}
+
+	if v := c.Query("end_date"); v != "" {
+		end, err := parseQueryDate(v)
+		if err != nil {
+			return query, fmt.Errorf("end_date参数无效: %s", v)
+		}
+		// 包含结束日期当天
+		query.End = end.AddDate(0, 0, 1)
+	}
+
+	if v := c.Query("signal"); v != "" {
+		for _, signal := range strings.Split(v, ",") {
+			signal = strings.ToUpper(strings.TrimSpace(signal))
+			if signal == "" {
+				continue
+			}
+			if signal != "BUY" && signal != "SELL" && signal != "HOLD" {
+				return query, fmt.Errorf("signal参数无效: %s (必须是BUY/SELL/HOLD)", signal)
+			}
+			query.Signals = append(query.Signals, signal)
+		}
+	}
+
+	return query, nil
+}
+
+// parseQueryDate 解析日期参数（支持YYYY-MM-DD和YYYYMMDD），使用本地时区
+func parseQueryDate(value string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02", "20060102"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("日期格式无效: %s", value)
+}
+
 // handleTriggerAnalysis 手动触发分析
+// 默认立即返回任务ID，可通过 GET /api/jobs/:id 查询结果；
+// 携带 ?wait=true 时同步等待分析完成（可用 timeout=秒 限制等待时间，默认300秒）
 func (s *StockAPIServer) handleTriggerAnalysis(c *gin.Context) {
 	code := c.Param("code")
 
@@ -181,32 +359,300 @@ func (s *StockAPIServer) handleTriggerAnalysis(c *gin.Context) {
 		return
 	}
 
-	// TODO: 触发立即分析
+	stockAnalyzer, ok := analyzer.(*stock.StockAnalyzer)
+	if !ok || s.jobManager == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{
+			"code":    -1,
+			"message": "不支持手动触发分析",
+		})
+		return
+	}
+
+	job := s.jobManager.Submit(stockAnalyzer)
+
+	if c.Query("wait") != "true" {
+		c.JSON(http.StatusAccepted, gin.H{
+			"code":    0,
+			"message": "分析任务已提交",
+			"data":    job,
+		})
+		return
+	}
+
+	timeout := 300 * time.Second
+	if v := c.Query("timeout"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    -1,
+				"message": fmt.Sprintf("timeout参数无效: %s", v),
+			})
+			return
+		}
+		timeout = time.Duration(seconds) * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	defer cancel()
+
+	job, err := s.jobManager.Wait(ctx, job.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	s.respondJob(c, job)
+}
+
+// handleGetJob 查询手动分析任务状态
+func (s *StockAPIServer) handleGetJob(c *gin.Context) {
+	if s.jobManager == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{
+			"code":    -1,
+			"message": "不支持手动触发分析",
+		})
+		return
+	}
+
+	job, ok := s.jobManager.Get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    -1,
+			"message": "任务不存在或已过期",
+		})
+		return
+	}
+
+	s.respondJob(c, job)
+}
+
+// respondJob 根据任务状态返回响应
+func (s *StockAPIServer) respondJob(c *gin.Context, job stock.AnalysisJob) {
+	switch job.Status {
+	case stock.JobStatusSucceeded:
+		c.JSON(http.StatusOK, gin.H{
+			"code":    0,
+			"message": "success",
+			"data":    job,
+		})
+	case stock.JobStatusFailed:
+		c.JSON(http.StatusOK, gin.H{
+			"code":    -1,
+			"message": fmt.Sprintf("分析失败: %s", job.Error),
+			"data":    job,
+		})
+	default:
+		c.JSON(http.StatusAccepted, gin.H{
+			"code":    0,
+			"message": "分析任务执行中",
+			"data":    job,
+		})
+	}
+}
+
+// handleCancelAnalysis 取消进行中的分析
+func (s *StockAPIServer) handleCancelAnalysis(c *gin.Context) {
+	code := c.Param("code")
+
+	analyzer := s.manager.GetAnalyzer(code)
+	if analyzer == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    -1,
+			"message": "未找到该股票的分析器",
+		})
+		return
+	}
+
+	canceller, ok := analyzer.(interface{ CancelAnalysis() bool })
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{
+			"code":    -1,
+			"message": "该分析器不支持取消",
+		})
+		return
+	}
+
+	cancelled := canceller.CancelAnalysis()
+	message := "已取消进行中的分析"
+	if !cancelled {
+		message = "当前没有进行中的分析"
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"code":    0,
-		"message": "分析任务已提交",
+		"message": message,
 		"data": gin.H{
 			"stock_code": code,
-			"triggered":  true,
+			"cancelled":  cancelled,
 		},
 	})
 }
 
-// handleGetStatistics 获取系统统计
-func (s *StockAPIServer) handleGetStatistics(c *gin.Context) {
-	analyzers := s.manager.GetAllAnalyzers()
+// handleGetSignalOutcomes 查询信号跟踪记录
+// 支持 code=股票代码、status=open|target|stop|timeout 过滤，按信号时间倒序
+func (s *StockAPIServer) handleGetSignalOutcomes(c *gin.Context) {
+	if s.tracker == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"code":    -1,
+			"message": "未启用信号跟踪",
+		})
+		return
+	}
 
+	status := stock.OutcomeStatus(c.Query("status"))
+	switch status {
+	case "", stock.OutcomeOpen, stock.OutcomeTarget, stock.OutcomeStop, stock.OutcomeTimeout, stock.OutcomeDataGap:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": fmt.Sprintf("无效的status: %s", status),
+		})
+		return
+	}
+
+	outcomes := s.tracker.Outcomes(c.Query("code"), status)
 	c.JSON(http.StatusOK, gin.H{
 		"code":    0,
 		"message": "success",
 		"data": gin.H{
-			"total_stocks":   len(analyzers),
-			"system_uptime":  "", // TODO: 计算运行时间
-			"total_analysis": 0,  // TODO: 统计总分析次数
+			"count":    len(outcomes),
+			"outcomes": outcomes,
 		},
 	})
 }
 
+// handleGetSignalSummary 按股票统计信号结果（目标价命中率、平均收益、平均最大不利偏移等）
+func (s *StockAPIServer) handleGetSignalSummary(c *gin.Context) {
+	if s.tracker == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"code":    -1,
+			"message": "未启用信号跟踪",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    s.tracker.Summary(c.Query("code")),
+	})
+}
+
+// handleGetNotificationQueue 查询各渠道待投递的通知
+func (s *StockAPIServer) handleGetNotificationQueue(c *gin.Context) {
+	if len(s.queues) == 0 {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"code":    -1,
+			"message": "未启用通知投递队列",
+		})
+		return
+	}
+
+	channels := make([]gin.H, 0, len(s.queues))
+	for _, queue := range s.queues {
+		pending := queue.Pending()
+		channels = append(channels, gin.H{
+			"channel":      queue.Name,
+			"rate_limit":   queue.Config.RateLimit,
+			"pending":      pending,
+			"dead_letters": len(queue.DeadLetters()),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    channels,
+	})
+}
+
+// handleGetDeadLetters 查询投递失败的通知，支持 channel=渠道名 过滤
+func (s *StockAPIServer) handleGetDeadLetters(c *gin.Context) {
+	if len(s.queues) == 0 {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"code":    -1,
+			"message": "未启用通知投递队列",
+		})
+		return
+	}
+
+	channel := c.Query("channel")
+	var queues []*notifier.DeliveryQueue
+	for _, queue := range s.queues {
+		if channel == "" || queue.Name == channel {
+			queues = append(queues, queue)
+		}
+	}
+	deadLetters := notifier.MergeDeadLetters(queues...)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data": gin.H{
+			"count":        len(deadLetters),
+			"dead_letters": deadLetters,
+		},
+	})
+}
+
+// handleRetryDeadLetter 将死信重新加入投递队列
+func (s *StockAPIServer) handleRetryDeadLetter(c *gin.Context) {
+	id := c.Param("id")
+	for _, queue := range s.queues {
+		if err := queue.Retry(id); err == nil {
+			c.JSON(http.StatusOK, gin.H{
+				"code":    0,
+				"message": "已重新加入投递队列",
+				"data": gin.H{
+					"id":      id,
+					"channel": queue.Name,
+				},
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusNotFound, gin.H{
+		"code":    -1,
+		"message": fmt.Sprintf("死信不存在: %s", id),
+	})
+}
+
+// handleGetStatistics 获取系统统计，支持 days=N 指定AI用量的统计天数（默认7天）
+func (s *StockAPIServer) handleGetStatistics(c *gin.Context) {
+	analyzers := s.manager.GetAllAnalyzers()
+
+	data := gin.H{
+		"total_stocks":   len(analyzers),
+		"system_uptime":  "", // TODO: 计算运行时间
+		"total_analysis": 0,  // TODO: 统计总分析次数
+	}
+	if s.usage != nil {
+		days := 7
+		if v := c.Query("days"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"code":    -1,
+					"message": "days必须是正整数",
+				})
+				return
+			}
+			days = n
+		}
+		data["ai_usage"] = s.usage.Summary(days)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    data,
+	})
+}
+
 // handleGetConfig 获取配置
 func (s *StockAPIServer) handleGetConfig(c *gin.Context) {
 	// 读取配置文件
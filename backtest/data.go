@@ -0,0 +1,71 @@
+package backtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"nofx/stock"
+	"os"
+	"sort"
+)
+
+// LoadKlineFile 从文件加载日K线
+// 支持三种格式：TDX接口完整响应（如test_kline.json）、KlineData对象、KlineItem数组
+func LoadKlineFile(path string) ([]stock.KlineItem, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取K线文件失败: %w", err)
+	}
+	// 去掉Windows下保存文件可能带有的UTF-8 BOM
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	data = bytes.TrimSpace(data)
+
+	var list []stock.KlineItem
+	if len(data) > 0 && data[0] == '[' {
+		if err := json.Unmarshal(data, &list); err != nil {
+			return nil, fmt.Errorf("解析K线文件失败: %w", err)
+		}
+		return sortKlines(list), nil
+	}
+
+	var apiResp stock.APIResponse
+	if err := json.Unmarshal(data, &apiResp); err == nil && len(apiResp.Data) > 0 {
+		if apiResp.Code != 0 {
+			return nil, fmt.Errorf("K线文件中的API错误: %s", apiResp.Message)
+		}
+		data = apiResp.Data
+	}
+
+	var klineData stock.KlineData
+	if err := json.Unmarshal(data, &klineData); err != nil {
+		return nil, fmt.Errorf("解析K线文件失败: %w", err)
+	}
+	return sortKlines(klineData.List), nil
+}
+
+// FetchKlines 从TDX接口获取日K线
+// 指定了startDate或endDate（YYYYMMDD）时使用/api/kline-history，否则使用/api/kline获取全部日线
+func FetchKlines(ctx context.Context, client *stock.TDXClient, code string, startDate string, endDate string) ([]stock.KlineItem, error) {
+	var (
+		klineData *stock.KlineData
+		err       error
+	)
+	if startDate != "" || endDate != "" {
+		klineData, err = client.GetKlineHistoryContext(ctx, code, "day", startDate, endDate, 800)
+	} else {
+		klineData, err = client.GetKlineContext(ctx, code, "day", 0)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取K线失败: %w", err)
+	}
+	return sortKlines(klineData.List), nil
+}
+
+// sortKlines 按时间升序排列K线
+func sortKlines(list []stock.KlineItem) []stock.KlineItem {
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Time.Before(list[j].Time)
+	})
+	return list
+}
@@ -0,0 +1,123 @@
+package backtest
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"nofx/mcp"
+	"nofx/stock"
+	"nofx/stock/indicators"
+)
+
+// DecisionSource 决策来源
+// 回测引擎在每根K线收盘后调用Decide，input只包含截至该K线的数据
+type DecisionSource interface {
+	Decide(ctx context.Context, input *stock.AnalysisInput) (*stock.AIDecisionResponse, error)
+}
+
+// DecisionFunc 函数形式的决策来源
+type DecisionFunc func(ctx context.Context, input *stock.AnalysisInput) (*stock.AIDecisionResponse, error)
+
+// Decide 调用函数本身
+func (f DecisionFunc) Decide(ctx context.Context, input *stock.AnalysisInput) (*stock.AIDecisionResponse, error) {
+	return f(ctx, input)
+}
+
+// AIDecisionSource 使用与实时分析相同的提示词和AI模型做决策
+type AIDecisionSource struct {
+	Analyzer  *stock.StockAnalyzer // 提供提示词模板和股票信息
+	MCPClient mcp.AIClient
+}
+
+// NewAIDecisionSource 创建AI决策来源
+func NewAIDecisionSource(analyzer *stock.StockAnalyzer, mcpClient mcp.AIClient) *AIDecisionSource {
+	return &AIDecisionSource{
+		Analyzer:  analyzer,
+		MCPClient: mcpClient,
+	}
+}
+
+// Decide 构建提示词并调用AI，响应无法解析时按HOLD处理
+func (s *AIDecisionSource) Decide(ctx context.Context, input *stock.AnalysisInput) (*stock.AIDecisionResponse, error) {
+	systemPrompt, userPrompt, err := s.Analyzer.BuildPrompts(input)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.MCPClient.ChatContext(ctx, systemPrompt, userPrompt)
+	if err != nil {
+		return nil, fmt.Errorf("AI分析失败: %w", err)
+	}
+
+	decision, err := stock.ParseAIResponse(response.Content)
+	if err != nil {
+		log.Printf("⚠️  %s AI响应解析失败，按HOLD处理: %v", input.Time.Format("2006-01-02"), err)
+		return &stock.AIDecisionResponse{Signal: "HOLD", Confidence: 0}, nil
+	}
+	return decision, nil
+}
+
+// MACDDecisionSource 基于MACD金叉死叉的规则决策（无需AI，可作为基准策略）
+// 金叉时BUY，目标价和止损价按ATR倍数设置；死叉时SELL
+type MACDDecisionSource struct {
+	TargetATR float64 // 目标价 = 收盘价 + TargetATR * ATR(14)
+	StopATR   float64 // 止损价 = 收盘价 - StopATR * ATR(14)
+}
+
+// NewMACDDecisionSource 创建MACD规则决策来源，默认目标3倍ATR、止损1.5倍ATR
+func NewMACDDecisionSource() *MACDDecisionSource {
+	return &MACDDecisionSource{
+		TargetATR: 3,
+		StopATR:   1.5,
+	}
+}
+
+// Decide 根据最近两根K线的MACD判断金叉死叉
+func (s *MACDDecisionSource) Decide(ctx context.Context, input *stock.AnalysisInput) (*stock.AIDecisionResponse, error) {
+	bars := stock.KlineToBars(input.DayKline.List)
+	if len(bars) < 35 {
+		return &stock.AIDecisionResponse{Signal: "HOLD", Reasoning: "K线数量不足"}, nil
+	}
+
+	macd := indicators.MACD(indicators.Closes(bars), 12, 26, 9)
+	n := len(bars)
+	prevDiff := macd.DIF[n-2] - macd.DEA[n-2]
+	currDiff := macd.DIF[n-1] - macd.DEA[n-1]
+
+	closePrice := bars[n-1].Close
+	atr, ok := indicators.Last(indicators.ATR(bars, 14))
+	if !ok || atr <= 0 {
+		atr = closePrice * 0.02
+	}
+
+	switch {
+	case prevDiff <= 0 && currDiff > 0:
+		// 零轴上方金叉信心更高
+		confidence := 65
+		if macd.DIF[n-1] > 0 {
+			confidence = 80
+		}
+		return &stock.AIDecisionResponse{
+			Signal:      "BUY",
+			Confidence:  confidence,
+			Reasoning:   "MACD金叉",
+			TargetPrice: roundPrice(closePrice + s.TargetATR*atr),
+			StopLoss:    roundPrice(closePrice - s.StopATR*atr),
+			RiskReward:  fmt.Sprintf("1:%.1f", s.TargetATR/s.StopATR),
+		}, nil
+	case prevDiff >= 0 && currDiff < 0:
+		return &stock.AIDecisionResponse{
+			Signal:     "SELL",
+			Confidence: 70,
+			Reasoning:  "MACD死叉",
+		}, nil
+	default:
+		return &stock.AIDecisionResponse{Signal: "HOLD", Confidence: 50, Reasoning: "无交叉信号"}, nil
+	}
+}
+
+// roundPrice 价格保留两位小数
+func roundPrice(price float64) float64 {
+	return math.Round(price*100) / 100
+}
@@ -0,0 +1,327 @@
+package backtest
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"nofx/stock"
+	"nofx/stock/market"
+	"time"
+)
+
+// Config 回测配置
+type Config struct {
+	StockCode      string
+	StockName      string
+	InitialCapital float64 // 初始资金（元），默认100000
+	PositionRatio  float64 // 每次开仓使用的资金比例(0,1]，默认1
+	Lookback       int     // 每次决策可见的日K线数量，默认60（与实时分析一致）
+	Warmup         int     // 开始决策前至少需要的K线数量，默认30
+	MinConfidence  int     // 低于该信心度的BUY信号不开仓
+	MaxHoldingBars int     // 最长持仓K线数，超过后按收盘价平仓，0表示不限制
+	DecisionEvery  int     // 每隔N根K线决策一次（用AI回测时可减少调用次数），默认1
+
+	// Rules A股交易规则（T+1、整手、涨跌停、交易费用），默认market.DefaultRules()
+	Rules *market.Rules
+}
+
+// applyDefaults 填充默认值
+func (c *Config) applyDefaults() {
+	if c.InitialCapital <= 0 {
+		c.InitialCapital = 100000
+	}
+	if c.PositionRatio <= 0 || c.PositionRatio > 1 {
+		c.PositionRatio = 1
+	}
+	if c.Lookback <= 0 {
+		c.Lookback = 60
+	}
+	if c.Warmup <= 0 {
+		c.Warmup = 30
+	}
+	if c.DecisionEvery <= 0 {
+		c.DecisionEvery = 1
+	}
+	if c.Rules == nil {
+		c.Rules = market.DefaultRules()
+	}
+}
+
+// 平仓原因
+const (
+	ExitTarget  = "target"  // 触及目标价
+	ExitStop    = "stop"    // 触及止损价
+	ExitSignal  = "signal"  // SELL信号
+	ExitTimeout = "timeout" // 超过最长持仓时间
+	ExitEnd     = "end"     // 回测结束强制平仓
+)
+
+// 委托未成交的原因
+const (
+	BlockedLimitUp   = "limit_up"   // 涨停无法买入
+	BlockedLimitDown = "limit_down" // 跌停无法卖出
+	BlockedLotSize   = "lot_size"   // 资金不足一手
+)
+
+// Trade 一笔完整的交易（开仓到平仓）
+type Trade struct {
+	EntryTime   time.Time `json:"entry_time"`
+	EntryPrice  float64   `json:"entry_price"`
+	ExitTime    time.Time `json:"exit_time"`
+	ExitPrice   float64   `json:"exit_price"`
+	Shares      int64     `json:"shares"`
+	TargetPrice float64   `json:"target_price"`
+	StopLoss    float64   `json:"stop_loss"`
+	Confidence  int       `json:"confidence"`
+	ExitReason  string    `json:"exit_reason"`
+	HoldingBars int       `json:"holding_bars"`
+	Fees        float64   `json:"fees"`       // 买卖费用合计（元）
+	PnL         float64   `json:"pnl"`        // 扣除费用后的盈亏（元）
+	ReturnPct   float64   `json:"return_pct"` // 扣除费用后的收益率（%）
+}
+
+// EquityPoint 每根K线收盘时的账户权益
+type EquityPoint struct {
+	Time   time.Time `json:"time"`
+	Equity float64   `json:"equity"`
+}
+
+// Engine 回测引擎
+type Engine struct {
+	Config Config
+	Source DecisionSource
+}
+
+// NewEngine 创建回测引擎
+func NewEngine(config Config, source DecisionSource) *Engine {
+	config.applyDefaults()
+	return &Engine{
+		Config: config,
+		Source: source,
+	}
+}
+
+// position 持仓状态
+type position struct {
+	trade     Trade
+	entryIdx  int
+	entryCost float64 // 买入金额+买入费用
+}
+
+// Run 按时间顺序回放K线并模拟交易
+// 决策在K线收盘后做出，在下一根K线开盘价成交；持仓期间按K线最高/最低价判断是否触及目标价或止损价
+// 成交遵循A股交易规则：买入按整手并扣除费用，当日买入不能卖出，涨停不能买入、跌停不能卖出
+func (e *Engine) Run(ctx context.Context, klines []stock.KlineItem) (*Report, error) {
+	cfg := e.Config
+	if len(klines) <= cfg.Warmup {
+		return nil, fmt.Errorf("K线数量不足: %d条，至少需要%d条", len(klines), cfg.Warmup+1)
+	}
+
+	rules := cfg.Rules
+	st := market.IsST(cfg.StockName)
+	report := newReport(cfg)
+	cash := cfg.InitialCapital
+	var pos *position
+	var pending *stock.AIDecisionResponse // 待下一根K线开盘执行的决策
+
+	closePosition := func(i int, fill *market.Fill, reason string) {
+		pos.trade.ExitTime = fill.Time
+		pos.trade.ExitPrice = fill.Price
+		pos.trade.ExitReason = reason
+		pos.trade.HoldingBars = i - pos.entryIdx
+		pos.trade.Fees += fill.Fee.Total
+		pos.trade.PnL = fill.NetAmount() - pos.entryCost
+		pos.trade.ReturnPct = pos.trade.PnL / pos.entryCost * 100
+		cash += fill.NetAmount()
+		report.TotalFees += fill.Fee.Total
+		report.Trades = append(report.Trades, pos.trade)
+		pos = nil
+	}
+
+	// trySell 按交易规则卖出全部持仓，被涨跌停或T+1限制时返回false
+	trySell := func(i int, price float64, prevClose float64, reason string) bool {
+		fill, err := rules.Execute(market.Order{
+			Code:      cfg.StockCode,
+			ST:        st,
+			Side:      market.SideSell,
+			Price:     price,
+			Shares:    pos.trade.Shares,
+			PrevClose: prevClose,
+			Time:      klines[i].Time,
+			BuyTime:   pos.trade.EntryTime,
+		})
+		if err != nil {
+			report.countBlocked(err)
+			return false
+		}
+		closePosition(i, fill, reason)
+		return true
+	}
+
+	for i := 0; i < len(klines); i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		bar := klines[i]
+		open := stock.PriceToYuan(bar.Open)
+		high := stock.PriceToYuan(bar.High)
+		low := stock.PriceToYuan(bar.Low)
+		closePrice := stock.PriceToYuan(bar.Close)
+		prevClose := stock.PriceToYuan(bar.Last)
+		if prevClose == 0 && i > 0 {
+			prevClose = stock.PriceToYuan(klines[i-1].Close)
+		}
+
+		// 1. 开盘执行上一根K线收盘后的决策
+		if pending != nil {
+			decision := pending
+			pending = nil
+			switch {
+			case decision.Signal == "BUY" && pos == nil && open > 0:
+				fill, err := rules.Execute(market.Order{
+					Code:      cfg.StockCode,
+					ST:        st,
+					Side:      market.SideBuy,
+					Price:     open,
+					Shares:    rules.MaxBuyShares(cfg.StockCode, cash*cfg.PositionRatio, open),
+					PrevClose: prevClose,
+					Time:      bar.Time,
+				})
+				if err != nil {
+					report.countBlocked(err)
+					break
+				}
+				cash += fill.NetAmount()
+				report.TotalFees += fill.Fee.Total
+				pos = &position{
+					entryIdx:  i,
+					entryCost: -fill.NetAmount(),
+					trade: Trade{
+						EntryTime:   bar.Time,
+						EntryPrice:  fill.Price,
+						Shares:      fill.Shares,
+						TargetPrice: decision.TargetPrice,
+						StopLoss:    decision.StopLoss,
+						Confidence:  decision.Confidence,
+						Fees:        fill.Fee.Total,
+					},
+				}
+			case decision.Signal == "SELL" && pos != nil:
+				// 跌停卖不出时保留委托，下一根K线开盘继续卖出
+				if !trySell(i, open, prevClose, ExitSignal) {
+					pending = decision
+				}
+			}
+		}
+
+		// 2. 盘中检查止损和目标价（同一根K线同时触及时保守地认为先触及止损）
+		// 当日买入的持仓受T+1限制不能卖出
+		if pos != nil && rules.CanSell(pos.trade.EntryTime, bar.Time) {
+			stop, target := pos.trade.StopLoss, pos.trade.TargetPrice
+			switch {
+			case stop > 0 && low <= stop:
+				trySell(i, math.Min(open, stop), prevClose, ExitStop)
+			case target > 0 && high >= target:
+				trySell(i, math.Max(open, target), prevClose, ExitTarget)
+			case cfg.MaxHoldingBars > 0 && i-pos.entryIdx >= cfg.MaxHoldingBars:
+				trySell(i, closePrice, prevClose, ExitTimeout)
+			}
+		}
+
+		// 3. 记录收盘权益
+		equity := cash
+		if pos != nil {
+			equity += closePrice * float64(pos.trade.Shares)
+		}
+		report.EquityCurve = append(report.EquityCurve, EquityPoint{Time: bar.Time, Equity: equity})
+
+		// 4. 收盘后决策（最后一根K线之后没有可成交的K线，不再决策）
+		if i+1 < cfg.Warmup || i == len(klines)-1 || (i+1-cfg.Warmup)%cfg.DecisionEvery != 0 {
+			continue
+		}
+		input := BuildInput(klines, i, cfg.Lookback)
+		decision, err := e.Source.Decide(ctx, input)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			log.Printf("⚠️  %s 决策失败，跳过: %v", bar.Time.Format("2006-01-02"), err)
+			continue
+		}
+		report.countDecision(decision.Signal)
+
+		switch decision.Signal {
+		case "BUY":
+			if pos == nil && decision.Confidence >= cfg.MinConfidence && validBuy(decision, closePrice) {
+				pending = decision
+			}
+		case "SELL":
+			if pos != nil {
+				pending = decision
+			}
+		}
+	}
+
+	// 回测结束时按最后收盘价平仓（仅用于结算，不受涨跌停和T+1限制）
+	if pos != nil {
+		last := len(klines) - 1
+		price := stock.PriceToYuan(klines[last].Close)
+		amount := price * float64(pos.trade.Shares)
+		closePosition(last, &market.Fill{
+			Side:   market.SideSell,
+			Price:  price,
+			Shares: pos.trade.Shares,
+			Amount: amount,
+			Fee:    rules.CalculateFee(cfg.StockCode, market.SideSell, amount),
+			Time:   klines[last].Time,
+		}, ExitEnd)
+		report.EquityCurve[last].Equity = cash
+	}
+
+	report.finalize()
+	return report, nil
+}
+
+// validBuy BUY信号的目标价和止损价是否合理
+func validBuy(decision *stock.AIDecisionResponse, price float64) bool {
+	return decision.TargetPrice > price && decision.StopLoss > 0 && decision.StopLoss < price
+}
+
+// BuildInput 重建第idx根K线收盘时的行情视图
+// 只包含截至该K线的数据，没有盘口、分时和30分钟K线
+func BuildInput(klines []stock.KlineItem, idx int, lookback int) *stock.AnalysisInput {
+	start := idx - lookback + 1
+	if start < 0 {
+		start = 0
+	}
+	window := make([]stock.KlineItem, idx+1-start)
+	copy(window, klines[start:idx+1])
+
+	bar := klines[idx]
+	last := bar.Last
+	if last == 0 && idx > 0 {
+		last = klines[idx-1].Close
+	}
+
+	quote := &stock.QuoteData{
+		K: stock.KData{
+			Last:  last,
+			Open:  bar.Open,
+			High:  bar.High,
+			Low:   bar.Low,
+			Close: bar.Close,
+		},
+		TotalHand: bar.Volume,
+		Amount:    bar.Amount,
+	}
+	dayKline := &stock.KlineData{Count: len(window), List: window}
+
+	return &stock.AnalysisInput{
+		Time:       bar.Time,
+		Quote:      quote,
+		DayKline:   dayKline,
+		Min30Kline: &stock.KlineData{},
+		Technical:  stock.CalculateTechnicalSnapshot(quote, dayKline),
+	}
+}
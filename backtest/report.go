@@ -0,0 +1,187 @@
+package backtest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"nofx/stock/market"
+	"strings"
+)
+
+// Report 回测报告
+type Report struct {
+	StockCode      string         `json:"stock_code"`
+	StockName      string         `json:"stock_name"`
+	InitialCapital float64        `json:"initial_capital"`
+	FinalEquity    float64        `json:"final_equity"`
+	TotalReturn    float64        `json:"total_return"`   // 总收益率（%）
+	WinRate        float64        `json:"win_rate"`       // 胜率（%）
+	MaxDrawdown    float64        `json:"max_drawdown"`   // 最大回撤（%）
+	Sharpe         float64        `json:"sharpe"`         // 年化夏普比率（按日收益、无风险利率0、252个交易日）
+	TradeCount     int            `json:"trade_count"`    // 交易笔数
+	WinCount       int            `json:"win_count"`      // 盈利笔数
+	LossCount      int            `json:"loss_count"`     // 亏损笔数
+	AvgHolding     float64        `json:"avg_holding"`    // 平均持仓K线数
+	BuySignals     int            `json:"buy_signals"`    // BUY决策次数
+	SellSignals    int            `json:"sell_signals"`   // SELL决策次数
+	HoldSignals    int            `json:"hold_signals"`   // HOLD决策次数
+	ExitReasons    map[string]int `json:"exit_reasons"`   // 各平仓原因的次数
+	TotalFees      float64        `json:"total_fees"`     // 交易费用合计（佣金+印花税+过户费）
+	BlockedOrders  map[string]int `json:"blocked_orders"` // 因涨跌停、不足一手等未成交的委托次数
+	Trades         []Trade        `json:"trades"`
+	EquityCurve    []EquityPoint  `json:"equity_curve"`
+}
+
+// newReport 创建空报告
+func newReport(cfg Config) *Report {
+	return &Report{
+		StockCode:      cfg.StockCode,
+		StockName:      cfg.StockName,
+		InitialCapital: cfg.InitialCapital,
+		ExitReasons:    make(map[string]int),
+		BlockedOrders:  make(map[string]int),
+		Trades:         []Trade{},
+		EquityCurve:    []EquityPoint{},
+	}
+}
+
+// countDecision 统计决策信号
+func (r *Report) countDecision(signal string) {
+	switch signal {
+	case "BUY":
+		r.BuySignals++
+	case "SELL":
+		r.SellSignals++
+	default:
+		r.HoldSignals++
+	}
+}
+
+// countBlocked 统计未成交的委托
+func (r *Report) countBlocked(err error) {
+	switch {
+	case errors.Is(err, market.ErrLimitUp):
+		r.BlockedOrders[BlockedLimitUp]++
+	case errors.Is(err, market.ErrLimitDown):
+		r.BlockedOrders[BlockedLimitDown]++
+	case errors.Is(err, market.ErrLotSize):
+		r.BlockedOrders[BlockedLotSize]++
+	}
+}
+
+// finalize 计算汇总指标
+func (r *Report) finalize() {
+	r.FinalEquity = r.InitialCapital
+	if len(r.EquityCurve) > 0 {
+		r.FinalEquity = r.EquityCurve[len(r.EquityCurve)-1].Equity
+	}
+	r.TotalReturn = (r.FinalEquity/r.InitialCapital - 1) * 100
+
+	r.TradeCount = len(r.Trades)
+	totalHolding := 0
+	for _, trade := range r.Trades {
+		if trade.PnL > 0 {
+			r.WinCount++
+		} else {
+			r.LossCount++
+		}
+		totalHolding += trade.HoldingBars
+		r.ExitReasons[trade.ExitReason]++
+	}
+	if r.TradeCount > 0 {
+		r.WinRate = float64(r.WinCount) / float64(r.TradeCount) * 100
+		r.AvgHolding = float64(totalHolding) / float64(r.TradeCount)
+	}
+
+	r.MaxDrawdown = maxDrawdown(r.EquityCurve)
+	r.Sharpe = sharpeRatio(r.EquityCurve)
+}
+
+// maxDrawdown 最大回撤（%）
+func maxDrawdown(curve []EquityPoint) float64 {
+	peak := 0.0
+	maxDD := 0.0
+	for _, point := range curve {
+		if point.Equity > peak {
+			peak = point.Equity
+		}
+		if peak > 0 {
+			dd := (peak - point.Equity) / peak * 100
+			if dd > maxDD {
+				maxDD = dd
+			}
+		}
+	}
+	return maxDD
+}
+
+// sharpeRatio 年化夏普比率
+func sharpeRatio(curve []EquityPoint) float64 {
+	if len(curve) < 3 {
+		return 0
+	}
+
+	returns := make([]float64, 0, len(curve)-1)
+	for i := 1; i < len(curve); i++ {
+		if curve[i-1].Equity > 0 {
+			returns = append(returns, curve[i].Equity/curve[i-1].Equity-1)
+		}
+	}
+	if len(returns) < 2 {
+		return 0
+	}
+
+	mean := 0.0
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+
+	variance := 0.0
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+	}
+	std := math.Sqrt(variance / float64(len(returns)-1))
+	if std == 0 {
+		return 0
+	}
+	return mean / std * math.Sqrt(252)
+}
+
+// String 生成文本格式的回测报告
+func (r *Report) String() string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("📈 回测报告 %s(%s)\n", r.StockName, r.StockCode))
+	if len(r.EquityCurve) > 0 {
+		b.WriteString(fmt.Sprintf("  区间: %s ~ %s (%d根K线)\n",
+			r.EquityCurve[0].Time.Format("2006-01-02"),
+			r.EquityCurve[len(r.EquityCurve)-1].Time.Format("2006-01-02"),
+			len(r.EquityCurve)))
+	}
+	b.WriteString(fmt.Sprintf("  初始资金: %.2f元 | 期末权益: %.2f元\n", r.InitialCapital, r.FinalEquity))
+	b.WriteString(fmt.Sprintf("  总收益率: %.2f%% | 最大回撤: %.2f%% | 夏普比率: %.2f\n", r.TotalReturn, r.MaxDrawdown, r.Sharpe))
+	b.WriteString(fmt.Sprintf("  交易笔数: %d | 盈利: %d | 亏损: %d | 胜率: %.1f%% | 平均持仓: %.1f根K线\n",
+		r.TradeCount, r.WinCount, r.LossCount, r.WinRate, r.AvgHolding))
+	b.WriteString(fmt.Sprintf("  交易费用: %.2f元\n", r.TotalFees))
+	b.WriteString(fmt.Sprintf("  决策: BUY=%d SELL=%d HOLD=%d\n", r.BuySignals, r.SellSignals, r.HoldSignals))
+	if len(r.BlockedOrders) > 0 {
+		b.WriteString(fmt.Sprintf("  未成交委托: 涨停=%d 跌停=%d 不足一手=%d\n",
+			r.BlockedOrders[BlockedLimitUp], r.BlockedOrders[BlockedLimitDown], r.BlockedOrders[BlockedLotSize]))
+	}
+	if len(r.ExitReasons) > 0 {
+		b.WriteString(fmt.Sprintf("  平仓原因: 目标价=%d 止损=%d 信号=%d 超时=%d 结束=%d\n",
+			r.ExitReasons[ExitTarget], r.ExitReasons[ExitStop], r.ExitReasons[ExitSignal],
+			r.ExitReasons[ExitTimeout], r.ExitReasons[ExitEnd]))
+	}
+
+	if len(r.Trades) > 0 {
+		b.WriteString("\n  明细:\n")
+		for _, t := range r.Trades {
+			b.WriteString(fmt.Sprintf("  %s %.2f -> %s %.2f x%d | %+.2f元 (%+.2f%%) | %s\n",
+				t.EntryTime.Format("2006-01-02"), t.EntryPrice,
+				t.ExitTime.Format("2006-01-02"), t.ExitPrice,
+				t.Shares, t.PnL, t.ReturnPct, t.ExitReason))
+		}
+	}
+	return b.String()
+}
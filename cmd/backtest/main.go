@@ -0,0 +1,190 @@
+// backtest 回测命令：用历史日K线回放分析流程，评估BUY/SELL信号的效果
+//
+// 用法示例:
+//
+//	go run ./cmd/backtest -file test_kline.json -code 000001 -source macd
+//	go run ./cmd/backtest -config config_stock.json -code 600519 -start 20240101 -end 20241231 -source ai -every 5
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"nofx/backtest"
+	"nofx/config"
+	"nofx/mcp"
+	"nofx/stock"
+	"nofx/stock/market"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	configFile := flag.String("config", "config_stock.json", "配置文件（使用TDX数据或AI决策时需要）")
+	code := flag.String("code", "", "股票代码")
+	name := flag.String("name", "", "股票名称（默认从配置文件读取）")
+	klineFile := flag.String("file", "", "K线文件（如test_kline.json），指定后不再从TDX获取数据")
+	startDate := flag.String("start", "", "开始日期（YYYYMMDD）")
+	endDate := flag.String("end", "", "结束日期（YYYYMMDD）")
+	source := flag.String("source", "macd", "决策来源: macd（MACD金叉死叉规则）或 ai（与实时分析相同的AI提示词）")
+	capital := flag.Float64("capital", 100000, "初始资金（元）")
+	positionRatio := flag.Float64("position", 1, "每次开仓使用的资金比例(0,1]")
+	minConfidence := flag.Int("min-confidence", 0, "BUY信号的最小信心度（默认使用配置文件中该股票的min_confidence）")
+	maxHolding := flag.Int("max-holding", 0, "最长持仓K线数，0表示不限制")
+	lookback := flag.Int("lookback", 60, "每次决策可见的日K线数量")
+	warmup := flag.Int("warmup", 30, "开始决策前至少需要的K线数量")
+	every := flag.Int("every", 1, "每隔N根K线决策一次（AI决策时可减少调用次数）")
+	rulesFile := flag.String("rules", "", "A股交易规则JSON文件（按板块配置涨跌幅、每手股数和费用），默认使用内置规则")
+	jsonOut := flag.String("json", "", "将完整回测报告写入JSON文件")
+	flag.Parse()
+
+	if *code == "" {
+		log.Fatalf("❌ 必须指定 -code")
+	}
+	if *klineFile != "" && (*startDate != "" || *endDate != "") {
+		log.Printf("⚠️  使用K线文件时 -start/-end 仅用于过滤文件中的数据")
+	}
+
+	// 使用TDX数据或AI决策时需要配置文件
+	var cfg *config.StockConfig
+	if *klineFile == "" || *source == "ai" {
+		var err error
+		cfg, err = config.LoadStockConfig(*configFile)
+		if err != nil {
+			log.Fatalf("❌ 加载配置失败: %v", err)
+		}
+	}
+
+	stockItem := findStock(cfg, *code)
+	if *name == "" && stockItem != nil {
+		*name = stockItem.Name
+	}
+	if *minConfidence == 0 && stockItem != nil {
+		*minConfidence = stockItem.MinConfidence
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	// 加载K线
+	var klines []stock.KlineItem
+	var err error
+	if *klineFile != "" {
+		klines, err = backtest.LoadKlineFile(*klineFile)
+		if err == nil {
+			klines = filterByDate(klines, *startDate, *endDate)
+		}
+	} else {
+		klines, err = backtest.FetchKlines(ctx, stock.NewTDXClient(cfg.TDXAPIUrl), *code, *startDate, *endDate)
+	}
+	if err != nil {
+		log.Fatalf("❌ 加载K线失败: %v", err)
+	}
+	log.Printf("✓ 已加载%d根日K线", len(klines))
+
+	// 创建决策来源
+	var decisionSource backtest.DecisionSource
+	switch *source {
+	case "macd":
+		decisionSource = backtest.NewMACDDecisionSource()
+	case "ai":
+		mcpClient, err := mcp.NewFromConfig(&cfg.AIConfig)
+		if err != nil {
+			log.Fatalf("❌ 创建AI客户端失败: %v", err)
+		}
+		analyzer := stock.NewStockAnalyzer(nil, mcpClient, nil, &stock.AnalysisConfig{
+			StockCode: *code,
+			StockName: *name,
+		}, nil)
+		if stockItem != nil {
+			promptConfig := cfg.GetPromptConfig(stockItem)
+			analyzer.PromptTemplate, err = stock.LoadPromptTemplate(promptConfig.SystemTemplate, promptConfig.UserTemplate)
+			if err != nil {
+				log.Fatalf("❌ 加载提示词模板失败: %v", err)
+			}
+		}
+		decisionSource = backtest.NewAIDecisionSource(analyzer, mcpClient)
+		log.Printf("⚠️  AI决策会对每个决策点调用一次AI接口，请注意调用费用（可用 -every 降低频率）")
+	default:
+		log.Fatalf("❌ 不支持的决策来源: %s", *source)
+	}
+
+	rules := market.DefaultRules()
+	if *rulesFile != "" {
+		rules, err = market.LoadRules(*rulesFile)
+		if err != nil {
+			log.Fatalf("❌ 加载交易规则失败: %v", err)
+		}
+	}
+	board := rules.BoardRules(*code)
+	log.Printf("✓ 交易规则: 板块=%s 涨跌幅=±%.0f%% 每手%d股 佣金=%.4f%%(最低%.0f元) 印花税=%.3f%%",
+		market.DetectBoard(*code), board.PriceLimit*100, board.LotSize,
+		board.Fees.CommissionRate*100, board.Fees.MinCommission, board.Fees.StampDutyRate*100)
+
+	engine := backtest.NewEngine(backtest.Config{
+		StockCode:      *code,
+		StockName:      *name,
+		InitialCapital: *capital,
+		PositionRatio:  *positionRatio,
+		Lookback:       *lookback,
+		Warmup:         *warmup,
+		MinConfidence:  *minConfidence,
+		MaxHoldingBars: *maxHolding,
+		DecisionEvery:  *every,
+		Rules:          rules,
+	}, decisionSource)
+
+	report, err := engine.Run(ctx, klines)
+	if err != nil {
+		log.Fatalf("❌ 回测失败: %v", err)
+	}
+
+	fmt.Println()
+	fmt.Print(report.String())
+
+	if *jsonOut != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			log.Fatalf("❌ 序列化回测报告失败: %v", err)
+		}
+		if err := os.WriteFile(*jsonOut, data, 0644); err != nil {
+			log.Fatalf("❌ 写入回测报告失败: %v", err)
+		}
+		log.Printf("✓ 回测报告已保存: %s", *jsonOut)
+	}
+}
+
+// findStock 在配置中查找股票
+func findStock(cfg *config.StockConfig, code string) *config.StockItem {
+	if cfg == nil {
+		return nil
+	}
+	for i := range cfg.Stocks {
+		if cfg.Stocks[i].Code == code {
+			return &cfg.Stocks[i]
+		}
+	}
+	return nil
+}
+
+// filterByDate 按日期（YYYYMMDD）过滤K线
+func filterByDate(klines []stock.KlineItem, startDate, endDate string) []stock.KlineItem {
+	if startDate == "" && endDate == "" {
+		return klines
+	}
+	filtered := []stock.KlineItem{}
+	for _, k := range klines {
+		day := k.Time.Format("20060102")
+		if startDate != "" && day < startDate {
+			continue
+		}
+		if endDate != "" && day > endDate {
+			continue
+		}
+		filtered = append(filtered, k)
+	}
+	return filtered
+}
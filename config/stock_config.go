@@ -4,18 +4,44 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 )
 
 // StockConfig 股票分析系统配置
 type StockConfig struct {
-	TDXAPIUrl     string             `json:"tdx_api_url"`
-	AIConfig      AIConfig           `json:"ai_config"`
-	Stocks        []StockItem        `json:"stocks"`
-	Notification  NotificationConfig `json:"notification"`
-	TradingTime   TradingTimeConfig  `json:"trading_time"`
-	APIServerPort int                `json:"api_server_port"`
-	LogDir        string             `json:"log_dir"`
+	TDXAPIUrl     string              `json:"tdx_api_url"`
+	AIConfig      AIConfig            `json:"ai_config"`
+	Stocks        []StockItem         `json:"stocks"`
+	Notification  NotificationConfig  `json:"notification"`
+	TradingTime   TradingTimeConfig   `json:"trading_time"`
+	APIServerPort int                 `json:"api_server_port"`
+	LogDir        string              `json:"log_dir"`
+	ResultStore   ResultStoreConfig   `json:"result_store"`
+	Prompt        PromptConfig        `json:"prompt"`
+	SignalTracker SignalTrackerConfig `json:"signal_tracker"`
+}
+
+// SignalTrackerConfig 信号结果跟踪配置
+type SignalTrackerConfig struct {
+	Enabled              bool   `json:"enabled"`
+	CheckIntervalMinutes int    `json:"check_interval_minutes"` // 行情检查间隔（分钟），默认5
+	TimeoutDays          int    `json:"timeout_days"`           // 超时交易日数，默认5
+	SummaryTime          string `json:"summary_time"`           // 每个交易日发送统计通知的时间（HH:MM），为空不发送
+	StoreFile            string `json:"store_file"`             // 跟踪记录文件，默认为 <log_dir>/signal_outcomes.json
+}
+
+// PromptConfig 提示词模板配置（Go text/template文件，为空时使用内置提示词）
+type PromptConfig struct {
+	SystemTemplate string `json:"system_template"` // 系统提示词模板文件
+	UserTemplate   string `json:"user_template"`   // 分析提示词模板文件
+}
+
+// ResultStoreConfig 分析结果存储配置
+type ResultStoreConfig struct {
+	Type string `json:"type"` // "jsonl"（默认）或 "memory"
+	Dir  string `json:"dir"`  // JSONL文件目录，默认为 <log_dir>/results
 }
 
 // TradingTimeConfig 交易时间配置
@@ -26,36 +52,354 @@ type TradingTimeConfig struct {
 }
 
 // AIConfig AI配置
+// 配置providers时按顺序故障转移，忽略provider等单提供商字段
 type AIConfig struct {
-	Provider        string `json:"provider"` // "deepseek", "qwen", "custom"
-	DeepSeekKey     string `json:"deepseek_key"`
-	QwenKey         string `json:"qwen_key"`
-	CustomAPIURL    string `json:"custom_api_url"`
-	CustomAPIKey    string `json:"custom_api_key"`
-	CustomModelName string `json:"custom_model_name"`
+	Provider                string             `json:"provider"` // "deepseek", "qwen", "custom", "anthropic", "ollama"
+	DeepSeekKey             string             `json:"deepseek_key"`
+	QwenKey                 string             `json:"qwen_key"`
+	CustomAPIURL            string             `json:"custom_api_url"`
+	CustomAPIKey            string             `json:"custom_api_key"`
+	CustomModelName         string             `json:"custom_model_name"`
+	AnthropicKey            string             `json:"anthropic_key"`
+	AnthropicModel          string             `json:"anthropic_model"` // 默认claude-3-5-sonnet-latest
+	OllamaURL               string             `json:"ollama_url"`      // 默认http://localhost:11434
+	OllamaModel             string             `json:"ollama_model"`
+	Providers               []AIProviderConfig `json:"providers"`                 // 按顺序尝试的提供商列表
+	FailoverCooldownSeconds int                `json:"failover_cooldown_seconds"` // 提供商失败后排到队尾的时长（秒），默认300
+	Ensemble                EnsembleConfig     `json:"ensemble"`                  // 多模型集成投票
+	MaxAttempts             int                `json:"max_attempts"`              // 每个提供商最多尝试次数（含首次），默认3
+	MaxConcurrency          int                `json:"max_concurrency"`           // 每个提供商同时进行的请求数上限，0表示不限制
+	Usage                   AIUsageConfig      `json:"usage"`                     // token用量与费用统计
+}
+
+// AIUsageConfig AI用量与费用统计配置
+type AIUsageConfig struct {
+	Prices      map[string]ModelPriceConfig `json:"prices"`       // 模型名称（或providers中的名称）→ 每百万token价格
+	DailyBudget float64                     `json:"daily_budget"` // 每日费用预算（与价格同一货币单位），超出后暂停AI分析直到次日，0表示不限制
+	StoreFile   string                      `json:"store_file"`   // 用量记录文件，默认为 <log_dir>/ai_usage.json
+	KeepDays    int                         `json:"keep_days"`    // 保留最近多少天的记录，默认90
+}
+
+// ModelPriceConfig 模型价格（每百万token）
+type ModelPriceConfig struct {
+	Input  float64 `json:"input"`  // 输入token价格
+	Output float64 `json:"output"` // 输出token价格
+}
+
+// EnsembleConfig 多模型集成投票配置
+type EnsembleConfig struct {
+	Enabled bool                   `json:"enabled"`
+	Voting  string                 `json:"voting"`  // "majority"（默认，一票一权）或 "weighted"（按成员weight计票）
+	Members []EnsembleMemberConfig `json:"members"` // 投票成员，为空时使用providers中的全部提供商
+}
+
+// EnsembleMemberConfig 集成投票成员配置
+type EnsembleMemberConfig struct {
+	Provider string  `json:"provider"` // providers中的提供商名称
+	Weight   float64 `json:"weight"`   // 投票权重，默认1
+	Samples  int     `json:"samples"`  // 同一提示词的采样次数，默认1
+}
+
+// AIProviderConfig 单个AI提供商配置
+type AIProviderConfig struct {
+	Name           string `json:"name"`            // 显示名称（记录在分析结果中），默认为provider，重复时自动加序号
+	Provider       string `json:"provider"`        // "deepseek", "qwen", "custom", "anthropic", "ollama"
+	APIKey         string `json:"api_key"`         // API密钥（ollama不需要）
+	APIURL         string `json:"api_url"`         // API地址（custom必填，其他提供商可选覆盖默认地址）
+	Model          string `json:"model"`           // 模型名称（custom和ollama必填，其他提供商可选覆盖默认模型）
+	TimeoutSeconds int    `json:"timeout_seconds"` // 单次请求超时（秒），默认120
+	MaxAttempts    int    `json:"max_attempts"`    // 最多尝试次数，默认使用ai_config.max_attempts
+	MaxConcurrency int    `json:"max_concurrency"` // 并发请求数上限，默认使用ai_config.max_concurrency
+}
+
+// LegacyProvider 将单提供商字段转换为提供商配置
+func (c *AIConfig) LegacyProvider() AIProviderConfig {
+	p := AIProviderConfig{Name: c.Provider, Provider: c.Provider, MaxAttempts: c.MaxAttempts, MaxConcurrency: c.MaxConcurrency}
+	switch c.Provider {
+	case "deepseek":
+		p.APIKey = c.DeepSeekKey
+	case "qwen":
+		p.APIKey = c.QwenKey
+	case "custom":
+		p.APIKey = c.CustomAPIKey
+		p.APIURL = c.CustomAPIURL
+		p.Model = c.CustomModelName
+	case "anthropic":
+		p.APIKey = c.AnthropicKey
+		p.Model = c.AnthropicModel
+	case "ollama":
+		p.APIURL = c.OllamaURL
+		p.Model = c.OllamaModel
+	}
+	return p
+}
+
+// validateProviders 验证AI提供商配置，未配置providers时由单提供商字段生成
+func (c *AIConfig) validateProviders() error {
+	if len(c.Providers) == 0 {
+		if c.Provider == "" {
+			return fmt.Errorf("ai_config.provider不能为空")
+		}
+		if !isValidAIProvider(c.Provider) {
+			return fmt.Errorf("ai_config.provider必须是 'deepseek', 'qwen', 'custom', 'anthropic' 或 'ollama'")
+		}
+
+		// 验证对应的API密钥
+		if c.Provider == "deepseek" && c.DeepSeekKey == "" {
+			return fmt.Errorf("使用DeepSeek时必须配置deepseek_key")
+		}
+		if c.Provider == "qwen" && c.QwenKey == "" {
+			return fmt.Errorf("使用Qwen时必须配置qwen_key")
+		}
+		if c.Provider == "custom" {
+			if c.CustomAPIURL == "" || c.CustomAPIKey == "" || c.CustomModelName == "" {
+				return fmt.Errorf("使用自定义API时必须配置custom_api_url, custom_api_key和custom_model_name")
+			}
+		}
+		if c.Provider == "anthropic" && c.AnthropicKey == "" {
+			return fmt.Errorf("使用Anthropic时必须配置anthropic_key")
+		}
+		if c.Provider == "ollama" && c.OllamaModel == "" {
+			return fmt.Errorf("使用Ollama时必须配置ollama_model")
+		}
+		c.Providers = []AIProviderConfig{c.LegacyProvider()}
+	} else if err := c.validateProviderList(); err != nil {
+		return err
+	}
+
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = 3
+	}
+	for i := range c.Providers {
+		p := &c.Providers[i]
+		if p.MaxAttempts <= 0 {
+			p.MaxAttempts = c.MaxAttempts
+		}
+		if p.MaxConcurrency <= 0 {
+			p.MaxConcurrency = c.MaxConcurrency
+		}
+	}
+	if c.FailoverCooldownSeconds <= 0 {
+		c.FailoverCooldownSeconds = 300
+	}
+	return nil
+}
+
+// isValidAIProvider 是否为支持的AI提供商
+func isValidAIProvider(provider string) bool {
+	switch provider {
+	case "deepseek", "qwen", "custom", "anthropic", "ollama":
+		return true
+	}
+	return false
+}
+
+// validateProviderList 验证providers列表并设置默认名称
+func (c *AIConfig) validateProviderList() error {
+	names := make(map[string]bool)
+	for i := range c.Providers {
+		p := &c.Providers[i]
+		if !isValidAIProvider(p.Provider) {
+			return fmt.Errorf("ai_config.providers[%d].provider必须是 'deepseek', 'qwen', 'custom', 'anthropic' 或 'ollama'", i)
+		}
+		if p.APIKey == "" && p.Provider != "ollama" {
+			return fmt.Errorf("ai_config.providers[%d]必须配置api_key", i)
+		}
+		if p.Provider == "custom" && (p.APIURL == "" || p.Model == "") {
+			return fmt.Errorf("ai_config.providers[%d]使用自定义API时必须配置api_url和model", i)
+		}
+		if p.Provider == "ollama" && p.Model == "" {
+			return fmt.Errorf("ai_config.providers[%d]使用Ollama时必须配置model", i)
+		}
+		if p.Name == "" {
+			p.Name = p.Provider
+			for n := 2; names[p.Name]; n++ {
+				p.Name = fmt.Sprintf("%s-%d", p.Provider, n)
+			}
+		}
+		if names[p.Name] {
+			return fmt.Errorf("ai_config.providers中的名称 %s 重复", p.Name)
+		}
+		names[p.Name] = true
+	}
+	return nil
+}
+
+// validateEnsemble 验证集成投票配置（需在validateProviders之后调用）
+func (c *AIConfig) validateEnsemble() error {
+	e := &c.Ensemble
+	if !e.Enabled {
+		return nil
+	}
+	if e.Voting == "" {
+		e.Voting = "majority"
+	}
+	if e.Voting != "majority" && e.Voting != "weighted" {
+		return fmt.Errorf("ai_config.ensemble.voting必须是 'majority' 或 'weighted'")
+	}
+	if len(e.Members) == 0 {
+		for _, p := range c.Providers {
+			e.Members = append(e.Members, EnsembleMemberConfig{Provider: p.Name})
+		}
+	}
+
+	samples := 0
+	for i := range e.Members {
+		m := &e.Members[i]
+		if c.ProviderConfig(m.Provider) == nil {
+			return fmt.Errorf("ai_config.ensemble.members[%d]引用的提供商 %s 不存在", i, m.Provider)
+		}
+		if m.Weight < 0 {
+			return fmt.Errorf("ai_config.ensemble.members[%d].weight不能为负数", i)
+		}
+		if m.Weight == 0 {
+			m.Weight = 1
+		}
+		if m.Samples <= 0 {
+			m.Samples = 1
+		}
+		samples += m.Samples
+	}
+	if samples < 2 {
+		return fmt.Errorf("集成投票至少需要2个回答（多个成员或samples大于1）")
+	}
+	return nil
+}
+
+// ProviderConfig 按名称查找提供商配置
+func (c *AIConfig) ProviderConfig(name string) *AIProviderConfig {
+	for i := range c.Providers {
+		if c.Providers[i].Name == name {
+			return &c.Providers[i]
+		}
+	}
+	return nil
 }
 
 // StockItem 股票配置项
 type StockItem struct {
-	Code                string `json:"code"`
-	Name                string `json:"name"`
-	Enabled             bool   `json:"enabled"`
-	ScanIntervalMinutes int    `json:"scan_interval_minutes"`
-	MinConfidence       int    `json:"min_confidence"` // 最小信心度阈值
+	Code                string        `json:"code"`
+	Name                string        `json:"name"`
+	Enabled             bool          `json:"enabled"`
+	ScanIntervalMinutes int           `json:"scan_interval_minutes"`
+	MinConfidence       int           `json:"min_confidence"`           // 最小信心度阈值
+	AnalysisTimeoutSec  int           `json:"analysis_timeout_seconds"` // 单次分析超时（秒）
+	Prompt              *PromptConfig `json:"prompt,omitempty"`         // 提示词模板覆盖（未配置的字段使用全局配置）
+	Tags                []string      `json:"tags,omitempty"`           // 分组标签（如"持仓"、"自选"），用于通知路由
 }
 
 // NotificationConfig 通知配置
 type NotificationConfig struct {
-	Enabled  bool           `json:"enabled"`
-	DingTalk DingTalkConfig `json:"dingtalk"`
-	Feishu   FeishuConfig   `json:"feishu"`
+	Enabled        bool            `json:"enabled"`
+	StartupMessage bool            `json:"startup_message"` // 启动时向每个渠道发送一条测试消息，尽早暴露地址、签名密钥等配置错误
+	DingTalk       DingTalkConfig  `json:"dingtalk"`
+	Feishu         FeishuConfig    `json:"feishu"`
+	WeCom          WeComConfig     `json:"wecom"`
+	Telegram       TelegramConfig  `json:"telegram"`
+	Slack          SlackConfig     `json:"slack"`
+	Discord        DiscordConfig   `json:"discord"`
+	Email          EmailConfig     `json:"email"`
+	Webhooks       []WebhookConfig `json:"webhooks"` // 通用Webhook（可配置多个）
+	Dedup          DedupConfig     `json:"dedup"`    // 信号去重与冷却
+	Delivery       DeliveryConfig  `json:"delivery"` // 投递队列（重试、限流、发件箱）
+	Routing        RoutingConfig   `json:"routing"`  // 通知路由规则
+	Digest         DigestConfig    `json:"digest"`   // 信号汇总与静默时段
+}
+
+// RoutingConfig 通知路由配置
+// 渠道名称：dingtalk、feishu、wecom、telegram、slack、discord、email，通用Webhook为其name
+type RoutingConfig struct {
+	DefaultChannels []string          `json:"default_channels"` // 没有规则匹配时发送的渠道，为空时发送到所有渠道
+	Rules           []RouteRuleConfig `json:"rules"`
+}
+
+// RouteRuleConfig 通知路由规则（各条件之间为"且"，未设置的条件不限制）
+type RouteRuleConfig struct {
+	Name          string              `json:"name"`
+	Stocks        []string            `json:"stocks"`         // 股票代码
+	Tags          []string            `json:"tags"`           // 股票分组标签
+	Signals       []string            `json:"signals"`        // BUY、SELL、HOLD
+	MinConfidence int                 `json:"min_confidence"` // 信心度下限（含）
+	MaxConfidence int                 `json:"max_confidence"` // 信心度上限（含），0表示不限制
+	Channels      []string            `json:"channels"`       // 发送到的渠道，为空表示匹配的信号不发送
+	Mentions      map[string][]string `json:"mentions"`       // 各渠道需要@的成员，如 {"dingtalk": ["13800000000"]}
+	Continue      bool                `json:"continue"`       // 匹配后继续匹配后续规则
+}
+
+// builtinChannelNames 内置通知渠道名称，通用Webhook不能使用
+var builtinChannelNames = []string{"dingtalk", "feishu", "wecom", "telegram", "slack", "discord", "email"}
+
+// EnabledChannels 返回已启用的通知渠道名称
+func (n *NotificationConfig) EnabledChannels() []string {
+	var channels []string
+	for _, channel := range []struct {
+		name    string
+		enabled bool
+	}{
+		{"dingtalk", n.DingTalk.Enabled},
+		{"feishu", n.Feishu.Enabled},
+		{"wecom", n.WeCom.Enabled},
+		{"telegram", n.Telegram.Enabled},
+		{"slack", n.Slack.Enabled},
+		{"discord", n.Discord.Enabled},
+		{"email", n.Email.Enabled},
+	} {
+		if channel.enabled {
+			channels = append(channels, channel.name)
+		}
+	}
+	for _, webhook := range n.Webhooks {
+		if webhook.Enabled {
+			channels = append(channels, webhook.Name)
+		}
+	}
+	return channels
+}
+
+// DeliveryConfig 通知投递队列配置
+type DeliveryConfig struct {
+	Enabled               bool           `json:"enabled"`
+	MaxAttempts           int            `json:"max_attempts"`            // 最多尝试次数（含首次），默认5
+	InitialBackoffSeconds int            `json:"initial_backoff_seconds"` // 首次重试等待（秒），之后每次翻倍，默认5
+	MaxBackoffSeconds     int            `json:"max_backoff_seconds"`     // 最长重试等待（秒），默认600
+	OutboxDir             string         `json:"outbox_dir"`              // 发件箱和死信目录，默认为 <log_dir>/outbox
+	RateLimits            map[string]int `json:"rate_limits"`             // 各渠道每分钟最多发送条数（键为渠道名，通用Webhook为其name），0表示不限制
+}
+
+// defaultRateLimits 各通知渠道的默认限流（每分钟条数），参考各平台机器人的频率限制
+var defaultRateLimits = map[string]int{
+	"dingtalk": 20,
+	"feishu":   100,
+	"wecom":    20,
+	"telegram": 20,
+	"slack":    60,
+	"discord":  30,
+}
+
+// DedupConfig 信号去重与冷却配置
+type DedupConfig struct {
+	Enabled         bool    `json:"enabled"`
+	CooldownMinutes int     `json:"cooldown_minutes"` // 同一股票两次通知的最短间隔（分钟），默认60
+	PriceChangePct  float64 `json:"price_change_pct"` // 目标价/止损价变化超过该百分比时重新通知，默认2
+	ConfidenceJump  int     `json:"confidence_jump"`  // 信心度上升达到该值时重新通知，默认10
+	StateFile       string  `json:"state_file"`       // 状态文件，默认为 <log_dir>/notify_state.json
+}
+
+// DigestConfig 信号汇总与静默时段配置
+type DigestConfig struct {
+	Enabled          bool     `json:"enabled"`
+	UrgentConfidence int      `json:"urgent_confidence"` // 信心度达到该值的信号立即发送，其余信号在汇总时间合并发送，默认85
+	DigestTimes      []string `json:"digest_times"`      // 发送汇总的时间（HH:MM），默认为各交易时段结束后5分钟（如11:35、15:05）
+	QuietHours       []string `json:"quiet_hours"`       // 静默时段（HH:MM-HH:MM，可跨午夜，如"22:00-08:00"），期间的通知在结束后合并发送
+	StoreFile        string   `json:"store_file"`        // 待汇总通知的保存文件，默认为 <log_dir>/notify_digest.json
 }
 
 // DingTalkConfig 钉钉配置
 type DingTalkConfig struct {
 	Enabled    bool   `json:"enabled"`
 	WebhookURL string `json:"webhook_url"`
-	Secret     string `json:"secret"`
+	Secret     string `json:"secret"`  // 加签密钥（安全设置选择"加签"时填写，SEC开头）
+	Keyword    string `json:"keyword"` // 自定义关键词（安全设置选择"自定义关键词"时填写）
 }
 
 // FeishuConfig 飞书配置
@@ -65,6 +409,63 @@ type FeishuConfig struct {
 	Secret     string `json:"secret"`
 }
 
+// WeComConfig 企业微信群机器人配置
+type WeComConfig struct {
+	Enabled        bool     `json:"enabled"`
+	WebhookURL     string   `json:"webhook_url"`
+	MessageType    string   `json:"message_type"`    // "markdown"（默认）或 "template_card"
+	MentionMobiles []string `json:"mention_mobiles"` // 需要@的成员手机号，"@all"表示所有人
+	CardURL        string   `json:"card_url"`        // 模板卡片点击跳转地址（template_card时必填）
+}
+
+// TelegramConfig Telegram机器人配置
+type TelegramConfig struct {
+	Enabled  bool   `json:"enabled"`
+	BotToken string `json:"bot_token"` // 通过@BotFather创建机器人获得
+	ChatID   string `json:"chat_id"`   // 用户、群组或频道ID（频道可用@channelname）
+	APIURL   string `json:"api_url"`   // Bot API地址（可选，默认https://api.telegram.org）
+}
+
+// SlackConfig Slack Incoming Webhook配置
+type SlackConfig struct {
+	Enabled    bool   `json:"enabled"`
+	WebhookURL string `json:"webhook_url"`
+}
+
+// DiscordConfig Discord Webhook配置
+type DiscordConfig struct {
+	Enabled    bool   `json:"enabled"`
+	WebhookURL string `json:"webhook_url"`
+	Username   string `json:"username"` // 覆盖Webhook显示名称（可选）
+}
+
+// EmailConfig SMTP邮件配置
+type EmailConfig struct {
+	Enabled  bool     `json:"enabled"`
+	SMTPHost string   `json:"smtp_host"`
+	SMTPPort int      `json:"smtp_port"` // 默认按security选择：starttls为587，ssl为465，none为25
+	Username string   `json:"username"`  // SMTP登录用户名（为空时不认证）
+	Password string   `json:"password"`  // SMTP密码或授权码
+	From     string   `json:"from"`      // 发件人地址（默认使用username）
+	To       []string `json:"to"`        // 收件人地址列表
+	Security string   `json:"security"`  // "starttls"（默认）、"ssl" 或 "none"
+}
+
+// WebhookConfig 通用Webhook配置
+// url、method、headers的值和两个body模板均为Go模板，可直接引用TradingSignal的字段
+type WebhookConfig struct {
+	Name            string            `json:"name"` // 渠道名称（日志和错误信息中显示）
+	Enabled         bool              `json:"enabled"`
+	URL             string            `json:"url"`
+	Method          string            `json:"method"`           // 默认POST
+	Headers         map[string]string `json:"headers"`          // 自定义请求头（默认Content-Type为application/json）
+	BodyTemplate    string            `json:"body_template"`    // 信号请求体模板（默认发送信号JSON）
+	MessageTemplate string            `json:"message_template"` // 普通消息请求体模板（默认{"message","timestamp"}）
+	Secret          string            `json:"secret"`           // HMAC-SHA256签名密钥（可选）
+	SignatureHeader string            `json:"signature_header"` // 签名请求头（默认X-Signature-256）
+	SignalsOnly     bool              `json:"signals_only"`     // 只推送交易信号，不推送启动通知等普通消息
+}
+
 // LoadStockConfig 加载股票分析配置
 func LoadStockConfig(filename string) (*StockConfig, error) {
 	data, err := os.ReadFile(filename)
@@ -93,24 +494,11 @@ func (c *StockConfig) Validate() error {
 	}
 
 	// 验证AI配置
-	if c.AIConfig.Provider == "" {
-		return fmt.Errorf("ai_config.provider不能为空")
+	if err := c.AIConfig.validateProviders(); err != nil {
+		return err
 	}
-	if c.AIConfig.Provider != "deepseek" && c.AIConfig.Provider != "qwen" && c.AIConfig.Provider != "custom" {
-		return fmt.Errorf("ai_config.provider必须是 'deepseek', 'qwen' 或 'custom'")
-	}
-
-	// 验证对应的API密钥
-	if c.AIConfig.Provider == "deepseek" && c.AIConfig.DeepSeekKey == "" {
-		return fmt.Errorf("使用DeepSeek时必须配置deepseek_key")
-	}
-	if c.AIConfig.Provider == "qwen" && c.AIConfig.QwenKey == "" {
-		return fmt.Errorf("使用Qwen时必须配置qwen_key")
-	}
-	if c.AIConfig.Provider == "custom" {
-		if c.AIConfig.CustomAPIURL == "" || c.AIConfig.CustomAPIKey == "" || c.AIConfig.CustomModelName == "" {
-			return fmt.Errorf("使用自定义API时必须配置custom_api_url, custom_api_key和custom_model_name")
-		}
+	if err := c.AIConfig.validateEnsemble(); err != nil {
+		return err
 	}
 
 	// 验证股票列表
@@ -143,6 +531,9 @@ func (c *StockConfig) Validate() error {
 		if stock.MinConfidence <= 0 {
 			c.Stocks[i].MinConfidence = 70 // 默认70%信心度
 		}
+		if stock.AnalysisTimeoutSec <= 0 {
+			c.Stocks[i].AnalysisTimeoutSec = 300 // 默认5分钟
+		}
 	}
 
 	if enabledCount == 0 {
@@ -159,6 +550,73 @@ func (c *StockConfig) Validate() error {
 		c.LogDir = "stock_analysis_logs"
 	}
 
+	// 设置默认结果存储配置
+	if c.ResultStore.Type == "" {
+		c.ResultStore.Type = "jsonl"
+	}
+	if c.ResultStore.Type != "jsonl" && c.ResultStore.Type != "memory" {
+		return fmt.Errorf("result_store.type必须是 'jsonl' 或 'memory'")
+	}
+	if c.ResultStore.Dir == "" {
+		c.ResultStore.Dir = filepath.Join(c.LogDir, "results")
+	}
+
+	// 设置默认信号跟踪配置
+	if c.SignalTracker.CheckIntervalMinutes <= 0 {
+		c.SignalTracker.CheckIntervalMinutes = 5
+	}
+	if c.SignalTracker.TimeoutDays <= 0 {
+		c.SignalTracker.TimeoutDays = 5
+	}
+	if c.SignalTracker.StoreFile == "" {
+		c.SignalTracker.StoreFile = filepath.Join(c.LogDir, "signal_outcomes.json")
+	}
+	if c.AIConfig.Usage.DailyBudget < 0 {
+		return fmt.Errorf("ai_config.usage.daily_budget不能为负数")
+	}
+	if c.AIConfig.Usage.StoreFile == "" {
+		c.AIConfig.Usage.StoreFile = filepath.Join(c.LogDir, "ai_usage.json")
+	}
+	if c.AIConfig.Usage.KeepDays <= 0 {
+		c.AIConfig.Usage.KeepDays = 90
+	}
+	if dedup := &c.Notification.Dedup; dedup.Enabled {
+		if dedup.CooldownMinutes <= 0 {
+			dedup.CooldownMinutes = 60
+		}
+		if dedup.PriceChangePct <= 0 {
+			dedup.PriceChangePct = 2
+		}
+		if dedup.ConfidenceJump <= 0 {
+			dedup.ConfidenceJump = 10
+		}
+		if dedup.StateFile == "" {
+			dedup.StateFile = filepath.Join(c.LogDir, "notify_state.json")
+		}
+	}
+	if delivery := &c.Notification.Delivery; delivery.Enabled {
+		if delivery.MaxAttempts <= 0 {
+			delivery.MaxAttempts = 5
+		}
+		if delivery.InitialBackoffSeconds <= 0 {
+			delivery.InitialBackoffSeconds = 5
+		}
+		if delivery.MaxBackoffSeconds <= 0 {
+			delivery.MaxBackoffSeconds = 600
+		}
+		if delivery.OutboxDir == "" {
+			delivery.OutboxDir = filepath.Join(c.LogDir, "outbox")
+		}
+		if delivery.RateLimits == nil {
+			delivery.RateLimits = make(map[string]int)
+		}
+		for channel, limit := range defaultRateLimits {
+			if _, ok := delivery.RateLimits[channel]; !ok {
+				delivery.RateLimits[channel] = limit
+			}
+		}
+	}
+
 	// 设置默认交易时间配置
 	if c.TradingTime.Timezone == "" {
 		c.TradingTime.Timezone = "Asia/Shanghai"
@@ -166,11 +624,43 @@ func (c *StockConfig) Validate() error {
 	if len(c.TradingTime.TradingHours) == 0 {
 		c.TradingTime.TradingHours = []string{"09:30-11:30", "13:00-15:00"} // A股默认交易时段
 	}
+	if digest := &c.Notification.Digest; digest.Enabled {
+		if err := c.setDigestDefaults(digest); err != nil {
+			return err
+		}
+	}
 
 	// 验证通知配置
 	if c.Notification.Enabled {
-		if !c.Notification.DingTalk.Enabled && !c.Notification.Feishu.Enabled {
-			return fmt.Errorf("启用通知时至少需要配置一个通知渠道（钉钉或飞书）")
+		n := &c.Notification
+		webhookEnabled := false
+		webhookNames := make(map[string]bool)
+		for i := range n.Webhooks {
+			webhook := &n.Webhooks[i]
+			if !webhook.Enabled {
+				continue
+			}
+			webhookEnabled = true
+			if webhook.Name == "" {
+				webhook.Name = fmt.Sprintf("webhook-%d", i+1)
+			}
+			if webhook.URL == "" {
+				return fmt.Errorf("通用Webhook %s 必须配置url", webhook.Name)
+			}
+			// 名称同时用作投递队列名、路由目标和?channel=过滤条件，必须唯一
+			for _, builtin := range builtinChannelNames {
+				if strings.EqualFold(webhook.Name, builtin) {
+					return fmt.Errorf("通用Webhook名称 %s 与内置通知渠道重名", webhook.Name)
+				}
+			}
+			if webhookNames[webhook.Name] {
+				return fmt.Errorf("通用Webhook名称 %s 重复", webhook.Name)
+			}
+			webhookNames[webhook.Name] = true
+		}
+		if !n.DingTalk.Enabled && !n.Feishu.Enabled && !n.WeCom.Enabled &&
+			!n.Telegram.Enabled && !n.Slack.Enabled && !n.Discord.Enabled && !n.Email.Enabled && !webhookEnabled {
+			return fmt.Errorf("启用通知时至少需要配置一个通知渠道（钉钉、飞书、企业微信、Telegram、Slack、Discord、邮件或通用Webhook）")
 		}
 		if c.Notification.DingTalk.Enabled && c.Notification.DingTalk.WebhookURL == "" {
 			return fmt.Errorf("启用钉钉通知时必须配置webhook_url")
@@ -178,8 +668,149 @@ func (c *StockConfig) Validate() error {
 		if c.Notification.Feishu.Enabled && c.Notification.Feishu.WebhookURL == "" {
 			return fmt.Errorf("启用飞书通知时必须配置webhook_url")
 		}
+		if c.Notification.WeCom.Enabled {
+			wecom := &c.Notification.WeCom
+			if wecom.WebhookURL == "" {
+				return fmt.Errorf("启用企业微信通知时必须配置webhook_url")
+			}
+			if wecom.MessageType == "" {
+				wecom.MessageType = "markdown"
+			}
+			if wecom.MessageType != "markdown" && wecom.MessageType != "template_card" {
+				return fmt.Errorf("notification.wecom.message_type必须是 'markdown' 或 'template_card'")
+			}
+			if wecom.MessageType == "template_card" && wecom.CardURL == "" {
+				return fmt.Errorf("企业微信使用template_card消息时必须配置card_url")
+			}
+		}
+		if n.Telegram.Enabled && (n.Telegram.BotToken == "" || n.Telegram.ChatID == "") {
+			return fmt.Errorf("启用Telegram通知时必须配置bot_token和chat_id")
+		}
+		if n.Slack.Enabled && n.Slack.WebhookURL == "" {
+			return fmt.Errorf("启用Slack通知时必须配置webhook_url")
+		}
+		if n.Discord.Enabled && n.Discord.WebhookURL == "" {
+			return fmt.Errorf("启用Discord通知时必须配置webhook_url")
+		}
+		if n.Email.Enabled {
+			email := &n.Email
+			if email.SMTPHost == "" {
+				return fmt.Errorf("启用邮件通知时必须配置smtp_host")
+			}
+			if len(email.To) == 0 {
+				return fmt.Errorf("启用邮件通知时必须配置至少一个收件人(to)")
+			}
+			if email.From == "" && email.Username == "" {
+				return fmt.Errorf("启用邮件通知时必须配置from或username")
+			}
+			if email.Security == "" {
+				email.Security = "starttls"
+			}
+			if email.SMTPPort == 0 {
+				switch email.Security {
+				case "ssl":
+					email.SMTPPort = 465
+				case "none":
+					email.SMTPPort = 25
+				default:
+					email.SMTPPort = 587
+				}
+			}
+			if email.Security != "starttls" && email.Security != "ssl" && email.Security != "none" {
+				return fmt.Errorf("notification.email.security必须是 'starttls'、'ssl' 或 'none'")
+			}
+		}
+		if err := n.validateRouting(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setDigestDefaults 设置信号汇总默认值并检查时间格式（需在交易时段设置默认值之后调用）
+func (c *StockConfig) setDigestDefaults(digest *DigestConfig) error {
+	if digest.UrgentConfidence <= 0 {
+		digest.UrgentConfidence = 85
+	}
+	if digest.StoreFile == "" {
+		digest.StoreFile = filepath.Join(c.LogDir, "notify_digest.json")
+	}
+	if len(digest.DigestTimes) == 0 {
+		// 默认在每个交易时段结束后5分钟发送汇总（午间休市、收盘）
+		for _, period := range c.TradingTime.TradingHours {
+			parts := strings.SplitN(period, "-", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			end, err := time.Parse("15:04", strings.TrimSpace(parts[1]))
+			if err != nil {
+				return fmt.Errorf("交易时段格式错误: %s", period)
+			}
+			digest.DigestTimes = append(digest.DigestTimes, end.Add(5*time.Minute).Format("15:04"))
+		}
+	}
+
+	for i, t := range digest.DigestTimes {
+		parsed, err := time.Parse("15:04", strings.TrimSpace(t))
+		if err != nil {
+			return fmt.Errorf("notification.digest.digest_times格式错误（应为HH:MM）: %s", t)
+		}
+		digest.DigestTimes[i] = parsed.Format("15:04")
+	}
+	for i, period := range digest.QuietHours {
+		parts := strings.SplitN(period, "-", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("notification.digest.quiet_hours格式错误（应为HH:MM-HH:MM）: %s", period)
+		}
+		start, err1 := time.Parse("15:04", strings.TrimSpace(parts[0]))
+		end, err2 := time.Parse("15:04", strings.TrimSpace(parts[1]))
+		if err1 != nil || err2 != nil || start.Equal(end) {
+			return fmt.Errorf("notification.digest.quiet_hours格式错误（应为HH:MM-HH:MM）: %s", period)
+		}
+		digest.QuietHours[i] = start.Format("15:04") + "-" + end.Format("15:04")
 	}
+	return nil
+}
 
+// validateRouting 检查路由规则引用的渠道均已启用（需在通用Webhook名称设置默认值之后调用）
+func (n *NotificationConfig) validateRouting() error {
+	enabled := make(map[string]bool)
+	for _, channel := range n.EnabledChannels() {
+		enabled[channel] = true
+	}
+	check := func(where string, channel string) error {
+		if !enabled[channel] {
+			return fmt.Errorf("%s引用的通知渠道 %s 不存在或未启用", where, channel)
+		}
+		return nil
+	}
+
+	for _, channel := range n.Routing.DefaultChannels {
+		if err := check("notification.routing.default_channels", channel); err != nil {
+			return err
+		}
+	}
+	for i, rule := range n.Routing.Rules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		where := fmt.Sprintf("路由规则 %s ", name)
+		for _, channel := range rule.Channels {
+			if err := check(where, channel); err != nil {
+				return err
+			}
+		}
+		for channel := range rule.Mentions {
+			if err := check(where+"的mentions", channel); err != nil {
+				return err
+			}
+		}
+		if rule.MaxConfidence > 0 && rule.MaxConfidence < rule.MinConfidence {
+			return fmt.Errorf("%s的max_confidence不能小于min_confidence", where)
+		}
+	}
 	return nil
 }
 
@@ -187,3 +818,22 @@ func (c *StockConfig) Validate() error {
 func (s *StockItem) GetScanInterval() time.Duration {
 	return time.Duration(s.ScanIntervalMinutes) * time.Minute
 }
+
+// GetAnalysisTimeout 获取单次分析超时时间
+func (s *StockItem) GetAnalysisTimeout() time.Duration {
+	return time.Duration(s.AnalysisTimeoutSec) * time.Second
+}
+
+// GetPromptConfig 获取股票使用的提示词模板配置（个股配置优先于全局配置）
+func (c *StockConfig) GetPromptConfig(stock *StockItem) PromptConfig {
+	prompt := c.Prompt
+	if stock.Prompt != nil {
+		if stock.Prompt.SystemTemplate != "" {
+			prompt.SystemTemplate = stock.Prompt.SystemTemplate
+		}
+		if stock.Prompt.UserTemplate != "" {
+			prompt.UserTemplate = stock.Prompt.UserTemplate
+		}
+	}
+	return prompt
+}
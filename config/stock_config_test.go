@@ -0,0 +1,64 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// newTestStockConfig 可以通过Validate的最小配置
+func newTestStockConfig(webhooks ...WebhookConfig) *StockConfig {
+	return &StockConfig{
+		TDXAPIUrl: "http://localhost:8080",
+		AIConfig:  AIConfig{Provider: "ollama", OllamaModel: "qwen2.5"},
+		Stocks:    []StockItem{{Code: "600519", Name: "贵州茅台", Enabled: true}},
+		Notification: NotificationConfig{
+			Enabled:  true,
+			Webhooks: webhooks,
+		},
+	}
+}
+
+func TestValidateWebhookNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		webhooks []WebhookConfig
+		wantErr  string
+	}{
+		{"名称唯一", []WebhookConfig{
+			{Name: "oms", Enabled: true, URL: "http://oms"},
+			{Name: "risk", Enabled: true, URL: "http://risk"},
+		}, ""},
+		{"名称重复", []WebhookConfig{
+			{Name: "oms", Enabled: true, URL: "http://oms"},
+			{Name: "oms", Enabled: true, URL: "http://oms2"},
+		}, "重复"},
+		{"与默认名称重复", []WebhookConfig{
+			{Enabled: true, URL: "http://a"},
+			{Name: "webhook-1", Enabled: true, URL: "http://b"},
+		}, "重复"},
+		{"与内置渠道重名", []WebhookConfig{
+			{Name: "dingtalk", Enabled: true, URL: "http://oms"},
+		}, "内置通知渠道"},
+		{"内置渠道名不区分大小写", []WebhookConfig{
+			{Name: "Email", Enabled: true, URL: "http://oms"},
+		}, "内置通知渠道"},
+		{"未启用的不检查", []WebhookConfig{
+			{Name: "oms", Enabled: true, URL: "http://oms"},
+			{Name: "oms", URL: "http://oms2"},
+		}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newTestStockConfig(tt.webhooks...).Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v，期望通过", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() = %v，期望包含%q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+// Package jsonfile 状态文件的JSON读取和原子保存
+// 通知去重、信号跟踪、通知汇总、AI用量和投递队列都用它持久化状态，重启后继续
+package jsonfile
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Load 读取path中的JSON到v；path为空或文件不存在时不修改v并返回nil
+func Load(path string, v interface{}) error {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// WriteAtomic 先写同目录下的临时文件并fsync，再重命名覆盖path，
+// 进程崩溃或断电时path要么是旧内容要么是新内容，不会只写了一半；所在目录不存在时自动创建
+func WriteAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmpFile := path + ".tmp"
+	f, err := os.OpenFile(tmpFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, path)
+}
+
+// File 串行化同一状态文件的保存，零值即可使用
+type File struct {
+	mu sync.Mutex
+}
+
+// Save 调用snapshot取得当前状态并以缩进JSON原子写入path，path为空时不保存（只保存在内存中）
+// snapshot在保存锁内调用，调用方在其中持有自己的锁读取状态，保证较新的快照不会被较早的快照覆盖
+func (f *File) Save(path string, snapshot func() ([]byte, error)) error {
+	if path == "" {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	data, err := snapshot()
+	if err != nil {
+		return err
+	}
+	return WriteAtomic(path, data)
+}
+
+// Marshal 以保存状态文件使用的格式（两个空格缩进）序列化v
+func Marshal(v interface{}) ([]byte, error) {
+	return json.MarshalIndent(v, "", "  ")
+}
@@ -0,0 +1,81 @@
+package jsonfile
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "state.json")
+	state := map[string]int{"600519": 3}
+
+	var file File
+	err := file.Save(path, func() ([]byte, error) { return Marshal(state) })
+	if err != nil {
+		t.Fatalf("保存失败: %v", err)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("保存后不应残留临时文件")
+	}
+
+	var loaded map[string]int
+	if err := Load(path, &loaded); err != nil || loaded["600519"] != 3 {
+		t.Errorf("Load() = %v, %v", loaded, err)
+	}
+}
+
+func TestLoadMissingOrEmptyPath(t *testing.T) {
+	loaded := map[string]int{"keep": 1}
+	if err := Load(filepath.Join(t.TempDir(), "missing.json"), &loaded); err != nil || loaded["keep"] != 1 {
+		t.Errorf("文件不存在时应返回nil且不修改v: %v, %v", loaded, err)
+	}
+	if err := Load("", &loaded); err != nil {
+		t.Errorf("path为空时应返回nil: %v", err)
+	}
+
+	var file File
+	called := false
+	if err := file.Save("", func() ([]byte, error) { called = true; return nil, nil }); err != nil || called {
+		t.Errorf("path为空时不应保存")
+	}
+}
+
+func TestLoadCorrupted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	os.WriteFile(path, []byte(`{"600519":`), 0644)
+	var loaded map[string]int
+	if err := Load(path, &loaded); err == nil {
+		t.Errorf("JSON损坏时应返回错误")
+	}
+}
+
+func TestSaveConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	var file File
+	var mu sync.Mutex
+	counter := 0
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mu.Lock()
+			counter++
+			mu.Unlock()
+			file.Save(path, func() ([]byte, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				return Marshal(counter)
+			})
+		}()
+	}
+	wg.Wait()
+
+	var saved int
+	if err := Load(path, &saved); err != nil || saved != 20 {
+		t.Errorf("最后保存的应是最新状态，实际: %d (%v)", saved, err)
+	}
+}
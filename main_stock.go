@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"nofx/api"
@@ -46,13 +48,26 @@ func main() {
 	if err != nil {
 		log.Fatalf("❌ 创建AI客户端失败: %v", err)
 	}
-	log.Printf("✓ AI客户端已初始化 (%s)", strings.ToUpper(cfg.AIConfig.Provider))
+	log.Printf("✓ AI客户端已初始化 (%s)", mcpClient.ProviderName())
+
+	// 创建集成投票
+	ensemble, err := createEnsemble(&cfg.AIConfig)
+	if err != nil {
+		log.Fatalf("❌ 创建集成投票失败: %v", err)
+	}
+	if ensemble != nil {
+		log.Printf("✓ 集成投票已启用 (%s, %s)", ensemble.ProviderName(), ensemble.Voting)
+	}
 
 	// 创建通知器
 	var notif notifier.Notifier
+	var deliveryQueues []*notifier.DeliveryQueue
 	if cfg.Notification.Enabled {
-		notif = createNotifier(&cfg.Notification)
+		notif, deliveryQueues = createNotifier(&cfg.Notification, cfg.Stocks)
 		log.Printf("✓ 通知系统已初始化")
+		if len(deliveryQueues) > 0 {
+			log.Printf("✓ 通知投递队列已启用 (%s)", cfg.Notification.Delivery.OutboxDir)
+		}
 	} else {
 		log.Printf("⏭️  通知系统未启用")
 	}
@@ -77,11 +92,83 @@ func main() {
 		log.Printf("⏭️  交易时间检查未启用（将持续分析）")
 	}
 
+	// 创建信号汇总（低信心信号合并发送、静默时段）
+	var digestNotifier *stock.DigestNotifier
+	if notif != nil && cfg.Notification.Digest.Enabled {
+		digest := cfg.Notification.Digest
+		digestNotifier, err = stock.NewDigestNotifier(notif, tradingTimeChecker, stock.DigestConfig{
+			UrgentConfidence: digest.UrgentConfidence,
+			DigestTimes:      digest.DigestTimes,
+			QuietHours:       digest.QuietHours,
+			StoreFile:        digest.StoreFile,
+		})
+		if err != nil {
+			log.Fatalf("❌ 创建信号汇总失败: %v", err)
+		}
+		notif = digestNotifier
+		log.Printf("✓ 信号汇总已启用 (信心度<%d%%的信号在 %v 合并发送)", digest.UrgentConfidence, digest.DigestTimes)
+		if len(digest.QuietHours) > 0 {
+			log.Printf("  静默时段: %v", digest.QuietHours)
+		}
+	}
+
 	// 创建日志目录
 	if err := os.MkdirAll(cfg.LogDir, 0755); err != nil {
 		log.Printf("⚠️  创建日志目录失败: %v", err)
 	}
 
+	// 创建AI用量统计
+	usageTracker, err := createUsageTracker(&cfg.AIConfig.Usage, notif)
+	if err != nil {
+		log.Fatalf("❌ 创建AI用量统计失败: %v", err)
+	}
+	if tradingTimeChecker != nil {
+		usageTracker.Location = tradingTimeChecker.Location
+	}
+	if cfg.AIConfig.Usage.DailyBudget > 0 {
+		log.Printf("✓ AI用量统计已启用 (每日预算 %.2f, %s)", cfg.AIConfig.Usage.DailyBudget, cfg.AIConfig.Usage.StoreFile)
+	} else {
+		log.Printf("✓ AI用量统计已启用 (%s)", cfg.AIConfig.Usage.StoreFile)
+	}
+
+	// 创建分析结果存储
+	resultStore, err := createResultStore(&cfg.ResultStore)
+	if err != nil {
+		log.Fatalf("❌ 创建分析结果存储失败: %v", err)
+	}
+	log.Printf("✓ 分析结果存储已初始化 (%s)", cfg.ResultStore.Type)
+
+	// 创建信号结果跟踪器
+	var signalTracker *stock.SignalTracker
+	if cfg.SignalTracker.Enabled {
+		signalTracker, err = stock.NewSignalTracker(tdxClient, notif, tradingTimeChecker, stock.SignalTrackerConfig{
+			CheckInterval: time.Duration(cfg.SignalTracker.CheckIntervalMinutes) * time.Minute,
+			TimeoutDays:   cfg.SignalTracker.TimeoutDays,
+			SummaryTime:   cfg.SignalTracker.SummaryTime,
+			StoreFile:     cfg.SignalTracker.StoreFile,
+		})
+		if err != nil {
+			log.Fatalf("❌ 创建信号跟踪器失败: %v", err)
+		}
+		log.Printf("✓ 信号跟踪已启用 (%s)", cfg.SignalTracker.StoreFile)
+	}
+
+	// 创建信号去重器
+	var signalGate *stock.SignalGate
+	if cfg.Notification.Dedup.Enabled {
+		dedup := cfg.Notification.Dedup
+		signalGate, err = stock.NewSignalGate(stock.SignalGateConfig{
+			Cooldown:       time.Duration(dedup.CooldownMinutes) * time.Minute,
+			PriceChangePct: dedup.PriceChangePct,
+			ConfidenceJump: dedup.ConfidenceJump,
+			StateFile:      dedup.StateFile,
+		})
+		if err != nil {
+			log.Fatalf("❌ 创建信号去重器失败: %v", err)
+		}
+		log.Printf("✓ 信号去重已启用 (冷却%d分钟, %s)", dedup.CooldownMinutes, dedup.StateFile)
+	}
+
 	fmt.Println()
 	fmt.Println("📊 监控股票列表:")
 	enabledStocks := []config.StockItem{}
@@ -120,14 +207,33 @@ func main() {
 			ScanInterval:       stockItem.GetScanInterval(),
 			EnableNotification: cfg.Notification.Enabled,
 			MinConfidence:      stockItem.MinConfidence,
+			AnalysisTimeout:    stockItem.GetAnalysisTimeout(),
+		}
+
+		promptConfig := cfg.GetPromptConfig(&stockItem)
+		promptTemplate, err := stock.LoadPromptTemplate(promptConfig.SystemTemplate, promptConfig.UserTemplate)
+		if err != nil {
+			log.Fatalf("❌ 加载%s提示词模板失败: %v", stockItem.Code, err)
 		}
 
 		analyzer := stock.NewStockAnalyzer(tdxClient, mcpClient, notif, analysisConfig, tradingTimeChecker)
+		analyzer.ResultStore = resultStore
+		analyzer.PromptTemplate = promptTemplate
+		analyzer.SignalTracker = signalTracker
+		analyzer.SignalGate = signalGate
+		analyzer.Ensemble = ensemble
+		analyzer.UsageTracker = usageTracker
 		analyzerManager.AddAnalyzer(stockItem.Code, analyzer)
 	}
 
 	// 创建并启动API服务器
 	apiServer := api.NewStockAPIServer(analyzerManager, cfg.APIServerPort)
+	apiServer.SetResultStore(resultStore)
+	jobManager := stock.NewAnalysisJobManager(0)
+	apiServer.SetJobManager(jobManager)
+	apiServer.SetSignalTracker(signalTracker)
+	apiServer.SetDeliveryQueues(deliveryQueues)
+	apiServer.SetUsageTracker(usageTracker)
 	go func() {
 		if err := apiServer.Start(); err != nil {
 			log.Printf("❌ API服务器错误: %v", err)
@@ -143,45 +249,117 @@ func main() {
 	// 启动所有分析器
 	analyzerManager.StartAll()
 
+	// 启动信号跟踪
+	trackerStop := make(chan struct{})
+	if signalTracker != nil {
+		go signalTracker.Start(trackerStop)
+	}
+
+	// 启动通知投递
+	deliveryStop := make(chan struct{})
+	for _, queue := range deliveryQueues {
+		go queue.Start(deliveryStop)
+	}
+
+	// 启动信号汇总
+	digestStop := make(chan struct{})
+	if digestNotifier != nil {
+		go digestNotifier.Start(digestStop)
+	}
+
 	// 等待退出信号
 	<-sigChan
 	fmt.Println()
 	fmt.Println()
 	log.Println("📛 收到退出信号，正在停止所有分析器...")
 	analyzerManager.StopAll()
+	close(trackerStop)
+	jobManager.Shutdown()
+	close(digestStop)
+	close(deliveryStop)
 
 	fmt.Println()
 	fmt.Println("👋 感谢使用AI股票分析系统！")
 }
 
 // createMCPClient 创建MCP客户端
-func createMCPClient(aiConfig *config.AIConfig) (*mcp.Client, error) {
-	client := mcp.New()
-
-	switch aiConfig.Provider {
-	case "deepseek":
-		client.SetDeepSeekAPIKey(aiConfig.DeepSeekKey)
-	case "qwen":
-		client.SetQwenAPIKey(aiConfig.QwenKey, "")
-	case "custom":
-		client.SetCustomAPI(aiConfig.CustomAPIURL, aiConfig.CustomAPIKey, aiConfig.CustomModelName)
+func createMCPClient(aiConfig *config.AIConfig) (mcp.AIClient, error) {
+	return mcp.NewFromConfig(aiConfig)
+}
+
+// createEnsemble 创建集成投票，未启用时返回nil
+func createEnsemble(aiConfig *config.AIConfig) (*stock.Ensemble, error) {
+	if !aiConfig.Ensemble.Enabled {
+		return nil, nil
+	}
+
+	var members []stock.EnsembleMember
+	for _, member := range aiConfig.Ensemble.Members {
+		providerConfig := aiConfig.ProviderConfig(member.Provider)
+		if providerConfig == nil {
+			return nil, fmt.Errorf("提供商 %s 不存在", member.Provider)
+		}
+		client, err := mcp.NewFromProviderConfig(providerConfig)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, stock.EnsembleMember{
+			Name:    member.Provider,
+			Client:  client,
+			Weight:  member.Weight,
+			Samples: member.Samples,
+		})
+	}
+	return stock.NewEnsemble(members, aiConfig.Ensemble.Voting)
+}
+
+// createUsageTracker 创建AI用量统计
+func createUsageTracker(usageConfig *config.AIUsageConfig, notif notifier.Notifier) (*stock.UsageTracker, error) {
+	prices := make(map[string]stock.ModelPrice, len(usageConfig.Prices))
+	for model, price := range usageConfig.Prices {
+		prices[model] = stock.ModelPrice{Input: price.Input, Output: price.Output}
+	}
+	return stock.NewUsageTracker(notif, stock.UsageTrackerConfig{
+		Prices:      prices,
+		DailyBudget: usageConfig.DailyBudget,
+		StoreFile:   usageConfig.StoreFile,
+		KeepDays:    usageConfig.KeepDays,
+	})
+}
+
+// createResultStore 创建分析结果存储
+func createResultStore(storeConfig *config.ResultStoreConfig) (stock.ResultStore, error) {
+	switch storeConfig.Type {
+	case "memory":
+		return stock.NewMemoryResultStore(), nil
+	case "jsonl":
+		return stock.NewJSONLResultStore(storeConfig.Dir)
 	default:
-		return nil, fmt.Errorf("不支持的AI提供商: %s", aiConfig.Provider)
+		return nil, fmt.Errorf("不支持的结果存储类型: %s", storeConfig.Type)
 	}
+}
 
-	return client, nil
+// channelNotifier 带渠道名称的通知器（渠道名称用于投递队列的发件箱文件和限流配置）
+type channelNotifier struct {
+	name     string
+	notifier notifier.Notifier
 }
 
-// createNotifier 创建通知器
-func createNotifier(notifConfig *config.NotificationConfig) notifier.Notifier {
-	var notifiers []notifier.Notifier
+// createNotifier 创建通知器，启用投递队列时同时返回各渠道的队列
+// 配置了路由规则时按规则选择渠道，否则发送到所有渠道
+func createNotifier(notifConfig *config.NotificationConfig, stocks []config.StockItem) (notifier.Notifier, []*notifier.DeliveryQueue) {
+	var notifiers []channelNotifier
 
 	if notifConfig.DingTalk.Enabled {
 		ding := notifier.NewDingTalkNotifier(
 			notifConfig.DingTalk.WebhookURL,
 			notifConfig.DingTalk.Secret,
 		)
-		notifiers = append(notifiers, ding)
+		ding.Keyword = notifConfig.DingTalk.Keyword
+		if ding.Secret != "" && !strings.HasPrefix(ding.Secret, "SEC") {
+			log.Printf("  ⚠️  钉钉secret不是SEC开头的加签密钥，如果机器人使用的是自定义关键词，请改为配置keyword")
+		}
+		notifiers = append(notifiers, channelNotifier{"dingtalk", ding})
 		log.Printf("  ✓ 钉钉通知已启用")
 	}
 
@@ -190,19 +368,183 @@ func createNotifier(notifConfig *config.NotificationConfig) notifier.Notifier {
 			notifConfig.Feishu.WebhookURL,
 			notifConfig.Feishu.Secret,
 		)
-		notifiers = append(notifiers, feishu)
+		notifiers = append(notifiers, channelNotifier{"feishu", feishu})
 		log.Printf("  ✓ 飞书通知已启用")
 	}
 
+	if notifConfig.WeCom.Enabled {
+		wecom := notifier.NewWeComNotifier(
+			notifConfig.WeCom.WebhookURL,
+			notifConfig.WeCom.MentionMobiles,
+		)
+		wecom.MessageType = notifConfig.WeCom.MessageType
+		wecom.CardURL = notifConfig.WeCom.CardURL
+		notifiers = append(notifiers, channelNotifier{"wecom", wecom})
+		log.Printf("  ✓ 企业微信通知已启用")
+	}
+
+	if notifConfig.Telegram.Enabled {
+		telegram := notifier.NewTelegramNotifier(
+			notifConfig.Telegram.BotToken,
+			notifConfig.Telegram.ChatID,
+		)
+		if notifConfig.Telegram.APIURL != "" {
+			telegram.APIURL = notifConfig.Telegram.APIURL
+		}
+		notifiers = append(notifiers, channelNotifier{"telegram", telegram})
+		log.Printf("  ✓ Telegram通知已启用")
+	}
+
+	if notifConfig.Slack.Enabled {
+		notifiers = append(notifiers, channelNotifier{"slack", notifier.NewSlackNotifier(notifConfig.Slack.WebhookURL)})
+		log.Printf("  ✓ Slack通知已启用")
+	}
+
+	if notifConfig.Discord.Enabled {
+		discord := notifier.NewDiscordNotifier(notifConfig.Discord.WebhookURL)
+		discord.Username = notifConfig.Discord.Username
+		notifiers = append(notifiers, channelNotifier{"discord", discord})
+		log.Printf("  ✓ Discord通知已启用")
+	}
+
+	if notifConfig.Email.Enabled {
+		email := notifier.NewEmailNotifier(
+			notifConfig.Email.SMTPHost,
+			notifConfig.Email.SMTPPort,
+			notifConfig.Email.Username,
+			notifConfig.Email.Password,
+			notifConfig.Email.From,
+			notifConfig.Email.To,
+		)
+		email.Security = notifConfig.Email.Security
+		notifiers = append(notifiers, channelNotifier{"email", email})
+		log.Printf("  ✓ 邮件通知已启用（%s:%d，%d个收件人）", notifConfig.Email.SMTPHost, notifConfig.Email.SMTPPort, len(notifConfig.Email.To))
+	}
+
+	for _, webhookConfig := range notifConfig.Webhooks {
+		if !webhookConfig.Enabled {
+			continue
+		}
+		webhook, err := notifier.NewWebhookNotifier(webhookConfig.Name, notifier.WebhookTemplate{
+			URL:         webhookConfig.URL,
+			Method:      webhookConfig.Method,
+			Headers:     webhookConfig.Headers,
+			Body:        webhookConfig.BodyTemplate,
+			MessageBody: webhookConfig.MessageTemplate,
+		})
+		if err != nil {
+			log.Printf("  ❌ %v", err)
+			continue
+		}
+		webhook.Secret = webhookConfig.Secret
+		webhook.SignalsOnly = webhookConfig.SignalsOnly
+		if webhookConfig.SignatureHeader != "" {
+			webhook.SignatureHeader = webhookConfig.SignatureHeader
+		}
+		notifiers = append(notifiers, channelNotifier{webhookConfig.Name, webhook})
+		log.Printf("  ✓ 通用Webhook %s 已启用", webhookConfig.Name)
+	}
+
 	if len(notifiers) == 0 {
-		return nil
+		return nil, nil
+	}
+
+	var queues []*notifier.DeliveryQueue
+	result := make([]channelNotifier, 0, len(notifiers))
+	for _, channel := range notifiers {
+		// 配置了startup_message时发送启动消息，尽早暴露Webhook地址、签名密钥等配置错误
+		if notifConfig.StartupMessage {
+			checkNotifier(channel.notifier)
+		}
+
+		if !notifConfig.Delivery.Enabled {
+			result = append(result, channel)
+			continue
+		}
+		delivery := notifConfig.Delivery
+		queue, err := notifier.NewDeliveryQueue(channel.name, channel.notifier, notifier.DeliveryQueueConfig{
+			MaxAttempts:    delivery.MaxAttempts,
+			InitialBackoff: time.Duration(delivery.InitialBackoffSeconds) * time.Second,
+			MaxBackoff:     time.Duration(delivery.MaxBackoffSeconds) * time.Second,
+			RateLimit:      delivery.RateLimits[channel.name],
+			OutboxDir:      delivery.OutboxDir,
+		})
+		if err != nil {
+			log.Printf("  ❌ 创建%s投递队列失败，将直接发送: %v", channel.name, err)
+			result = append(result, channel)
+			continue
+		}
+		if pending := len(queue.Pending()); pending > 0 {
+			log.Printf("  ↻ %s发件箱中有%d条未完成的通知，将继续投递", channel.name, pending)
+		}
+		queues = append(queues, queue)
+		result = append(result, channelNotifier{channel.name, queue})
+	}
+
+	routing := notifConfig.Routing
+	if len(routing.Rules) > 0 || len(routing.DefaultChannels) > 0 {
+		return createRouter(&routing, stocks, result), queues
+	}
+
+	if len(result) == 1 {
+		return result[0].notifier, queues
+	}
+
+	list := make([]notifier.Notifier, 0, len(result))
+	for _, channel := range result {
+		list = append(list, channel.notifier)
+	}
+	return notifier.NewMultiNotifier(list...), queues
+}
+
+// createRouter 根据路由规则创建通知路由
+func createRouter(routing *config.RoutingConfig, stocks []config.StockItem, channels []channelNotifier) *notifier.Router {
+	stockTags := make(map[string][]string)
+	for _, stockItem := range stocks {
+		if len(stockItem.Tags) > 0 {
+			stockTags[stockItem.Code] = stockItem.Tags
+		}
 	}
 
-	if len(notifiers) == 1 {
-		return notifiers[0]
+	rules := make([]notifier.RouteRule, 0, len(routing.Rules))
+	for _, rule := range routing.Rules {
+		rules = append(rules, notifier.RouteRule{
+			Name:          rule.Name,
+			StockCodes:    rule.Stocks,
+			Tags:          rule.Tags,
+			Signals:       rule.Signals,
+			MinConfidence: rule.MinConfidence,
+			MaxConfidence: rule.MaxConfidence,
+			Channels:      rule.Channels,
+			Mentions:      rule.Mentions,
+			Continue:      rule.Continue,
+		})
 	}
 
-	return notifier.NewMultiNotifier(notifiers...)
+	router := notifier.NewRouter(rules, stockTags)
+	router.DefaultChannels = routing.DefaultChannels
+	for _, channel := range channels {
+		router.AddChannel(channel.name, channel.notifier)
+	}
+	log.Printf("  ✓ 通知路由已启用（%d条规则）", len(rules))
+	return router
+}
+
+// checkNotifier 发送启动消息检查通知渠道配置
+func checkNotifier(n notifier.Notifier) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := notifier.SendMessageContext(ctx, n, "🟢 AI股票分析系统已启动")
+	switch {
+	case err == nil:
+	case errors.Is(err, notifier.ErrSignatureMismatch):
+		log.Printf("  ❌ 通知渠道签名校验失败，请检查secret配置: %v", err)
+	case errors.Is(err, notifier.ErrRateLimited):
+		log.Printf("  ⚠️  通知渠道发送频率超限: %v", err)
+	default:
+		log.Printf("  ❌ 通知渠道测试消息发送失败: %v", err)
+	}
 }
 
 // AnalyzerManager 分析器管理器
@@ -224,7 +566,12 @@ func (m *AnalyzerManager) AddAnalyzer(code string, analyzer *stock.StockAnalyzer
 func (m *AnalyzerManager) GetAnalyzer(code string) interface{} {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
-	return m.analyzers[code]
+	// 不存在时返回nil接口，避免返回包装了nil指针的非nil接口
+	analyzer, ok := m.analyzers[code]
+	if !ok {
+		return nil
+	}
+	return analyzer
 }
 
 // StartAll 启动所有分析器
@@ -0,0 +1,84 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// anthropicVersion Anthropic Messages API版本
+const anthropicVersion = "2023-06-01"
+
+// newAnthropicRequest 构建Anthropic Messages API请求（POST {BaseURL}/messages）
+// system prompt通过顶层system字段传递，认证使用x-api-key请求头
+func (cfg *Client) newAnthropicRequest(ctx context.Context, systemPrompt, userPrompt string) (*http.Request, error) {
+	requestBody := map[string]interface{}{
+		"model":      cfg.Model,
+		"max_tokens": 2000,
+		"messages": []map[string]string{
+			{"role": "user", "content": userPrompt},
+		},
+		"temperature": 0.5,
+	}
+	if systemPrompt != "" {
+		requestBody["system"] = systemPrompt
+	}
+
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求失败: %w", err)
+	}
+
+	url := cfg.BaseURL
+	if !cfg.UseFullURL {
+		url = fmt.Sprintf("%s/messages", cfg.BaseURL)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", cfg.APIKey)
+	req.Header.Set("anthropic-version", anthropicVersion)
+	return req, nil
+}
+
+// parseAnthropicResponse 解析Anthropic Messages API响应，拼接所有text内容块
+func parseAnthropicResponse(body []byte) (string, Usage, error) {
+	var result struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		StopReason string `json:"stop_reason"`
+		Usage      struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+	}
+
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", Usage{}, fmt.Errorf("解析响应失败: %w", err)
+	}
+
+	var text strings.Builder
+	for _, block := range result.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	if text.Len() == 0 {
+		return "", Usage{}, fmt.Errorf("API返回空响应 (stop_reason: %s)", result.StopReason)
+	}
+
+	usage := Usage{
+		PromptTokens:     result.Usage.InputTokens,
+		CompletionTokens: result.Usage.OutputTokens,
+		TotalTokens:      result.Usage.InputTokens + result.Usage.OutputTokens,
+	}
+	return text.String(), usage, nil
+}
@@ -0,0 +1,115 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// newTestAnthropicClient 连接测试服务器的Anthropic客户端，只尝试一次
+func newTestAnthropicClient(url string) *Client {
+	c := New()
+	c.SetAnthropicAPIKey("sk-ant-test")
+	c.BaseURL = url + "/v1"
+	c.MaxAttempts = 1
+	return c
+}
+
+func TestAnthropicRequest(t *testing.T) {
+	server, requests := chatServer(t, http.StatusOK, nil, `{
+		"content": [{"type": "text", "text": "{\"signal\":"}, {"type": "tool_use"}, {"type": "text", "text": "\"HOLD\"}"}],
+		"stop_reason": "end_turn",
+		"usage": {"input_tokens": 1200, "output_tokens": 80}
+	}`)
+
+	resp, err := newTestAnthropicClient(server.URL).ChatContext(context.Background(), "你是股票分析师", "分析600519")
+	if err != nil {
+		t.Fatalf("调用失败: %v", err)
+	}
+	req := <-requests
+
+	if req.Path != "/v1/messages" {
+		t.Errorf("请求路径 = %s，期望/v1/messages", req.Path)
+	}
+	if req.Header.Get("x-api-key") != "sk-ant-test" || req.Header.Get("anthropic-version") != anthropicVersion {
+		t.Errorf("认证请求头错误: x-api-key=%q anthropic-version=%q", req.Header.Get("x-api-key"), req.Header.Get("anthropic-version"))
+	}
+	if req.Header.Get("Authorization") != "" {
+		t.Errorf("Anthropic不应使用Authorization请求头")
+	}
+	if req.Body["system"] != "你是股票分析师" {
+		t.Errorf("system = %v，应通过顶层system字段传递", req.Body["system"])
+	}
+	messages := req.Body["messages"].([]interface{})
+	if len(messages) != 1 || messages[0].(map[string]interface{})["role"] != "user" {
+		t.Errorf("messages中只应包含user消息: %v", messages)
+	}
+	if req.Body["model"] != "claude-3-5-sonnet-latest" || req.Body["max_tokens"] == nil {
+		t.Errorf("model/max_tokens错误: %v", req.Body)
+	}
+
+	if resp.Content != `{"signal":"HOLD"}` {
+		t.Errorf("Content = %q，应拼接所有text内容块", resp.Content)
+	}
+	if resp.Usage != (Usage{PromptTokens: 1200, CompletionTokens: 80, TotalTokens: 1280}) {
+		t.Errorf("Usage = %+v", resp.Usage)
+	}
+	if resp.Provider != "anthropic" || resp.Model != "claude-3-5-sonnet-latest" {
+		t.Errorf("Provider/Model = %s/%s", resp.Provider, resp.Model)
+	}
+}
+
+func TestAnthropicWithoutSystemPrompt(t *testing.T) {
+	server, requests := chatServer(t, http.StatusOK, nil, `{"content":[{"type":"text","text":"ok"}]}`)
+	if _, err := newTestAnthropicClient(server.URL).ChatContext(context.Background(), "", "hi"); err != nil {
+		t.Fatalf("调用失败: %v", err)
+	}
+	if _, ok := (<-requests).Body["system"]; ok {
+		t.Errorf("system prompt为空时不应发送system字段")
+	}
+}
+
+func TestAnthropicEmptyContent(t *testing.T) {
+	server, _ := chatServer(t, http.StatusOK, nil, `{"content":[],"stop_reason":"max_tokens","usage":{"input_tokens":10,"output_tokens":0}}`)
+	_, err := newTestAnthropicClient(server.URL).ChatContext(context.Background(), "system", "user")
+	if err == nil || !strings.Contains(err.Error(), "API返回空响应 (stop_reason: max_tokens)") {
+		t.Errorf("空内容时应返回错误并包含stop_reason，实际: %v", err)
+	}
+}
+
+func TestAnthropicErrorStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		retryAfter string
+		retryable  bool
+	}{
+		{"频率超限", http.StatusTooManyRequests, "30", true},
+		{"服务过载", 529, "", true},
+		{"认证失败", http.StatusUnauthorized, "", false},
+		{"参数错误", http.StatusBadRequest, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := chatServer(t, tt.status, map[string]string{"Retry-After": tt.retryAfter},
+				`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`)
+			_, err := newTestAnthropicClient(server.URL).ChatContext(context.Background(), "system", "user")
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status {
+				t.Fatalf("应返回APIError(%d)，实际: %v", tt.status, err)
+			}
+			if apiErr.Retryable() != tt.retryable || isRetryableError(err) != tt.retryable {
+				t.Errorf("Retryable() = %v，期望%v", apiErr.Retryable(), tt.retryable)
+			}
+			if tt.retryAfter != "" && apiErr.RetryAfter.Seconds() != 30 {
+				t.Errorf("RetryAfter = %v，期望30s", apiErr.RetryAfter)
+			}
+			if !strings.Contains(apiErr.Body, "Overloaded") {
+				t.Errorf("错误应包含响应内容: %s", apiErr.Body)
+			}
+		})
+	}
+}
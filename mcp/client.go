@@ -2,11 +2,17 @@ package mcp
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand/v2"
+	"net"
 	"net/http"
+	"nofx/config"
 	"strings"
+	"syscall"
 	"time"
 )
 
@@ -14,13 +20,16 @@ import (
 type Provider string
 
 const (
-	ProviderDeepSeek Provider = "deepseek"
-	ProviderQwen     Provider = "qwen"
-	ProviderCustom   Provider = "custom"
+	ProviderDeepSeek  Provider = "deepseek"
+	ProviderQwen      Provider = "qwen"
+	ProviderCustom    Provider = "custom"
+	ProviderAnthropic Provider = "anthropic" // Anthropic Messages API
+	ProviderOllama    Provider = "ollama"    // Ollama原生 /api/chat 接口（本地部署，无需密钥）
 )
 
 // Client AI API配置
 type Client struct {
+	Name       string // 显示名称（用于日志和分析结果），为空时使用Provider
 	Provider   Provider
 	APIKey     string
 	SecretKey  string // 阿里云需要
@@ -28,6 +37,12 @@ type Client struct {
 	Model      string
 	Timeout    time.Duration
 	UseFullURL bool // 是否使用完整URL（不添加/chat/completions）
+
+	MaxAttempts    int           // 最多尝试次数（含首次），<=0时为3
+	InitialBackoff time.Duration // 首次重试等待，之后指数增长并加随机抖动，<=0时为2秒
+	MaxBackoff     time.Duration // 最长重试等待，服务端Retry-After超过该值时不再重试，<=0时为30秒
+
+	limiter chan struct{} // 并发请求名额，为nil时不限制（通过SetMaxConcurrency设置）
 }
 
 func New() *Client {
@@ -41,6 +56,75 @@ func New() *Client {
 	return &defaultClient
 }
 
+// NewFromConfig 根据配置文件中的AI配置创建客户端
+// 只配置了一个提供商时返回该提供商的Client，配置多个时返回按顺序故障转移的FallbackClient
+func NewFromConfig(aiConfig *config.AIConfig) (AIClient, error) {
+	providers := aiConfig.Providers
+	if len(providers) == 0 {
+		// 未经Validate的旧版单提供商配置
+		providers = []config.AIProviderConfig{aiConfig.LegacyProvider()}
+	}
+
+	clients := make([]AIClient, 0, len(providers))
+	for i := range providers {
+		client, err := NewFromProviderConfig(&providers[i])
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, client)
+	}
+	if len(clients) == 1 {
+		return clients[0], nil
+	}
+	return NewFallbackClient(clients, time.Duration(aiConfig.FailoverCooldownSeconds)*time.Second), nil
+}
+
+// NewFromProviderConfig 根据单个提供商的配置创建客户端
+func NewFromProviderConfig(providerConfig *config.AIProviderConfig) (*Client, error) {
+	client := New()
+
+	switch providerConfig.Provider {
+	case "deepseek":
+		client.SetDeepSeekAPIKey(providerConfig.APIKey)
+	case "qwen":
+		client.SetQwenAPIKey(providerConfig.APIKey, "")
+	case "custom":
+		client.SetCustomAPI(providerConfig.APIURL, providerConfig.APIKey, providerConfig.Model)
+	case "anthropic":
+		client.SetAnthropicAPIKey(providerConfig.APIKey)
+	case "ollama":
+		client.SetOllama(providerConfig.APIURL, providerConfig.Model)
+		client.APIKey = providerConfig.APIKey // 经过反向代理鉴权时使用
+	default:
+		return nil, fmt.Errorf("不支持的AI提供商: %s", providerConfig.Provider)
+	}
+
+	// 内置提供商可覆盖默认地址和模型
+	if providerConfig.Provider != "custom" {
+		if providerConfig.APIURL != "" {
+			client.BaseURL = strings.TrimSuffix(providerConfig.APIURL, "/")
+		}
+		if providerConfig.Model != "" {
+			client.Model = providerConfig.Model
+		}
+	}
+	if providerConfig.TimeoutSeconds > 0 {
+		client.Timeout = time.Duration(providerConfig.TimeoutSeconds) * time.Second
+	}
+	client.MaxAttempts = providerConfig.MaxAttempts
+	client.SetMaxConcurrency(providerConfig.MaxConcurrency)
+	client.Name = providerConfig.Name
+	return client, nil
+}
+
+// ProviderName 返回提供商显示名称
+func (cfg *Client) ProviderName() string {
+	if cfg.Name != "" {
+		return cfg.Name
+	}
+	return string(cfg.Provider)
+}
+
 // SetDeepSeekAPIKey 设置DeepSeek API密钥
 func (cfg *Client) SetDeepSeekAPIKey(apiKey string) {
 	cfg.Provider = ProviderDeepSeek
@@ -76,6 +160,26 @@ func (cfg *Client) SetCustomAPI(apiURL, apiKey, modelName string) {
 	cfg.Timeout = 120 * time.Second
 }
 
+// SetAnthropicAPIKey 设置Anthropic API密钥（使用Messages API）
+func (cfg *Client) SetAnthropicAPIKey(apiKey string) {
+	cfg.Provider = ProviderAnthropic
+	cfg.APIKey = apiKey
+	cfg.BaseURL = "https://api.anthropic.com/v1"
+	cfg.Model = "claude-3-5-sonnet-latest"
+}
+
+// SetOllama 设置本地Ollama服务（使用原生 /api/chat 接口），baseURL为空时为 http://localhost:11434
+func (cfg *Client) SetOllama(baseURL, modelName string) {
+	if baseURL == "" {
+		baseURL = "http://localhost:11434"
+	}
+	cfg.Provider = ProviderOllama
+	cfg.APIKey = ""
+	cfg.BaseURL = strings.TrimSuffix(baseURL, "/")
+	cfg.Model = modelName
+	cfg.Timeout = 300 * time.Second // 本地模型推理较慢
+}
+
 // SetClient 设置完整的AI配置（高级用户）
 func (cfg *Client) SetClient(Client Client) {
 	if Client.Timeout == 0 {
@@ -86,20 +190,36 @@ func (cfg *Client) SetClient(Client Client) {
 
 // CallWithMessages 使用 system + user prompt 调用AI API（推荐）
 func (cfg *Client) CallWithMessages(systemPrompt, userPrompt string) (string, error) {
-	if cfg.APIKey == "" {
-		return "", fmt.Errorf("AI API密钥未设置，请先调用 SetDeepSeekAPIKey() 或 SetQwenAPIKey()")
+	return cfg.CallWithMessagesContext(context.Background(), systemPrompt, userPrompt)
+}
+
+// CallWithMessagesContext 使用 system + user prompt 调用AI API，ctx取消时立即中断请求和重试等待
+func (cfg *Client) CallWithMessagesContext(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	resp, err := cfg.ChatContext(ctx, systemPrompt, userPrompt)
+	if err != nil {
+		return "", err
+	}
+	return resp.Content, nil
+}
+
+// ChatContext 调用AI API并返回响应内容、实际使用的提供商和模型以及token用量
+func (cfg *Client) ChatContext(ctx context.Context, systemPrompt, userPrompt string) (*Response, error) {
+	if cfg.APIKey == "" && cfg.Provider != ProviderOllama {
+		return nil, fmt.Errorf("AI API密钥未设置，请先调用 SetDeepSeekAPIKey() 或 SetQwenAPIKey()")
 	}
 
-	// 重试配置
-	maxRetries := 3
+	maxAttempts := cfg.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 3
+	}
 	var lastErr error
 
-	for attempt := 1; attempt <= maxRetries; attempt++ {
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
 		if attempt > 1 {
-			fmt.Printf("⚠️  AI API调用失败，正在重试 (%d/%d)...\n", attempt, maxRetries)
+			fmt.Printf("⚠️  AI API调用失败，正在重试 (%d/%d)...\n", attempt, maxAttempts)
 		}
 
-		result, err := cfg.callOnce(systemPrompt, userPrompt)
+		result, err := cfg.callOnce(ctx, systemPrompt, userPrompt)
 		if err == nil {
 			if attempt > 1 {
 				fmt.Printf("✓ AI API重试成功\n")
@@ -108,24 +228,168 @@ func (cfg *Client) CallWithMessages(systemPrompt, userPrompt string) (string, er
 		}
 
 		lastErr = err
-		// 如果不是网络错误，不重试
+		// 已取消或超时，不再重试
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("AI API调用已取消: %w", ctx.Err())
+		}
+		// 参数错误、认证失败等不可重试的错误直接返回
 		if !isRetryableError(err) {
-			return "", err
+			return nil, err
 		}
 
 		// 重试前等待
-		if attempt < maxRetries {
-			waitTime := time.Duration(attempt) * 2 * time.Second
-			fmt.Printf("⏳ 等待%v后重试...\n", waitTime)
-			time.Sleep(waitTime)
+		if attempt < maxAttempts {
+			waitTime, ok := cfg.retryWait(attempt, err)
+			// 服务端要求等待过久时直接返回，由调用方（如FallbackClient）切换到其他提供商
+			if !ok {
+				return nil, fmt.Errorf("服务端要求%v后重试，超过最长重试等待%v: %w", waitTime, cfg.maxBackoff(), err)
+			}
+			// 剩余时间不足以等待时直接返回，由调用方（如FallbackClient）尽快切换到其他提供商
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < waitTime {
+				return nil, fmt.Errorf("重试需等待%v，超过剩余超时时间: %w", waitTime, err)
+			}
+			fmt.Printf("⏳ 等待%v后重试...\n", waitTime.Round(100*time.Millisecond))
+			timer := time.NewTimer(waitTime)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, fmt.Errorf("AI API调用已取消: %w", ctx.Err())
+			}
 		}
 	}
 
-	return "", fmt.Errorf("重试%d次后仍然失败: %w", maxRetries, lastErr)
+	return nil, fmt.Errorf("尝试%d次后仍然失败: %w", maxAttempts, lastErr)
+}
+
+// retryWait 计算第attempt次失败后的等待时间
+// 服务端返回Retry-After时按其等待（超过MaxBackoff时返回false，表示不再重试），
+// 否则按指数退避并加随机抖动（在退避时间的50%~100%之间），避免多只股票同时重试
+func (cfg *Client) retryWait(attempt int, err error) (time.Duration, bool) {
+	maxBackoff := cfg.maxBackoff()
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		return apiErr.RetryAfter, apiErr.RetryAfter <= maxBackoff
+	}
+
+	initial := cfg.InitialBackoff
+	if initial <= 0 {
+		initial = 2 * time.Second
+	}
+
+	backoff := initial
+	for i := 1; i < attempt && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	half := backoff / 2
+	return half + rand.N(backoff-half+1), true
+}
+
+// maxBackoff 最长重试等待
+func (cfg *Client) maxBackoff() time.Duration {
+	if cfg.MaxBackoff <= 0 {
+		return 30 * time.Second
+	}
+	return cfg.MaxBackoff
+}
+
+// SetMaxConcurrency 设置同时进行的请求数上限（所有使用该Client的股票共享），n<=0表示不限制
+// 需在开始调用前设置
+func (cfg *Client) SetMaxConcurrency(n int) {
+	if n <= 0 {
+		cfg.limiter = nil
+		return
+	}
+	cfg.limiter = make(chan struct{}, n)
+}
+
+// acquire 获取并发请求名额，返回释放函数
+func (cfg *Client) acquire(ctx context.Context) (func(), error) {
+	if cfg.limiter == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case cfg.limiter <- struct{}{}:
+		return func() { <-cfg.limiter }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("等待AI请求名额时已取消: %w", ctx.Err())
+	}
 }
 
 // callOnce 单次调用AI API（内部使用）
-func (cfg *Client) callOnce(systemPrompt, userPrompt string) (string, error) {
+func (cfg *Client) callOnce(ctx context.Context, systemPrompt, userPrompt string) (*Response, error) {
+	// 按提供商构建请求
+	var req *http.Request
+	var err error
+	switch cfg.Provider {
+	case ProviderAnthropic:
+		req, err = cfg.newAnthropicRequest(ctx, systemPrompt, userPrompt)
+	case ProviderOllama:
+		req, err = cfg.newOllamaRequest(ctx, systemPrompt, userPrompt)
+	default:
+		req, err = cfg.newOpenAIRequest(ctx, systemPrompt, userPrompt)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 发送请求
+	release, err := cfg.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	client := &http.Client{Timeout: cfg.Timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 读取响应
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &APIError{
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+
+	// 解析响应
+	var content string
+	var usage Usage
+	switch cfg.Provider {
+	case ProviderAnthropic:
+		content, usage, err = parseAnthropicResponse(body)
+	case ProviderOllama:
+		content, usage, err = parseOllamaResponse(body)
+	default:
+		content, usage, err = parseOpenAIResponse(body)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Response{
+		Content:  content,
+		Provider: cfg.ProviderName(),
+		Model:    cfg.Model,
+		Usage:    usage,
+	}, nil
+}
+
+// newOpenAIRequest 构建OpenAI兼容的 /chat/completions 请求（DeepSeek、Qwen、自定义API）
+func (cfg *Client) newOpenAIRequest(ctx context.Context, systemPrompt, userPrompt string) (*http.Request, error) {
 	// 构建 messages 数组
 	messages := []map[string]string{}
 
@@ -156,7 +420,7 @@ func (cfg *Client) callOnce(systemPrompt, userPrompt string) (string, error) {
 
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
-		return "", fmt.Errorf("序列化请求失败: %w", err)
+		return nil, fmt.Errorf("序列化请求失败: %w", err)
 	}
 
 	// 创建HTTP请求
@@ -168,9 +432,9 @@ func (cfg *Client) callOnce(systemPrompt, userPrompt string) (string, error) {
 		// 默认行为：添加/chat/completions
 		url = fmt.Sprintf("%s/chat/completions", cfg.BaseURL)
 	}
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", fmt.Errorf("创建请求失败: %w", err)
+		return nil, fmt.Errorf("创建请求失败: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -186,61 +450,49 @@ func (cfg *Client) callOnce(systemPrompt, userPrompt string) (string, error) {
 	default:
 		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", cfg.APIKey))
 	}
+	return req, nil
+}
 
-	// 发送请求
-	client := &http.Client{Timeout: cfg.Timeout}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("发送请求失败: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// 读取响应
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("读取响应失败: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API返回错误 (status %d): %s", resp.StatusCode, string(body))
-	}
-
-	// 解析响应
+// parseOpenAIResponse 解析OpenAI兼容接口的响应
+func parseOpenAIResponse(body []byte) (string, Usage, error) {
 	var result struct {
 		Choices []struct {
 			Message struct {
 				Content string `json:"content"`
 			} `json:"message"`
 		} `json:"choices"`
+		Usage Usage `json:"usage"`
 	}
 
 	if err := json.Unmarshal(body, &result); err != nil {
-		return "", fmt.Errorf("解析响应失败: %w", err)
+		return "", Usage{}, fmt.Errorf("解析响应失败: %w", err)
 	}
 
 	if len(result.Choices) == 0 {
-		return "", fmt.Errorf("API返回空响应")
+		return "", Usage{}, fmt.Errorf("API返回空响应")
 	}
-
-	return result.Choices[0].Message.Content, nil
+	return result.Choices[0].Message.Content, result.Usage, nil
 }
 
 // isRetryableError 判断错误是否可重试
+// 429和500/502/503/504、网络超时、连接被重置或拒绝、DNS解析失败、连接意外断开可以重试
 func isRetryableError(err error) bool {
-	errStr := err.Error()
-	// 网络错误、超时、EOF等可以重试
-	retryableErrors := []string{
-		"EOF",
-		"timeout",
-		"connection reset",
-		"connection refused",
-		"temporary failure",
-		"no such host",
-	}
-	for _, retryable := range retryableErrors {
-		if strings.Contains(errStr, retryable) {
-			return true
-		}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Retryable()
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
 	}
 	return false
 }
@@ -0,0 +1,279 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// newTestClient 连接测试服务器的OpenAI兼容客户端，重试等待为毫秒级
+func newTestClient(url string) *Client {
+	return &Client{
+		Provider:       ProviderCustom,
+		APIKey:         "test-key",
+		BaseURL:        url,
+		Model:          "test-model",
+		Timeout:        5 * time.Second,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     10 * time.Millisecond,
+	}
+}
+
+// chatRequest 测试服务器收到的请求
+type chatRequest struct {
+	Path   string
+	Header http.Header
+	Body   map[string]interface{}
+}
+
+// chatServer 记录请求并返回固定响应的测试AI服务器
+func chatServer(t *testing.T, status int, header map[string]string, response string) (*httptest.Server, <-chan chatRequest) {
+	t.Helper()
+	requests := make(chan chatRequest, 8)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("请求体不是JSON: %v", err)
+		}
+		requests <- chatRequest{Path: r.URL.Path, Header: r.Header, Body: body}
+		for k, v := range header {
+			w.Header().Set(k, v)
+		}
+		w.WriteHeader(status)
+		io.WriteString(w, response)
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+const openAIOK = `{"choices":[{"message":{"content":"ok"}}],"usage":{"prompt_tokens":10,"completion_tokens":2,"total_tokens":12}}`
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		min   time.Duration
+		max   time.Duration
+	}{
+		{"秒数", "30", 30 * time.Second, 30 * time.Second},
+		{"带空格", " 5 ", 5 * time.Second, 5 * time.Second},
+		{"HTTP日期", time.Now().Add(90 * time.Second).UTC().Format(http.TimeFormat), 85 * time.Second, 90 * time.Second},
+		{"过去的日期", time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0, 0},
+		{"零", "0", 0, 0},
+		{"负数", "-5", 0, 0},
+		{"空", "", 0, 0},
+		{"无法解析", "soon", 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRetryAfter(tt.value); got < tt.min || got > tt.max {
+				t.Errorf("parseRetryAfter(%q) = %v，期望在%v~%v之间", tt.value, got, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestIsRetryableError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"429", &APIError{StatusCode: 429}, true},
+		{"500", &APIError{StatusCode: 500}, true},
+		{"502", &APIError{StatusCode: 502}, true},
+		{"503", &APIError{StatusCode: 503}, true},
+		{"504", &APIError{StatusCode: 504}, true},
+		{"529过载", &APIError{StatusCode: 529}, true},
+		{"包装后的429", fmt.Errorf("调用失败: %w", &APIError{StatusCode: 429}), true},
+		{"400", &APIError{StatusCode: 400}, false},
+		{"401", &APIError{StatusCode: 401}, false},
+		{"403", &APIError{StatusCode: 403}, false},
+		{"404", &APIError{StatusCode: 404}, false},
+		{"连接被重置", fmt.Errorf("发送请求失败: %w", syscall.ECONNRESET), true},
+		{"连接被拒绝", fmt.Errorf("发送请求失败: %w", syscall.ECONNREFUSED), true},
+		{"连接意外断开", fmt.Errorf("读取响应失败: %w", io.ErrUnexpectedEOF), true},
+		{"DNS解析失败", &net.DNSError{Err: "no such host", Name: "api.example.com"}, true},
+		{"超时", &net.OpError{Op: "dial", Err: timeoutError{}}, true},
+		{"解析失败", errors.New("解析响应失败"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryableError(tt.err); got != tt.want {
+				t.Errorf("isRetryableError(%v) = %v，期望%v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+// timeoutError 模拟网络超时
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestRetryWait(t *testing.T) {
+	c := &Client{InitialBackoff: 2 * time.Second, MaxBackoff: 30 * time.Second}
+	for attempt, max := range map[int]time.Duration{1: 2 * time.Second, 2: 4 * time.Second, 3: 8 * time.Second, 10: 30 * time.Second} {
+		wait, ok := c.retryWait(attempt, &APIError{StatusCode: 503})
+		if !ok || wait < max/2 || wait > max {
+			t.Errorf("retryWait(%d) = %v, %v，期望在%v~%v之间", attempt, wait, ok, max/2, max)
+		}
+	}
+
+	if wait, ok := c.retryWait(1, &APIError{StatusCode: 429, RetryAfter: 20 * time.Second}); !ok || wait != 20*time.Second {
+		t.Errorf("Retry-After未超过上限时应按其等待，实际: %v, %v", wait, ok)
+	}
+	if _, ok := c.retryWait(1, &APIError{StatusCode: 429, RetryAfter: time.Hour}); ok {
+		t.Errorf("Retry-After超过MaxBackoff时不应重试")
+	}
+	if _, ok := (&Client{}).retryWait(1, &APIError{StatusCode: 429, RetryAfter: time.Minute}); ok {
+		t.Errorf("未设置MaxBackoff时上限为30秒")
+	}
+}
+
+func TestChatContextRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		statuses   []int  // 依次返回的状态码，用完后返回200
+		retryAfter string // 失败响应的Retry-After头
+		wantCalls  int
+		wantStatus int // 期望返回的APIError状态码，0表示成功
+	}{
+		{"503后重试成功", []int{503, 503}, "", 3, 0},
+		{"429按Retry-After等待后成功", []int{429}, "0", 2, 0},
+		{"超过最多尝试次数", []int{500, 500, 500}, "", 3, 500},
+		{"400不重试", []int{400}, "", 1, 400},
+		{"401不重试", []int{401}, "", 1, 401},
+		{"Retry-After过长时直接返回", []int{429}, "120", 1, 429},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(atomic.AddInt32(&calls, 1))
+				if n <= len(tt.statuses) {
+					if tt.retryAfter != "" {
+						w.Header().Set("Retry-After", tt.retryAfter)
+					}
+					w.WriteHeader(tt.statuses[n-1])
+					io.WriteString(w, `{"error":"test"}`)
+					return
+				}
+				io.WriteString(w, openAIOK)
+			}))
+			defer server.Close()
+
+			resp, err := newTestClient(server.URL).ChatContext(context.Background(), "system", "user")
+			if got := int(atomic.LoadInt32(&calls)); got != tt.wantCalls {
+				t.Errorf("请求%d次，期望%d次", got, tt.wantCalls)
+			}
+			if tt.wantStatus == 0 {
+				if err != nil || resp.Content != "ok" {
+					t.Fatalf("期望成功，实际: %v", err)
+				}
+				return
+			}
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.wantStatus {
+				t.Fatalf("期望返回APIError(%d)，实际: %v", tt.wantStatus, err)
+			}
+		})
+	}
+}
+
+func TestFallbackOnLongRetryAfter(t *testing.T) {
+	limited := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer limited.Close()
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, openAIOK)
+	}))
+	defer healthy.Close()
+
+	primary, secondary := newTestClient(limited.URL), newTestClient(healthy.URL)
+	primary.Name, secondary.Name = "primary", "secondary"
+	start := time.Now()
+	resp, err := NewFallbackClient([]AIClient{primary, secondary}, 0).ChatContext(context.Background(), "system", "user")
+	if err != nil || resp.Provider != "secondary" {
+		t.Fatalf("应切换到secondary，实际: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("不应等待Retry-After，实际耗时%v", elapsed)
+	}
+}
+
+func TestMaxConcurrency(t *testing.T) {
+	var inFlight, peak int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		io.WriteString(w, openAIOK)
+	}))
+	defer server.Close()
+
+	c := newTestClient(server.URL)
+	c.SetMaxConcurrency(2)
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.ChatContext(context.Background(), "system", "user"); err != nil {
+				t.Errorf("调用失败: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if peak != 2 {
+		t.Errorf("同时进行的请求最多%d个，期望2个", peak)
+	}
+}
+
+func TestAcquireCancelled(t *testing.T) {
+	c := &Client{}
+	c.SetMaxConcurrency(1)
+	release, err := c.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("获取名额失败: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := c.acquire(ctx); !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "等待AI请求名额") {
+		t.Errorf("名额已满且ctx超时时应返回错误，实际: %v", err)
+	}
+
+	release()
+	if release, err := c.acquire(context.Background()); err != nil {
+		t.Errorf("释放后应能获取名额: %v", err)
+	} else {
+		release()
+	}
+
+	c.SetMaxConcurrency(0)
+	if c.limiter != nil {
+		t.Errorf("n<=0时不应限制并发")
+	}
+}
@@ -0,0 +1,55 @@
+package mcp
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APIError AI API返回的非200响应
+type APIError struct {
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration // 服务端Retry-After头指定的等待时间，未返回时为0
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API返回错误 (status %d): %s", e.StatusCode, strings.TrimSpace(e.Body))
+}
+
+// Retryable 是否可重试：频率超限（429）和服务端临时错误（500/502/503/504，以及Anthropic过载时返回的529）
+func (e *APIError) Retryable() bool {
+	switch e.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+		529:
+		return true
+	default:
+		return false
+	}
+}
+
+// parseRetryAfter 解析Retry-After头（秒数或HTTP日期），无法解析时返回0
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(t); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
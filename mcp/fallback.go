@@ -0,0 +1,149 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AIClient AI调用接口，单个提供商的Client和多提供商的FallbackClient都实现该接口
+type AIClient interface {
+	// ProviderName 提供商显示名称
+	ProviderName() string
+	// ChatContext 使用 system + user prompt 调用AI，ctx取消时立即中断
+	ChatContext(ctx context.Context, systemPrompt, userPrompt string) (*Response, error)
+}
+
+// Response AI调用结果
+type Response struct {
+	Content  string // 模型返回的文本
+	Provider string // 实际产生结果的提供商名称
+	Model    string // 实际使用的模型
+	Usage    Usage  // token用量（接口未返回时为0）
+}
+
+// Usage token用量
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// DefaultFailoverCooldown 提供商调用失败后被降级的默认时长
+const DefaultFailoverCooldown = 5 * time.Minute
+
+// FallbackClient 按顺序尝试多个AI提供商，前一个出错、超时或返回429/5xx时切换到下一个
+// 调用失败的提供商在Cooldown内排到队尾，避免每只股票的分析都先等待已宕机的提供商超时
+type FallbackClient struct {
+	Clients  []AIClient
+	Cooldown time.Duration
+
+	mutex    sync.Mutex
+	failedAt map[int]time.Time // Clients下标 → 最近一次失败时间
+}
+
+// NewFallbackClient 创建多提供商故障转移客户端，cooldown<=0时使用DefaultFailoverCooldown
+func NewFallbackClient(clients []AIClient, cooldown time.Duration) *FallbackClient {
+	if cooldown <= 0 {
+		cooldown = DefaultFailoverCooldown
+	}
+	return &FallbackClient{
+		Clients:  clients,
+		Cooldown: cooldown,
+		failedAt: make(map[int]time.Time),
+	}
+}
+
+// ProviderName 返回按顺序排列的提供商名称，如"deepseek→qwen"
+func (f *FallbackClient) ProviderName() string {
+	names := make([]string, 0, len(f.Clients))
+	for _, client := range f.Clients {
+		names = append(names, client.ProviderName())
+	}
+	return strings.Join(names, "→")
+}
+
+// ChatContext 依次尝试各提供商，返回第一个成功的结果
+// ctx设置了截止时间时，每个提供商最多使用剩余时间除以剩余提供商数，避免一个无响应的提供商耗尽整个分析超时
+func (f *FallbackClient) ChatContext(ctx context.Context, systemPrompt, userPrompt string) (*Response, error) {
+	var errs []error
+	order := f.order()
+	for n, i := range order {
+		client := f.Clients[i]
+		name := client.ProviderName()
+		if n > 0 {
+			log.Printf("↻ 切换到AI提供商 %s", name)
+		}
+
+		attemptCtx, cancel := providerContext(ctx, len(order)-n)
+		resp, err := client.ChatContext(attemptCtx, systemPrompt, userPrompt)
+		cancel()
+		if err == nil {
+			f.markHealthy(i)
+			return resp, nil
+		}
+		// 整个分析已取消或超时，不再尝试其他提供商；仅本提供商的时间片用完时继续切换
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("AI API调用已取消: %w", ctx.Err())
+		}
+
+		log.Printf("⚠️  AI提供商 %s 调用失败: %v", name, err)
+		f.markFailed(i)
+		errs = append(errs, fmt.Errorf("%s: %w", name, err))
+	}
+	return nil, fmt.Errorf("所有AI提供商均调用失败: %w", errors.Join(errs...))
+}
+
+// providerContext 为单个提供商分配时间片：ctx剩余时间平均分给剩余的remaining个提供商，ctx没有截止时间时不限制
+func providerContext(ctx context.Context, remaining int) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok || remaining <= 1 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, time.Until(deadline)/time.Duration(remaining))
+}
+
+// CallWithMessagesContext 依次尝试各提供商，只返回响应内容
+func (f *FallbackClient) CallWithMessagesContext(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	resp, err := f.ChatContext(ctx, systemPrompt, userPrompt)
+	if err != nil {
+		return "", err
+	}
+	return resp.Content, nil
+}
+
+// order 返回本次调用的尝试顺序（Clients下标）：正常的提供商在前，冷却中的提供商在后（均保持配置顺序）
+func (f *FallbackClient) order() []int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	now := time.Now()
+	healthy := make([]int, 0, len(f.Clients))
+	var cooling []int
+	for i := range f.Clients {
+		if failedAt, ok := f.failedAt[i]; ok && now.Sub(failedAt) < f.Cooldown {
+			cooling = append(cooling, i)
+		} else {
+			healthy = append(healthy, i)
+		}
+	}
+	return append(healthy, cooling...)
+}
+
+// markFailed 记录提供商调用失败
+func (f *FallbackClient) markFailed(i int) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.failedAt[i] = time.Now()
+}
+
+// markHealthy 清除提供商的失败记录
+func (f *FallbackClient) markHealthy(i int) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	delete(f.failedAt, i)
+}
@@ -0,0 +1,203 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stubClient 测试用AI提供商：err不为nil时返回该错误，hang为true时一直等待到ctx结束
+type stubClient struct {
+	name     string
+	err      error
+	hang     bool
+	calls    int32
+	deadline time.Duration // 最近一次调用时ctx的剩余时间，没有截止时间时为0
+}
+
+func (s *stubClient) ProviderName() string { return s.name }
+
+func (s *stubClient) ChatContext(ctx context.Context, systemPrompt, userPrompt string) (*Response, error) {
+	atomic.AddInt32(&s.calls, 1)
+	s.deadline = 0
+	if deadline, ok := ctx.Deadline(); ok {
+		s.deadline = time.Until(deadline)
+	}
+	if s.hang {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &Response{Content: "ok", Provider: s.name, Model: s.name + "-model"}, nil
+}
+
+func TestFallbackFailover(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"429", &APIError{StatusCode: 429}},
+		{"503", &APIError{StatusCode: 503}},
+		{"529", &APIError{StatusCode: 529}},
+		{"401", &APIError{StatusCode: 401}},
+		{"网络错误", errors.New("发送请求失败: connection reset by peer")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := &stubClient{name: "deepseek", err: tt.err}
+			secondary := &stubClient{name: "qwen"}
+			f := NewFallbackClient([]AIClient{primary, secondary}, 0)
+
+			resp, err := f.ChatContext(context.Background(), "system", "user")
+			if err != nil {
+				t.Fatalf("应切换到qwen，实际: %v", err)
+			}
+			if resp.Provider != "qwen" || resp.Model != "qwen-model" {
+				t.Errorf("Provider/Model = %s/%s，应记录实际回答的提供商", resp.Provider, resp.Model)
+			}
+			if primary.calls != 1 || secondary.calls != 1 {
+				t.Errorf("调用次数 = %d/%d，期望1/1", primary.calls, secondary.calls)
+			}
+		})
+	}
+}
+
+func TestFallbackFirstSucceeds(t *testing.T) {
+	primary := &stubClient{name: "deepseek"}
+	secondary := &stubClient{name: "qwen"}
+	f := NewFallbackClient([]AIClient{primary, secondary}, 0)
+
+	resp, err := f.ChatContext(context.Background(), "system", "user")
+	if err != nil || resp.Provider != "deepseek" {
+		t.Fatalf("应使用deepseek，实际: %v", err)
+	}
+	if secondary.calls != 0 {
+		t.Errorf("第一个提供商成功时不应调用其他提供商")
+	}
+	if f.ProviderName() != "deepseek→qwen" {
+		t.Errorf("ProviderName() = %s", f.ProviderName())
+	}
+}
+
+func TestFallbackCooldownOrder(t *testing.T) {
+	primary := &stubClient{name: "deepseek", err: &APIError{StatusCode: 503}}
+	secondary := &stubClient{name: "qwen"}
+	third := &stubClient{name: "ollama"}
+	f := NewFallbackClient([]AIClient{primary, secondary, third}, time.Minute)
+
+	if order := f.order(); order[0] != 0 || order[1] != 1 || order[2] != 2 {
+		t.Fatalf("初始顺序 = %v，期望配置顺序", order)
+	}
+	if _, err := f.ChatContext(context.Background(), "system", "user"); err != nil {
+		t.Fatalf("调用失败: %v", err)
+	}
+	// 冷却中的提供商排到队尾，其余保持配置顺序
+	if order := f.order(); order[0] != 1 || order[1] != 2 || order[2] != 0 {
+		t.Errorf("冷却中的顺序 = %v，期望[1 2 0]", order)
+	}
+	if _, err := f.ChatContext(context.Background(), "system", "user"); err != nil {
+		t.Fatalf("调用失败: %v", err)
+	}
+	if primary.calls != 1 || secondary.calls != 2 {
+		t.Errorf("冷却期内不应先调用deepseek: %d/%d", primary.calls, secondary.calls)
+	}
+
+	// 冷却结束后恢复原顺序
+	f.failedAt[0] = time.Now().Add(-2 * time.Minute)
+	if order := f.order(); order[0] != 0 {
+		t.Errorf("冷却结束后顺序 = %v，期望deepseek在前", order)
+	}
+
+	// 冷却中的提供商是唯一可用的时仍会被调用，成功后清除失败记录
+	f.markFailed(0)
+	primary.err = nil
+	secondary.err = errors.New("down")
+	third.err = errors.New("down")
+	resp, err := f.ChatContext(context.Background(), "system", "user")
+	if err != nil || resp.Provider != "deepseek" {
+		t.Fatalf("其他提供商都失败时应调用冷却中的deepseek，实际: %v", err)
+	}
+	if _, ok := f.failedAt[0]; ok {
+		t.Errorf("调用成功后应清除失败记录")
+	}
+	if order := f.order(); order[0] != 0 {
+		t.Errorf("恢复后顺序 = %v，期望deepseek在前", order)
+	}
+}
+
+func TestFallbackTimeoutFailsOver(t *testing.T) {
+	primary := &stubClient{name: "deepseek", hang: true}
+	secondary := &stubClient{name: "qwen"}
+	f := NewFallbackClient([]AIClient{primary, secondary}, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 400*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	resp, err := f.ChatContext(ctx, "system", "user")
+	if err != nil || resp.Provider != "qwen" {
+		t.Fatalf("第一个提供商超时后应切换到qwen，实际: %v", err)
+	}
+	// 两个提供商平分剩余时间
+	if primary.deadline <= 0 || primary.deadline > 200*time.Millisecond {
+		t.Errorf("deepseek的时间片 = %v，期望不超过剩余时间的一半", primary.deadline)
+	}
+	if secondary.deadline < 150*time.Millisecond {
+		t.Errorf("最后一个提供商应获得全部剩余时间，实际%v", secondary.deadline)
+	}
+	if elapsed := time.Since(start); elapsed > 350*time.Millisecond {
+		t.Errorf("耗时%v，超时的提供商不应占用全部时间", elapsed)
+	}
+	if _, ok := f.failedAt[0]; !ok {
+		t.Errorf("超时的提供商应进入冷却")
+	}
+}
+
+func TestFallbackWithoutDeadline(t *testing.T) {
+	primary := &stubClient{name: "deepseek"}
+	f := NewFallbackClient([]AIClient{primary, &stubClient{name: "qwen"}}, 0)
+	if _, err := f.ChatContext(context.Background(), "system", "user"); err != nil {
+		t.Fatalf("调用失败: %v", err)
+	}
+	if primary.deadline != 0 {
+		t.Errorf("ctx没有截止时间时不应限制单个提供商，实际%v", primary.deadline)
+	}
+}
+
+func TestFallbackParentCancelled(t *testing.T) {
+	primary := &stubClient{name: "deepseek", hang: true}
+	secondary := &stubClient{name: "qwen"}
+	f := NewFallbackClient([]AIClient{primary, secondary}, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	_, err := f.ChatContext(ctx, "system", "user")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("整个分析取消时应返回context.Canceled，实际: %v", err)
+	}
+	if secondary.calls != 0 {
+		t.Errorf("已取消时不应尝试其他提供商")
+	}
+	if _, ok := f.failedAt[0]; ok {
+		t.Errorf("取消不是提供商的故障，不应进入冷却")
+	}
+}
+
+func TestFallbackAllFailed(t *testing.T) {
+	f := NewFallbackClient([]AIClient{
+		&stubClient{name: "deepseek", err: &APIError{StatusCode: 429, Body: "rate limited"}},
+		&stubClient{name: "qwen", err: errors.New("连接超时")},
+	}, 0)
+	_, err := f.ChatContext(context.Background(), "system", "user")
+	if err == nil || !strings.Contains(err.Error(), "deepseek: ") || !strings.Contains(err.Error(), "qwen: 连接超时") {
+		t.Fatalf("错误应包含各提供商的失败原因，实际: %v", err)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 429 {
+		t.Errorf("应能通过errors.As取得APIError: %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// newOllamaRequest 构建Ollama原生聊天请求（POST {BaseURL}/api/chat，非流式）
+// 本地部署无需认证；配置了APIKey时（如经过反向代理）使用Bearer认证
+func (cfg *Client) newOllamaRequest(ctx context.Context, systemPrompt, userPrompt string) (*http.Request, error) {
+	messages := []map[string]string{}
+	if systemPrompt != "" {
+		messages = append(messages, map[string]string{
+			"role":    "system",
+			"content": systemPrompt,
+		})
+	}
+	messages = append(messages, map[string]string{
+		"role":    "user",
+		"content": userPrompt,
+	})
+
+	requestBody := map[string]interface{}{
+		"model":    cfg.Model,
+		"messages": messages,
+		"stream":   false,
+		"options": map[string]interface{}{
+			"temperature": 0.5,
+			"num_predict": 2000,
+		},
+	}
+
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求失败: %w", err)
+	}
+
+	url := cfg.BaseURL
+	if !cfg.UseFullURL {
+		url = fmt.Sprintf("%s/api/chat", cfg.BaseURL)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if cfg.APIKey != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", cfg.APIKey))
+	}
+	return req, nil
+}
+
+// parseOllamaResponse 解析Ollama /api/chat 响应，token用量取prompt_eval_count和eval_count
+func parseOllamaResponse(body []byte) (string, Usage, error) {
+	var result struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+		Error           string `json:"error"`
+		PromptEvalCount int    `json:"prompt_eval_count"`
+		EvalCount       int    `json:"eval_count"`
+	}
+
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", Usage{}, fmt.Errorf("解析响应失败: %w", err)
+	}
+	if result.Error != "" {
+		return "", Usage{}, fmt.Errorf("Ollama返回错误: %s", result.Error)
+	}
+	if result.Message.Content == "" {
+		return "", Usage{}, fmt.Errorf("API返回空响应")
+	}
+
+	usage := Usage{
+		PromptTokens:     result.PromptEvalCount,
+		CompletionTokens: result.EvalCount,
+		TotalTokens:      result.PromptEvalCount + result.EvalCount,
+	}
+	return result.Message.Content, usage, nil
+}
@@ -0,0 +1,117 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// newTestOllamaClient 连接测试服务器的Ollama客户端，只尝试一次
+func newTestOllamaClient(url string) *Client {
+	c := New()
+	c.SetOllama(url+"/", "qwen2.5:14b")
+	c.MaxAttempts = 1
+	return c
+}
+
+func TestOllamaRequest(t *testing.T) {
+	server, requests := chatServer(t, http.StatusOK, nil, `{
+		"model": "qwen2.5:14b",
+		"message": {"role": "assistant", "content": "{\"signal\":\"HOLD\"}"},
+		"done": true,
+		"prompt_eval_count": 900,
+		"eval_count": 60
+	}`)
+
+	resp, err := newTestOllamaClient(server.URL).ChatContext(context.Background(), "你是股票分析师", "分析600519")
+	if err != nil {
+		t.Fatalf("调用失败: %v", err)
+	}
+	req := <-requests
+
+	if req.Path != "/api/chat" {
+		t.Errorf("请求路径 = %s，期望/api/chat（BaseURL末尾的/应去掉）", req.Path)
+	}
+	if req.Header.Get("Authorization") != "" {
+		t.Errorf("未配置APIKey时不应发送Authorization")
+	}
+	if req.Body["stream"] != false {
+		t.Errorf("stream = %v，应为false", req.Body["stream"])
+	}
+	messages := req.Body["messages"].([]interface{})
+	if len(messages) != 2 || messages[0].(map[string]interface{})["role"] != "system" ||
+		messages[0].(map[string]interface{})["content"] != "你是股票分析师" {
+		t.Errorf("system prompt应作为第一条system消息: %v", messages)
+	}
+	if req.Body["model"] != "qwen2.5:14b" {
+		t.Errorf("model = %v", req.Body["model"])
+	}
+
+	if resp.Content != `{"signal":"HOLD"}` {
+		t.Errorf("Content = %q", resp.Content)
+	}
+	if resp.Usage != (Usage{PromptTokens: 900, CompletionTokens: 60, TotalTokens: 960}) {
+		t.Errorf("Usage = %+v，应取prompt_eval_count和eval_count", resp.Usage)
+	}
+	if resp.Provider != "ollama" {
+		t.Errorf("Provider = %s", resp.Provider)
+	}
+}
+
+func TestOllamaBearerThroughProxy(t *testing.T) {
+	server, requests := chatServer(t, http.StatusOK, nil, `{"message":{"content":"ok"}}`)
+	c := newTestOllamaClient(server.URL)
+	c.APIKey = "proxy-token"
+	if _, err := c.ChatContext(context.Background(), "", "hi"); err != nil {
+		t.Fatalf("调用失败: %v", err)
+	}
+	req := <-requests
+	if req.Header.Get("Authorization") != "Bearer proxy-token" {
+		t.Errorf("配置APIKey时应使用Bearer认证: %q", req.Header.Get("Authorization"))
+	}
+	if messages := req.Body["messages"].([]interface{}); len(messages) != 1 {
+		t.Errorf("system prompt为空时只应发送user消息: %v", messages)
+	}
+}
+
+func TestOllamaErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		response string
+		want     string
+	}{
+		{"空内容", http.StatusOK, `{"message":{"role":"assistant","content":""},"done":true}`, "API返回空响应"},
+		{"返回error字段", http.StatusOK, `{"error":"model 'qwen2.5:14b' not found"}`, "Ollama返回错误: model 'qwen2.5:14b' not found"},
+		{"响应不是JSON", http.StatusOK, `not json`, "解析响应失败"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := chatServer(t, tt.status, nil, tt.response)
+			_, err := newTestOllamaClient(server.URL).ChatContext(context.Background(), "system", "user")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("错误 = %v，期望包含%q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestOllamaErrorStatus(t *testing.T) {
+	for _, tt := range []struct {
+		status    int
+		retryable bool
+	}{
+		{http.StatusTooManyRequests, true},
+		{http.StatusServiceUnavailable, true},
+		{http.StatusNotFound, false},
+	} {
+		server, _ := chatServer(t, tt.status, nil, `{"error":"busy"}`)
+		_, err := newTestOllamaClient(server.URL).ChatContext(context.Background(), "system", "user")
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status || isRetryableError(err) != tt.retryable {
+			t.Errorf("状态码%d: 应返回APIError且可重试=%v，实际: %v", tt.status, tt.retryable, err)
+		}
+	}
+}
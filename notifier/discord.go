@@ -0,0 +1,133 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Discord消息长度限制
+const (
+	discordContentLimit     = 2000 // content最大字符数
+	discordEmbedTitleLimit  = 256  // embed标题最大字符数
+	discordEmbedDescLimit   = 4096 // embed描述最大字符数
+	discordEmbedFieldsLimit = 25   // embed最多字段数
+)
+
+// DiscordNotifier Discord Webhook通知器
+// 文档: https://discord.com/developers/docs/resources/webhook#execute-webhook
+type DiscordNotifier struct {
+	WebhookURL string
+	Username   string // 覆盖Webhook默认显示的名称（可选）
+}
+
+// NewDiscordNotifier 创建Discord通知器
+func NewDiscordNotifier(webhookURL string) *DiscordNotifier {
+	return &DiscordNotifier{
+		WebhookURL: webhookURL,
+	}
+}
+
+// SendSignal 发送交易信号到Discord
+func (d *DiscordNotifier) SendSignal(signal *TradingSignal) error {
+	return d.SendSignalContext(context.Background(), signal)
+}
+
+// SendSignalContext 发送交易信号到Discord（支持取消）
+func (d *DiscordNotifier) SendSignalContext(ctx context.Context, signal *TradingSignal) error {
+	message := map[string]interface{}{
+		"embeds": []map[string]interface{}{d.formatSignalEmbed(signal)},
+	}
+	// embed中的@不会提醒，需放在content中（Discord用户ID，"all"表示@everyone）
+	if len(signal.Mentions) > 0 {
+		mentions := make([]string, 0, len(signal.Mentions))
+		for _, mention := range signal.Mentions {
+			if isMentionAll(mention) {
+				mentions = append(mentions, "@everyone")
+			} else {
+				mentions = append(mentions, fmt.Sprintf("<@%s>", mention))
+			}
+		}
+		message["content"] = strings.Join(mentions, " ")
+	}
+	return d.sendRequest(ctx, message)
+}
+
+// SendMessage 发送普通消息到Discord
+func (d *DiscordNotifier) SendMessage(message string) error {
+	return d.SendMessageContext(context.Background(), message)
+}
+
+// SendMessageContext 发送普通消息到Discord（支持取消）
+func (d *DiscordNotifier) SendMessageContext(ctx context.Context, message string) error {
+	return d.sendRequest(ctx, map[string]interface{}{
+		"content": truncateRunes(message, discordContentLimit),
+	})
+}
+
+// formatSignalEmbed 格式化信号为Discord embed
+func (d *DiscordNotifier) formatSignalEmbed(signal *TradingSignal) map[string]interface{} {
+	fields := []map[string]interface{}{}
+	for _, field := range signalFields(signal) {
+		if len(fields) == discordEmbedFieldsLimit {
+			break
+		}
+		fields = append(fields, map[string]interface{}{
+			"name":   field.Name,
+			"value":  field.Value,
+			"inline": true,
+		})
+	}
+
+	embed := map[string]interface{}{
+		"title":       truncateRunes(signalTitle(signal), discordEmbedTitleLimit),
+		"description": truncateRunes(signal.Reasoning, discordEmbedDescLimit),
+		"color":       signalColor(signal.Signal),
+		"fields":      fields,
+		"footer": map[string]interface{}{
+			"text": "AI股票分析系统",
+		},
+	}
+	if !signal.Timestamp.IsZero() {
+		embed["timestamp"] = signal.Timestamp.Format(time.RFC3339)
+	}
+	return embed
+}
+
+// sendRequest 发送HTTP请求到Discord
+// Discord成功时返回204（无响应体），失败时返回JSON格式的错误信息
+func (d *DiscordNotifier) sendRequest(ctx context.Context, message map[string]interface{}) error {
+	if d.Username != "" {
+		message["username"] = d.Username
+	}
+
+	jsonData, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("序列化消息失败: %w", err)
+	}
+
+	status, body, err := postJSONStatus(ctx, d.WebhookURL, jsonData)
+	if err != nil {
+		return err
+	}
+
+	if status < 200 || status >= 300 {
+		var result struct {
+			Message string `json:"message"`
+		}
+		errMsg := strings.TrimSpace(string(body))
+		if json.Unmarshal(body, &result) == nil && result.Message != "" {
+			errMsg = result.Message
+		}
+		webhookErr := &WebhookError{Channel: "Discord", Code: status, Message: errMsg}
+		if status == http.StatusTooManyRequests {
+			webhookErr.Kind = ErrRateLimited
+		}
+		return webhookErr
+	}
+
+	return nil
+}
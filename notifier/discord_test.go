@@ -0,0 +1,138 @@
+package notifier
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// discordPayload Discord请求体
+type discordPayload struct {
+	Content  string `json:"content"`
+	Username string `json:"username"`
+	Embeds   []struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		Color       int    `json:"color"`
+		Timestamp   string `json:"timestamp"`
+		Fields      []struct {
+			Name   string `json:"name"`
+			Value  string `json:"value"`
+			Inline bool   `json:"inline"`
+		} `json:"fields"`
+		Footer struct {
+			Text string `json:"text"`
+		} `json:"footer"`
+	} `json:"embeds"`
+}
+
+func parseDiscordPayload(t *testing.T, body string) discordPayload {
+	t.Helper()
+	var payload discordPayload
+	if err := json.Unmarshal([]byte(body), &payload); err != nil {
+		t.Fatalf("请求体不是JSON: %v", err)
+	}
+	return payload
+}
+
+func TestDiscordSignalEmbed(t *testing.T) {
+	server, requests := webhookServer(t, http.StatusNoContent, "")
+	d := NewDiscordNotifier(server.URL)
+	d.Username = "AI股票分析"
+	signal := &TradingSignal{
+		StockCode: "600519", StockName: "贵州茅台", Signal: "SELL", Price: 1500, Confidence: 75,
+		StopLoss: 1550, RiskReward: "1:2", Reasoning: "跌破支撑",
+		Timestamp: time.Date(2024, 3, 7, 10, 0, 0, 0, time.UTC),
+		Mentions:  []string{"123456", "@all"},
+	}
+	if err := d.SendSignal(signal); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+
+	payload := parseDiscordPayload(t, (<-requests).Body)
+	if payload.Username != "AI股票分析" {
+		t.Errorf("username = %q", payload.Username)
+	}
+	if payload.Content != "<@123456> @everyone" {
+		t.Errorf("@成员应放在content中: %q", payload.Content)
+	}
+	if len(payload.Embeds) != 1 {
+		t.Fatalf("embeds = %d条", len(payload.Embeds))
+	}
+	embed := payload.Embeds[0]
+	if embed.Title != "⚠️ SELL信号 - 贵州茅台(600519)" || embed.Description != "跌破支撑" {
+		t.Errorf("title/description = %q / %q", embed.Title, embed.Description)
+	}
+	if embed.Color != 0x43A047 {
+		t.Errorf("卖出信号应为绿色，实际%06X", embed.Color)
+	}
+	if embed.Timestamp != "2024-03-07T10:00:00Z" || embed.Footer.Text != "AI股票分析系统" {
+		t.Errorf("timestamp/footer = %q / %q", embed.Timestamp, embed.Footer.Text)
+	}
+	var names []string
+	for _, field := range embed.Fields {
+		names = append(names, field.Name)
+		if !field.Inline {
+			t.Errorf("字段%s应为inline", field.Name)
+		}
+	}
+	if strings.Join(names, ",") != "当前价格,信心度,止损价格,风险回报比" {
+		t.Errorf("字段 = %v", names)
+	}
+}
+
+func TestDiscordTruncation(t *testing.T) {
+	server, requests := webhookServer(t, http.StatusNoContent, "")
+	d := NewDiscordNotifier(server.URL)
+
+	signal := &TradingSignal{StockCode: "600519", StockName: strings.Repeat("名", 300), Signal: "BUY", Reasoning: strings.Repeat("涨", 5000)}
+	if err := d.SendSignal(signal); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	embed := parseDiscordPayload(t, (<-requests).Body).Embeds[0]
+	if n := utf8.RuneCountInString(embed.Title); n != discordEmbedTitleLimit {
+		t.Errorf("标题长度 = %d，期望截断到%d", n, discordEmbedTitleLimit)
+	}
+	if n := utf8.RuneCountInString(embed.Description); n != discordEmbedDescLimit || !strings.HasSuffix(embed.Description, "...") {
+		t.Errorf("描述长度 = %d，期望截断到%d", n, discordEmbedDescLimit)
+	}
+	if embed.Timestamp != "" {
+		t.Errorf("信号没有时间时不应设置timestamp")
+	}
+
+	if err := d.SendMessage(strings.Repeat("长", 3000)); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	if n := utf8.RuneCountInString(parseDiscordPayload(t, (<-requests).Body).Content); n != discordContentLimit {
+		t.Errorf("content长度 = %d，期望截断到%d", n, discordContentLimit)
+	}
+}
+
+func TestDiscordErrors(t *testing.T) {
+	tests := []struct {
+		status      int
+		response    string
+		message     string
+		rateLimited bool
+	}{
+		{http.StatusBadRequest, `{"message":"Cannot send an empty message","code":50006}`, "Cannot send an empty message", false},
+		{http.StatusNotFound, `{"message":"Unknown Webhook","code":10015}`, "Unknown Webhook", false},
+		{http.StatusTooManyRequests, `{"message":"You are being rate limited.","retry_after":1.5}`, "You are being rate limited.", true},
+		{http.StatusBadGateway, `bad gateway`, "bad gateway", false},
+	}
+	for _, tt := range tests {
+		server, _ := webhookServer(t, tt.status, tt.response)
+		err := NewDiscordNotifier(server.URL).SendMessage("test")
+		var webhookErr *WebhookError
+		if !errors.As(err, &webhookErr) || webhookErr.Code != tt.status || webhookErr.Message != tt.message {
+			t.Errorf("状态码%d: 应返回WebhookError(%s)，实际: %v", tt.status, tt.message, err)
+		}
+		if errors.Is(err, ErrRateLimited) != tt.rateLimited {
+			t.Errorf("状态码%d: errors.Is(err, ErrRateLimited)应为%v", tt.status, tt.rateLimited)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMTP连接加密方式
+const (
+	EmailSecurityStartTLS = "starttls" // 明文连接后升级为TLS（通常为587端口）
+	EmailSecuritySSL      = "ssl"      // 直接建立TLS连接（通常为465端口）
+	EmailSecurityNone     = "none"     // 不加密（仅用于本地或内网SMTP服务）
+)
+
+// EmailNotifier SMTP邮件通知器
+// 信号以HTML报告发送，同时附带纯文本版本供不支持HTML的客户端显示
+type EmailNotifier struct {
+	Host          string
+	Port          int
+	Username      string // 为空时不进行SMTP认证
+	Password      string
+	From          string   // 发件人地址，为空时使用Username
+	To            []string // 收件人地址
+	Security      string   // 加密方式：starttls（默认）、ssl、none
+	SubjectPrefix string   // 邮件主题前缀，默认"[AI股票分析]"
+	Timeout       time.Duration
+}
+
+// NewEmailNotifier 创建邮件通知器
+func NewEmailNotifier(host string, port int, username string, password string, from string, to []string) *EmailNotifier {
+	return &EmailNotifier{
+		Host:          host,
+		Port:          port,
+		Username:      username,
+		Password:      password,
+		From:          from,
+		To:            to,
+		Security:      EmailSecurityStartTLS,
+		SubjectPrefix: "[AI股票分析]",
+		Timeout:       30 * time.Second,
+	}
+}
+
+// SendSignal 发送交易信号邮件
+func (e *EmailNotifier) SendSignal(signal *TradingSignal) error {
+	return e.SendSignalContext(context.Background(), signal)
+}
+
+// SendSignalContext 发送交易信号邮件（支持取消）
+func (e *EmailNotifier) SendSignalContext(ctx context.Context, signal *TradingSignal) error {
+	htmlBody, err := e.formatSignalHTML(signal)
+	if err != nil {
+		return err
+	}
+	return e.send(ctx, signalTitle(signal), e.formatSignalText(signal), htmlBody)
+}
+
+// SendMessage 发送普通消息邮件
+func (e *EmailNotifier) SendMessage(message string) error {
+	return e.SendMessageContext(context.Background(), message)
+}
+
+// SendMessageContext 发送普通消息邮件（支持取消），主题取消息第一行
+func (e *EmailNotifier) SendMessageContext(ctx context.Context, message string) error {
+	subject := strings.TrimSpace(strings.SplitN(message, "\n", 2)[0])
+	return e.send(ctx, truncateRunes(subject, 60), message, "")
+}
+
+// formatSignalText 格式化信号为纯文本
+func (e *EmailNotifier) formatSignalText(signal *TradingSignal) string {
+	var b strings.Builder
+	b.WriteString(signalTitle(signal) + "\n\n")
+	for _, field := range signalFields(signal) {
+		b.WriteString(fmt.Sprintf("%s: %s\n", field.Name, field.Value))
+	}
+	b.WriteString(fmt.Sprintf("\n分析原因:\n%s\n", signal.Reasoning))
+	if fields := technicalFields(signal.TechnicalData); len(fields) > 0 {
+		b.WriteString("\n技术指标:\n")
+		for _, field := range fields {
+			b.WriteString(fmt.Sprintf("  %s: %s\n", field.Name, field.Value))
+		}
+	}
+	b.WriteString(fmt.Sprintf("\n时间: %s\n", signal.Timestamp.Format("2006-01-02 15:04:05")))
+	return b.String()
+}
+
+// emailSignalTemplate 信号HTML报告模板（邮件客户端对CSS支持有限，使用内联样式）
+var emailSignalTemplate = template.Must(template.New("signal").Parse(`<!DOCTYPE html>
+<html>
+<body style="margin:0;padding:16px;background:#f5f5f5;font-family:-apple-system,'PingFang SC','Microsoft YaHei',sans-serif;">
+<div style="max-width:640px;margin:0 auto;background:#ffffff;border-radius:8px;overflow:hidden;">
+  <div style="background:{{.Color}};color:#ffffff;padding:16px 20px;font-size:18px;font-weight:bold;">{{.Title}}</div>
+  <div style="padding:20px;">
+    <table style="width:100%;border-collapse:collapse;font-size:14px;">
+      {{- range .Fields}}
+      <tr><td style="padding:6px 0;color:#666666;width:120px;">{{.Name}}</td><td style="padding:6px 0;font-weight:bold;">{{.Value}}</td></tr>
+      {{- end}}
+    </table>
+    <h3 style="font-size:15px;margin:20px 0 8px 0;">分析原因</h3>
+    <div style="font-size:14px;line-height:1.7;white-space:pre-wrap;">{{.Reasoning}}</div>
+    {{- if .Technical}}
+    <h3 style="font-size:15px;margin:20px 0 8px 0;">技术指标</h3>
+    <table style="width:100%;border-collapse:collapse;font-size:13px;">
+      {{- range .Technical}}
+      <tr><td style="padding:5px 8px;border:1px solid #eeeeee;color:#666666;width:160px;">{{.Name}}</td><td style="padding:5px 8px;border:1px solid #eeeeee;">{{.Value}}</td></tr>
+      {{- end}}
+    </table>
+    {{- end}}
+    <p style="font-size:12px;color:#999999;margin-top:20px;">{{.Time}} · AI分析仅供参考，投资有风险，决策需谨慎</p>
+  </div>
+</div>
+</body>
+</html>
+`))
+
+// formatSignalHTML 格式化信号为HTML报告
+func (e *EmailNotifier) formatSignalHTML(signal *TradingSignal) (string, error) {
+	data := struct {
+		Title     string
+		Color     string
+		Fields    []signalField
+		Reasoning string
+		Technical []signalField
+		Time      string
+	}{
+		Title:     signalTitle(signal),
+		Color:     fmt.Sprintf("#%06X", signalColor(signal.Signal)),
+		Fields:    signalFields(signal),
+		Reasoning: signal.Reasoning,
+		Technical: technicalFields(signal.TechnicalData),
+		Time:      signal.Timestamp.Format("2006-01-02 15:04:05"),
+	}
+
+	var buf bytes.Buffer
+	if err := emailSignalTemplate.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("渲染邮件内容失败: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// send 发送邮件，htmlBody为空时只发送纯文本
+func (e *EmailNotifier) send(ctx context.Context, subject string, textBody string, htmlBody string) error {
+	if len(e.To) == 0 {
+		return fmt.Errorf("未配置收件人")
+	}
+	from := e.From
+	if from == "" {
+		from = e.Username
+	}
+
+	msg := e.buildMessage(from, subject, textBody, htmlBody)
+
+	client, err := e.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if e.Username != "" {
+		auth := smtp.PlainAuth("", e.Username, e.Password, e.Host)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("SMTP认证失败: %w", err)
+		}
+	}
+
+	if err := client.Mail(from); err != nil {
+		return fmt.Errorf("设置发件人失败: %w", err)
+	}
+	for _, to := range e.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("设置收件人%s失败: %w", to, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("发送邮件内容失败: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		w.Close()
+		return fmt.Errorf("发送邮件内容失败: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("发送邮件内容失败: %w", err)
+	}
+
+	return client.Quit()
+}
+
+// dial 连接SMTP服务器，按Security建立TLS连接或升级STARTTLS
+func (e *EmailNotifier) dial(ctx context.Context) (*smtp.Client, error) {
+	timeout := e.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	addr := net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+	tlsConfig := &tls.Config{ServerName: e.Host}
+	dialer := &net.Dialer{Deadline: deadline}
+
+	var conn net.Conn
+	var err error
+	if e.Security == EmailSecuritySSL {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("连接SMTP服务器失败: %w", err)
+	}
+	// SMTP会话的读写也受超时和ctx取消控制
+	conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+
+	client, err := smtp.NewClient(&deadlineConn{Conn: conn, stop: stop}, e.Host)
+	if err != nil {
+		stop()
+		conn.Close()
+		return nil, fmt.Errorf("SMTP握手失败: %w", err)
+	}
+
+	if e.Security == "" || e.Security == EmailSecurityStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, fmt.Errorf("SMTP服务器不支持STARTTLS，请将security设置为ssl或none")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("STARTTLS失败: %w", err)
+		}
+	}
+	return client, nil
+}
+
+// deadlineConn 关闭连接时同时注销ctx取消回调
+type deadlineConn struct {
+	net.Conn
+	stop func() bool
+}
+
+// Close 关闭连接
+func (c *deadlineConn) Close() error {
+	c.stop()
+	return c.Conn.Close()
+}
+
+// buildMessage 构建MIME邮件（multipart/alternative：纯文本 + HTML）
+func (e *EmailNotifier) buildMessage(from string, subject string, textBody string, htmlBody string) []byte {
+	if e.SubjectPrefix != "" {
+		subject = e.SubjectPrefix + " " + subject
+	}
+
+	var buf bytes.Buffer
+	writeHeader := func(key, value string) {
+		buf.WriteString(key + ": " + value + "\r\n")
+	}
+	writeHeader("From", from)
+	writeHeader("To", strings.Join(e.To, ", "))
+	writeHeader("Subject", mime.BEncoding.Encode("UTF-8", subject))
+	writeHeader("Date", time.Now().Format(time.RFC1123Z))
+	writeHeader("Message-ID", fmt.Sprintf("<%s@%s>", randomID(), e.Host))
+	writeHeader("MIME-Version", "1.0")
+
+	if htmlBody == "" {
+		writeHeader("Content-Type", "text/plain; charset=UTF-8")
+		writeHeader("Content-Transfer-Encoding", "base64")
+		buf.WriteString("\r\n")
+		writeBase64(&buf, textBody)
+		return buf.Bytes()
+	}
+
+	boundary := "nofx-" + randomID()
+	writeHeader("Content-Type", fmt.Sprintf("multipart/alternative; boundary=%q", boundary))
+	buf.WriteString("\r\n")
+
+	for _, part := range []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain; charset=UTF-8", textBody},
+		{"text/html; charset=UTF-8", htmlBody},
+	} {
+		buf.WriteString("--" + boundary + "\r\n")
+		writeHeader("Content-Type", part.contentType)
+		writeHeader("Content-Transfer-Encoding", "base64")
+		buf.WriteString("\r\n")
+		writeBase64(&buf, part.body)
+	}
+	buf.WriteString("--" + boundary + "--\r\n")
+	return buf.Bytes()
+}
+
+// writeBase64 以每行76个字符写入base64编码的内容
+func writeBase64(buf *bytes.Buffer, body string) {
+	encoded := base64.StdEncoding.EncodeToString([]byte(body))
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded + "\r\n")
+}
+
+// randomID 生成随机标识，用于Message-ID和MIME分隔符
+func randomID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
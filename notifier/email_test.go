@@ -0,0 +1,254 @@
+package notifier
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpSession SMTP替身收到的一封邮件
+type smtpSession struct {
+	Auth string   // AUTH PLAIN解码后的"\x00用户名\x00密码"
+	From string   // MAIL FROM地址
+	To   []string // RCPT TO地址
+	Data string   // DATA内容
+}
+
+// smtpStandIn 进程内最小SMTP服务器：支持EHLO、AUTH PLAIN、MAIL、RCPT、DATA、QUIT，不支持STARTTLS
+// password为空时不校验密码，否则密码不符返回535
+type smtpStandIn struct {
+	listener net.Listener
+	password string
+	sessions chan smtpSession
+}
+
+// newSMTPStandIn 在127.0.0.1随机端口启动SMTP替身
+func newSMTPStandIn(t *testing.T, password string) *smtpStandIn {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("启动SMTP替身失败: %v", err)
+	}
+	s := &smtpStandIn{listener: listener, password: password, sessions: make(chan smtpSession, 4)}
+	go s.serve()
+	t.Cleanup(func() { listener.Close() })
+	return s
+}
+
+// port SMTP替身监听的端口
+func (s *smtpStandIn) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpStandIn) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *smtpStandIn) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	var session smtpSession
+	reply("220 localhost ESMTP stand-in")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch command {
+		case "EHLO", "HELO":
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			fields := strings.Fields(line)
+			decoded, _ := base64.StdEncoding.DecodeString(fields[len(fields)-1])
+			session.Auth = string(decoded)
+			parts := strings.Split(session.Auth, "\x00")
+			if s.password != "" && (len(parts) != 3 || parts[2] != s.password) {
+				reply("535 5.7.8 Authentication credentials invalid")
+				continue
+			}
+			reply("235 2.7.0 Authentication successful")
+		case "MAIL":
+			session.From = strings.Trim(strings.TrimPrefix(line[len("MAIL FROM:"):], " "), "<>")
+			reply("250 OK")
+		case "RCPT":
+			session.To = append(session.To, strings.Trim(strings.TrimPrefix(line[len("RCPT TO:"):], " "), "<>"))
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			session.Data = data.String()
+			s.sessions <- session
+			reply("250 OK queued")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+// newTestEmailNotifier 连接SMTP替身的邮件通知器（不加密）
+func newTestEmailNotifier(s *smtpStandIn, password string) *EmailNotifier {
+	e := NewEmailNotifier("127.0.0.1", s.port(), "bot@example.com", password, "", []string{"a@example.com", "b@example.com"})
+	e.Security = EmailSecurityNone
+	e.Timeout = 5 * time.Second
+	return e
+}
+
+// decodePart 解码base64内容
+func decodePart(t *testing.T, r io.Reader) string {
+	t.Helper()
+	data, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, r))
+	if err != nil {
+		t.Fatalf("解码邮件内容失败: %v", err)
+	}
+	return string(data)
+}
+
+func TestEmailSendSignal(t *testing.T) {
+	server := newSMTPStandIn(t, "secret")
+	e := newTestEmailNotifier(server, "secret")
+
+	signal := &TradingSignal{
+		StockCode: "600519", StockName: "贵州茅台", Signal: "BUY", Price: 1500.5, Confidence: 80,
+		TargetPrice: 1600, StopLoss: 1450, Reasoning: "放量突破<前高>",
+		Timestamp: time.Date(2024, 3, 7, 10, 0, 0, 0, time.UTC),
+	}
+	if err := e.SendSignal(signal); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	session := <-server.sessions
+
+	if session.Auth != "\x00bot@example.com\x00secret" {
+		t.Errorf("AUTH PLAIN = %q", session.Auth)
+	}
+	if session.From != "bot@example.com" {
+		t.Errorf("MAIL FROM = %s，未配置From时应使用Username", session.From)
+	}
+	if strings.Join(session.To, ",") != "a@example.com,b@example.com" {
+		t.Errorf("RCPT TO = %v", session.To)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(session.Data))
+	if err != nil {
+		t.Fatalf("解析邮件失败: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || !strings.HasPrefix(subject, "[AI股票分析] ") || !strings.Contains(subject, "贵州茅台") {
+		t.Errorf("Subject = %q (%v)", subject, err)
+	}
+	if msg.Header.Get("To") != "a@example.com, b@example.com" {
+		t.Errorf("To = %s", msg.Header.Get("To"))
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %s (%v)", msg.Header.Get("Content-Type"), err)
+	}
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	bodies := make(map[string]string)
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("读取MIME分段失败: %v", err)
+		}
+		contentType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		bodies[contentType] = decodePart(t, part)
+	}
+	if text := bodies["text/plain"]; !strings.Contains(text, "1500.50") || !strings.Contains(text, "放量突破<前高>") {
+		t.Errorf("纯文本内容错误: %s", text)
+	}
+	if html := bodies["text/html"]; !strings.Contains(html, "1500.50") || !strings.Contains(html, "放量突破&lt;前高&gt;") {
+		t.Errorf("HTML内容错误或未转义: %s", html)
+	}
+}
+
+func TestEmailSendMessagePlainText(t *testing.T) {
+	server := newSMTPStandIn(t, "")
+	e := newTestEmailNotifier(server, "")
+	e.Username = "" // 不认证
+	e.From = "noreply@example.com"
+
+	if err := e.SendMessage("系统已启动\n监控3只股票"); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	session := <-server.sessions
+	if session.Auth != "" {
+		t.Errorf("未配置Username时不应认证")
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(session.Data))
+	if err != nil {
+		t.Fatalf("解析邮件失败: %v", err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if subject != "[AI股票分析] 系统已启动" {
+		t.Errorf("Subject = %q，应取消息第一行", subject)
+	}
+	if !strings.HasPrefix(msg.Header.Get("Content-Type"), "text/plain") {
+		t.Errorf("Content-Type = %s", msg.Header.Get("Content-Type"))
+	}
+	if body := decodePart(t, msg.Body); body != "系统已启动\n监控3只股票" {
+		t.Errorf("正文 = %q", body)
+	}
+}
+
+func TestEmailAuthFailure(t *testing.T) {
+	server := newSMTPStandIn(t, "secret")
+	e := newTestEmailNotifier(server, "wrong")
+
+	err := e.SendMessage("test")
+	if err == nil || !strings.Contains(err.Error(), "SMTP认证失败") {
+		t.Fatalf("密码错误时应返回认证失败，实际: %v", err)
+	}
+	select {
+	case <-server.sessions:
+		t.Errorf("认证失败后不应发送邮件")
+	default:
+	}
+}
+
+func TestEmailStartTLSUnsupported(t *testing.T) {
+	server := newSMTPStandIn(t, "")
+	e := newTestEmailNotifier(server, "")
+	e.Security = EmailSecurityStartTLS
+
+	err := e.SendMessage("test")
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Fatalf("服务器不支持STARTTLS时应返回错误，实际: %v", err)
+	}
+}
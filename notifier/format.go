@@ -0,0 +1,168 @@
+package notifier
+
+import (
+	"fmt"
+	"nofx/stock/indicators"
+	"strings"
+	"unicode/utf8"
+)
+
+// signalField 信号消息中的一个字段
+type signalField struct {
+	Name  string
+	Value string
+}
+
+// signalFields 信号的价格类字段（当前价格、信心度、目标价、止损价、风险回报比），未设置的字段不返回
+func signalFields(signal *TradingSignal) []signalField {
+	fields := []signalField{
+		{Name: "当前价格", Value: fmt.Sprintf("%.2f元", signal.Price)},
+		{Name: "信心度", Value: fmt.Sprintf("%d%%", signal.Confidence)},
+	}
+	if signal.TargetPrice > 0 {
+		fields = append(fields, signalField{Name: "目标价格", Value: fmt.Sprintf("%.2f元", signal.TargetPrice)})
+	}
+	if signal.StopLoss > 0 {
+		fields = append(fields, signalField{Name: "止损价格", Value: fmt.Sprintf("%.2f元", signal.StopLoss)})
+	}
+	if signal.RiskReward != "" {
+		fields = append(fields, signalField{Name: "风险回报比", Value: signal.RiskReward})
+	}
+	return fields
+}
+
+// technicalFields 技术指标字段，缺失的可选指标不返回
+func technicalFields(t *indicators.TechnicalSnapshot) []signalField {
+	if t == nil {
+		return nil
+	}
+
+	fields := []signalField{}
+	add := func(name string, v *float64, format string) {
+		if v != nil {
+			fields = append(fields, signalField{Name: name, Value: fmt.Sprintf(format, *v)})
+		}
+	}
+
+	add("涨跌幅", t.ChangePercent, "%+.2f%%")
+	fields = append(fields,
+		signalField{Name: "今开/最高/最低", Value: fmt.Sprintf("%.2f / %.2f / %.2f", t.OpenPrice, t.HighPrice, t.LowPrice)},
+		signalField{Name: "昨收", Value: fmt.Sprintf("%.2f", t.PrevClose)},
+		signalField{Name: "成交额", Value: fmt.Sprintf("%.2f万元", t.Amount/10000)},
+	)
+	add("MA5", t.MA5, "%.2f")
+	add("MA10", t.MA10, "%.2f")
+	add("MA20", t.MA20, "%.2f")
+	add("MA60", t.MA60, "%.2f")
+	if t.MACDDIF != nil && t.MACDDEA != nil && t.MACDHist != nil {
+		fields = append(fields, signalField{Name: "MACD(DIF/DEA/柱)", Value: fmt.Sprintf("%.3f / %.3f / %.3f", *t.MACDDIF, *t.MACDDEA, *t.MACDHist)})
+	}
+	if t.KDJK != nil && t.KDJD != nil && t.KDJJ != nil {
+		fields = append(fields, signalField{Name: "KDJ(K/D/J)", Value: fmt.Sprintf("%.2f / %.2f / %.2f", *t.KDJK, *t.KDJD, *t.KDJJ)})
+	}
+	if t.BollUpper != nil && t.BollMid != nil && t.BollLower != nil {
+		fields = append(fields, signalField{Name: "BOLL(上/中/下)", Value: fmt.Sprintf("%.2f / %.2f / %.2f", *t.BollUpper, *t.BollMid, *t.BollLower)})
+	}
+	add("RSI(14)", t.RSI14, "%.2f")
+	add("ATR(14)", t.ATR14, "%.3f")
+	add("CCI(14)", t.CCI14, "%.2f")
+	add("20日波动率", t.Volatility20d, "%.2f%%")
+	add("外盘占比", t.OuterRatio, "%.2f%%")
+	add("买卖盘比", t.BuySellRatio, "%.2f")
+	return fields
+}
+
+// signalTitle 信号消息标题，如"🚀 BUY信号 - 平安银行(000001)"
+func signalTitle(signal *TradingSignal) string {
+	return fmt.Sprintf("%s %s信号 - %s(%s)", signalEmoji(signal.Signal), signal.Signal, signal.StockName, signal.StockCode)
+}
+
+// signalEmoji 信号对应的图标
+func signalEmoji(signal string) string {
+	switch signal {
+	case "BUY":
+		return "🚀"
+	case "SELL":
+		return "⚠️"
+	case "HOLD":
+		return "⏸️"
+	default:
+		return "📊"
+	}
+}
+
+// signalColor 信号对应的RGB颜色（A股习惯：买入红色、卖出绿色）
+func signalColor(signal string) int {
+	switch signal {
+	case "BUY":
+		return 0xE53935
+	case "SELL":
+		return 0x43A047
+	case "HOLD":
+		return 0xFDD835
+	default:
+		return 0x9E9E9E
+	}
+}
+
+// truncateUTF8 按字节数截断字符串，不截断多字节字符
+func truncateUTF8(s string, maxBytes int) string {
+	if len(s) <= maxBytes {
+		return s
+	}
+	const ellipsis = "..."
+	cut := maxBytes - len(ellipsis)
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + ellipsis
+}
+
+// truncateRunes 按字符数截断字符串
+func truncateRunes(s string, maxRunes int) string {
+	if utf8.RuneCountInString(s) <= maxRunes {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:maxRunes-3]) + "..."
+}
+
+// truncateEscaped 转义后截断到不超过maxRunes个字符（含"..."），逐字符转义，不会截断转义实体
+func truncateEscaped(s string, maxRunes int, escape func(string) string) string {
+	escaped := escape(s)
+	if utf8.RuneCountInString(escaped) <= maxRunes {
+		return escaped
+	}
+
+	var b strings.Builder
+	count := 0
+	for _, r := range s {
+		e := escape(string(r))
+		n := utf8.RuneCountInString(e)
+		if count+n > maxRunes-len("...") {
+			break
+		}
+		b.WriteString(e)
+		count += n
+	}
+	return b.String() + "..."
+}
+
+// isMentionAll 是否为@所有人（"all"或"@all"）
+func isMentionAll(mention string) bool {
+	return mention == "all" || mention == "@all"
+}
+
+// splitMentions 拆分@所有人和具体成员
+func splitMentions(mentions []string) (bool, []string) {
+	atAll := false
+	members := []string{}
+	for _, mention := range mentions {
+		if isMentionAll(mention) {
+			atAll = true
+		} else {
+			members = append(members, mention)
+		}
+	}
+	return atAll, members
+}
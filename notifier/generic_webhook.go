@@ -0,0 +1,234 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// DefaultSignatureHeader 通用Webhook签名请求头默认名称
+const DefaultSignatureHeader = "X-Signature-256"
+
+// Webhook事件类型
+const (
+	WebhookEventSignal  = "signal"  // 交易信号
+	WebhookEventMessage = "message" // 普通消息（启动通知、结果汇总等）
+)
+
+// WebhookTemplate 通用Webhook请求模板
+// 各字段均为Go模板（text/template），数据为WebhookEvent
+type WebhookTemplate struct {
+	URL         string            // 请求地址
+	Method      string            // 请求方法，为空时为POST
+	Headers     map[string]string // 请求头，值可使用模板
+	Body        string            // 信号请求体，为空时发送TradingSignal的JSON
+	MessageBody string            // 普通消息请求体，为空时发送{"event","message","timestamp"}
+}
+
+// WebhookEvent 模板数据
+// 内嵌TradingSignal，模板中可直接使用{{.StockCode}}、{{.Price}}等字段；
+// 普通消息只有Message和Timestamp，其余信号字段为零值
+type WebhookEvent struct {
+	TradingSignal
+	Event   string // WebhookEventSignal 或 WebhookEventMessage
+	Message string // 普通消息内容
+}
+
+// WebhookNotifier 通用Webhook通知器
+// 通过模板定义请求方法、请求头和请求体，无需新增通知器类型即可对接任意HTTP系统
+type WebhookNotifier struct {
+	Name            string // 渠道名称，用于日志和错误信息
+	SignalsOnly     bool   // 只推送交易信号，忽略普通消息
+	Secret          string // HMAC-SHA256签名密钥（可选）
+	SignatureHeader string // 签名请求头，默认X-Signature-256，值为"sha256=<十六进制签名>"
+
+	url         *template.Template
+	method      *template.Template
+	headers     map[string]*template.Template
+	body        *template.Template
+	messageBody *template.Template
+}
+
+// webhookTemplateFuncs 模板可用的辅助函数
+var webhookTemplateFuncs = template.FuncMap{
+	// json 将值序列化为JSON，字符串会带引号并转义，适合嵌入JSON请求体
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	// price 格式化价格，保留两位小数
+	"price": func(v float64) string {
+		return fmt.Sprintf("%.2f", v)
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	// default 值为空时使用默认值，如 {{default "N/A" .RiskReward}}
+	"default": func(def string, v string) string {
+		if v == "" {
+			return def
+		}
+		return v
+	},
+}
+
+// NewWebhookNotifier 创建通用Webhook通知器，模板语法错误时返回错误
+func NewWebhookNotifier(name string, tmpl WebhookTemplate) (*WebhookNotifier, error) {
+	if tmpl.URL == "" {
+		return nil, fmt.Errorf("Webhook %s 未配置url", name)
+	}
+	if tmpl.Method == "" {
+		tmpl.Method = http.MethodPost
+	}
+	if tmpl.Body == "" {
+		tmpl.Body = "{{json .TradingSignal}}"
+	}
+	if tmpl.MessageBody == "" {
+		tmpl.MessageBody = `{"event":"message","message":{{json .Message}},"timestamp":{{json .Timestamp}}}`
+	}
+
+	w := &WebhookNotifier{
+		Name:            name,
+		SignatureHeader: DefaultSignatureHeader,
+		headers:         make(map[string]*template.Template),
+	}
+
+	parse := func(field string, text string) (*template.Template, error) {
+		t, err := template.New(field).Funcs(webhookTemplateFuncs).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("Webhook %s 的%s模板解析失败: %w", name, field, err)
+		}
+		return t, nil
+	}
+
+	var err error
+	if w.url, err = parse("url", tmpl.URL); err != nil {
+		return nil, err
+	}
+	if w.method, err = parse("method", tmpl.Method); err != nil {
+		return nil, err
+	}
+	if w.body, err = parse("body", tmpl.Body); err != nil {
+		return nil, err
+	}
+	if w.messageBody, err = parse("message_body", tmpl.MessageBody); err != nil {
+		return nil, err
+	}
+	for key, value := range tmpl.Headers {
+		if w.headers[key], err = parse("header "+key, value); err != nil {
+			return nil, err
+		}
+	}
+
+	return w, nil
+}
+
+// SendSignal 发送交易信号到Webhook
+func (w *WebhookNotifier) SendSignal(signal *TradingSignal) error {
+	return w.SendSignalContext(context.Background(), signal)
+}
+
+// SendSignalContext 发送交易信号到Webhook（支持取消）
+func (w *WebhookNotifier) SendSignalContext(ctx context.Context, signal *TradingSignal) error {
+	return w.sendRequest(ctx, w.body, &WebhookEvent{TradingSignal: *signal, Event: WebhookEventSignal})
+}
+
+// SendMessage 发送普通消息到Webhook
+func (w *WebhookNotifier) SendMessage(message string) error {
+	return w.SendMessageContext(context.Background(), message)
+}
+
+// SendMessageContext 发送普通消息到Webhook（支持取消）
+func (w *WebhookNotifier) SendMessageContext(ctx context.Context, message string) error {
+	if w.SignalsOnly {
+		return nil
+	}
+	event := &WebhookEvent{Event: WebhookEventMessage, Message: message}
+	event.Timestamp = time.Now()
+	return w.sendRequest(ctx, w.messageBody, event)
+}
+
+// render 渲染模板
+func (w *WebhookNotifier) render(t *template.Template, data *WebhookEvent) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("渲染%s模板失败: %w", t.Name(), err)
+	}
+	return buf.String(), nil
+}
+
+// sign 计算请求体的HMAC-SHA256签名
+func (w *WebhookNotifier) sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(w.Secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// sendRequest 渲染模板并发送HTTP请求，2xx以外的状态码视为失败
+func (w *WebhookNotifier) sendRequest(ctx context.Context, bodyTemplate *template.Template, data *WebhookEvent) error {
+	url, err := w.render(w.url, data)
+	if err != nil {
+		return err
+	}
+	method, err := w.render(w.method, data)
+	if err != nil {
+		return err
+	}
+	body, err := w.render(bodyTemplate, data)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(strings.TrimSpace(method)), strings.TrimSpace(url), strings.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, t := range w.headers {
+		value, err := w.render(t, data)
+		if err != nil {
+			return err
+		}
+		req.Header.Set(key, value)
+	}
+	if w.Secret != "" {
+		header := w.SignatureHeader
+		if header == "" {
+			header = DefaultSignatureHeader
+		}
+		req.Header.Set(header, w.sign([]byte(body)))
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		webhookErr := &WebhookError{
+			Channel: w.Name,
+			Code:    resp.StatusCode,
+			Message: truncateRunes(strings.TrimSpace(string(respBody)), 500),
+		}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			webhookErr.Kind = ErrRateLimited
+		}
+		return webhookErr
+	}
+
+	return nil
+}
@@ -0,0 +1,170 @@
+package notifier
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// webhookRequest 测试Webhook服务器收到的请求
+type webhookRequest struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   string
+}
+
+// webhookServer 记录请求并返回指定状态码的测试Webhook服务器
+func webhookServer(t *testing.T, status int, response string) (*httptest.Server, <-chan webhookRequest) {
+	t.Helper()
+	requests := make(chan webhookRequest, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- webhookRequest{Method: r.Method, Path: r.URL.Path, Header: r.Header, Body: string(body)}
+		w.WriteHeader(status)
+		io.WriteString(w, response)
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+func TestWebhookNotifierRendersTemplates(t *testing.T) {
+	server, requests := webhookServer(t, http.StatusAccepted, "")
+	w, err := NewWebhookNotifier("oms", WebhookTemplate{
+		URL:    server.URL + "/signals/{{.StockCode}}",
+		Method: "put",
+		Headers: map[string]string{
+			"Authorization": "Bearer token",
+			"X-Signal":      "{{.Signal}}",
+		},
+		Body: `{"symbol":{{json .StockCode}},"side":"{{lower .Signal}}","px":{{price .Price}},"note":{{json .Reasoning}},"rr":"{{default "N/A" .RiskReward}}"}`,
+	})
+	if err != nil {
+		t.Fatalf("创建Webhook通知器失败: %v", err)
+	}
+	w.Secret = "hmac-key"
+
+	signal := &TradingSignal{StockCode: "600519", Signal: "BUY", Price: 1500.456, Reasoning: `突破"前高"`}
+	if err := w.SendSignal(signal); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	req := <-requests
+
+	if req.Method != http.MethodPut || req.Path != "/signals/600519" {
+		t.Errorf("请求 = %s %s，期望PUT /signals/600519", req.Method, req.Path)
+	}
+	if req.Header.Get("Authorization") != "Bearer token" || req.Header.Get("X-Signal") != "BUY" {
+		t.Errorf("自定义请求头错误: %v", req.Header)
+	}
+	want := `{"symbol":"600519","side":"buy","px":1500.46,"note":"突破\"前高\"","rr":"N/A"}`
+	if req.Body != want {
+		t.Errorf("请求体 = %s\n期望 %s", req.Body, want)
+	}
+	if !json.Valid([]byte(req.Body)) {
+		t.Errorf("请求体不是有效JSON")
+	}
+
+	mac := hmac.New(sha256.New, []byte("hmac-key"))
+	mac.Write([]byte(req.Body))
+	if got := req.Header.Get(DefaultSignatureHeader); got != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+		t.Errorf("签名 = %s", got)
+	}
+}
+
+func TestWebhookNotifierDefaultBodies(t *testing.T) {
+	server, requests := webhookServer(t, http.StatusOK, "")
+	w, err := NewWebhookNotifier("oms", WebhookTemplate{URL: server.URL})
+	if err != nil {
+		t.Fatalf("创建Webhook通知器失败: %v", err)
+	}
+
+	signal := &TradingSignal{StockCode: "600519", Signal: "SELL", Confidence: 75, Timestamp: time.Date(2024, 3, 7, 10, 0, 0, 0, time.UTC)}
+	if err := w.SendSignal(signal); err != nil {
+		t.Fatalf("发送信号失败: %v", err)
+	}
+	req := <-requests
+	var got TradingSignal
+	if err := json.Unmarshal([]byte(req.Body), &got); err != nil || got.StockCode != "600519" || got.Confidence != 75 {
+		t.Errorf("默认信号请求体 = %s (%v)", req.Body, err)
+	}
+	if req.Method != http.MethodPost || req.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("默认请求 = %s %s", req.Method, req.Header.Get("Content-Type"))
+	}
+
+	if err := w.SendMessage("系统已启动"); err != nil {
+		t.Fatalf("发送消息失败: %v", err)
+	}
+	var message map[string]interface{}
+	if err := json.Unmarshal([]byte((<-requests).Body), &message); err != nil || message["event"] != "message" || message["message"] != "系统已启动" {
+		t.Errorf("默认消息请求体 = %v (%v)", message, err)
+	}
+
+	w.SignalsOnly = true
+	if err := w.SendMessage("忽略"); err != nil {
+		t.Fatalf("SignalsOnly时发送消息应直接返回: %v", err)
+	}
+	select {
+	case req := <-requests:
+		t.Errorf("SignalsOnly时不应发送普通消息: %s", req.Body)
+	default:
+	}
+}
+
+func TestWebhookNotifierNon2xx(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		rateLimited bool
+	}{
+		{"服务端错误", http.StatusInternalServerError, false},
+		{"频率限制", http.StatusTooManyRequests, true},
+		{"重定向不视为成功", http.StatusNotModified, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := webhookServer(t, tt.status, "upstream unavailable")
+			w, err := NewWebhookNotifier("oms", WebhookTemplate{URL: server.URL})
+			if err != nil {
+				t.Fatalf("创建Webhook通知器失败: %v", err)
+			}
+
+			err = w.SendSignal(&TradingSignal{StockCode: "600519", Signal: "BUY"})
+			<-requests
+			var webhookErr *WebhookError
+			if !errors.As(err, &webhookErr) || webhookErr.Code != tt.status || webhookErr.Channel != "oms" {
+				t.Fatalf("应返回WebhookError(%d)，实际: %v", tt.status, err)
+			}
+			if tt.status != http.StatusNotModified && !strings.Contains(webhookErr.Message, "upstream unavailable") {
+				t.Errorf("错误信息应包含响应内容: %s", webhookErr.Message)
+			}
+			if errors.Is(err, ErrRateLimited) != tt.rateLimited {
+				t.Errorf("errors.Is(err, ErrRateLimited) = %v，期望%v", !tt.rateLimited, tt.rateLimited)
+			}
+		})
+	}
+}
+
+func TestWebhookNotifierTemplateErrors(t *testing.T) {
+	if _, err := NewWebhookNotifier("oms", WebhookTemplate{}); err == nil {
+		t.Errorf("未配置url时应返回错误")
+	}
+	if _, err := NewWebhookNotifier("oms", WebhookTemplate{URL: "http://localhost", Body: "{{.StockCode"}); err == nil {
+		t.Errorf("模板语法错误时应返回错误")
+	}
+
+	w, err := NewWebhookNotifier("oms", WebhookTemplate{URL: "http://localhost", Body: "{{.NoSuchField}}"})
+	if err != nil {
+		t.Fatalf("创建Webhook通知器失败: %v", err)
+	}
+	if err := w.SendSignal(&TradingSignal{}); err == nil || !strings.Contains(err.Error(), "渲染body模板失败") {
+		t.Errorf("引用不存在的字段时应返回渲染错误，实际: %v", err)
+	}
+}
@@ -0,0 +1,452 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"nofx/jsonfile"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 投递内容类型
+const (
+	DeliverySignal  = "signal"
+	DeliveryMessage = "message"
+)
+
+// ErrDeliveryNotFound 死信记录不存在
+var ErrDeliveryNotFound = errors.New("投递记录不存在")
+
+// Delivery 一条待投递（或投递失败）的通知
+type Delivery struct {
+	ID          string         `json:"id"`
+	Channel     string         `json:"channel"`
+	Kind        string         `json:"kind"` // signal 或 message
+	Signal      *TradingSignal `json:"signal,omitempty"`
+	Message     string         `json:"message,omitempty"`
+	Attempts    int            `json:"attempts"`
+	CreatedAt   time.Time      `json:"created_at"`
+	NextAttempt time.Time      `json:"next_attempt"`
+	LastError   string         `json:"last_error,omitempty"`
+	FailedAt    *time.Time     `json:"failed_at,omitempty"` // 进入死信的时间
+}
+
+// DeliveryQueueConfig 投递队列配置
+type DeliveryQueueConfig struct {
+	MaxAttempts    int           // 最多尝试次数（含首次），默认5
+	InitialBackoff time.Duration // 首次重试等待时间，默认5秒，之后每次翻倍
+	MaxBackoff     time.Duration // 最长重试等待时间，默认10分钟
+	RateLimit      int           // 每分钟最多发送条数，0表示不限制
+	SendTimeout    time.Duration // 单次发送超时，默认30秒
+	OutboxDir      string        // 发件箱目录，为空时只保存在内存中
+	MaxDeadLetters int           // 最多保留的死信条数，默认500
+}
+
+// DeliveryQueue 通知投递队列
+// 包装任意Notifier：发送时先写入发件箱并立即返回，由后台协程按限流发送，
+// 失败后按指数退避重试，超过最大次数（或签名错误等无法通过重试解决的错误）转入死信。
+// 发件箱和死信保存在OutboxDir中，重启后继续投递
+type DeliveryQueue struct {
+	Name     string // 渠道名称，同时用作发件箱文件名
+	Notifier Notifier
+	Config   DeliveryQueueConfig
+
+	mutex   sync.Mutex
+	saveMu  sync.Mutex
+	pending []*Delivery
+	dead    []*Delivery
+	sent    []time.Time // 最近一分钟内的发送时间，用于限流
+	seq     int
+	wake    chan struct{}
+}
+
+// NewDeliveryQueue 创建投递队列，并从OutboxDir恢复未完成的投递
+func NewDeliveryQueue(name string, n Notifier, config DeliveryQueueConfig) (*DeliveryQueue, error) {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 5
+	}
+	if config.InitialBackoff <= 0 {
+		config.InitialBackoff = 5 * time.Second
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = 10 * time.Minute
+	}
+	if config.SendTimeout <= 0 {
+		config.SendTimeout = 30 * time.Second
+	}
+	if config.MaxDeadLetters <= 0 {
+		config.MaxDeadLetters = 500
+	}
+
+	q := &DeliveryQueue{
+		Name:     name,
+		Notifier: n,
+		Config:   config,
+		wake:     make(chan struct{}, 1),
+	}
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// SendSignal 将交易信号加入投递队列
+func (q *DeliveryQueue) SendSignal(signal *TradingSignal) error {
+	return q.SendSignalContext(context.Background(), signal)
+}
+
+// SendSignalContext 将交易信号加入投递队列（入队不会阻塞，ctx仅用于检查是否已取消）
+func (q *DeliveryQueue) SendSignalContext(ctx context.Context, signal *TradingSignal) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	copied := *signal
+	return q.enqueue(&Delivery{Kind: DeliverySignal, Signal: &copied})
+}
+
+// SendMessage 将普通消息加入投递队列
+func (q *DeliveryQueue) SendMessage(message string) error {
+	return q.SendMessageContext(context.Background(), message)
+}
+
+// SendMessageContext 将普通消息加入投递队列（入队不会阻塞，ctx仅用于检查是否已取消）
+func (q *DeliveryQueue) SendMessageContext(ctx context.Context, message string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return q.enqueue(&Delivery{Kind: DeliveryMessage, Message: message})
+}
+
+// enqueue 加入发件箱并唤醒投递协程
+// 发件箱写入失败时通知仍保留在内存中继续投递，只记录日志
+func (q *DeliveryQueue) enqueue(d *Delivery) error {
+	now := time.Now()
+	q.mutex.Lock()
+	q.seq++
+	d.ID = fmt.Sprintf("%s-%d-%d", q.Name, now.UnixNano(), q.seq)
+	d.Channel = q.Name
+	d.CreatedAt = now
+	d.NextAttempt = now
+	q.pending = append(q.pending, d)
+	q.mutex.Unlock()
+
+	q.saveLogged()
+	q.notify()
+	return nil
+}
+
+// notify 唤醒投递协程
+func (q *DeliveryQueue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start 启动投递协程，stopChan关闭时停止（未完成的投递保留在发件箱中）
+func (q *DeliveryQueue) Start(stopChan <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stopChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for {
+		wait := q.nextWait(time.Now())
+		if wait == 0 {
+			q.deliverNext(ctx)
+			continue
+		}
+
+		// 队列为空时只等待新的投递
+		var timer *time.Timer
+		var timerC <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			timerC = timer.C
+		}
+		select {
+		case <-ctx.Done():
+		case <-q.wake:
+		case <-timerC:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// nextWait 距离下一次可以发送的等待时间，0表示可以立即发送，-1表示队列为空
+func (q *DeliveryQueue) nextWait(now time.Time) time.Duration {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if len(q.pending) == 0 {
+		return -1
+	}
+
+	due := q.pending[0].NextAttempt
+	for _, d := range q.pending[1:] {
+		if d.NextAttempt.Before(due) {
+			due = d.NextAttempt
+		}
+	}
+	wait := due.Sub(now)
+
+	// 限流：最近一分钟的发送次数已达上限时，等待最早的一次发送滑出窗口
+	if q.Config.RateLimit > 0 {
+		cutoff := now.Add(-time.Minute)
+		for len(q.sent) > 0 && !q.sent[0].After(cutoff) {
+			q.sent = q.sent[1:]
+		}
+		if len(q.sent) >= q.Config.RateLimit {
+			if limitWait := q.sent[0].Sub(cutoff); limitWait > wait {
+				wait = limitWait
+			}
+		}
+	}
+
+	if wait <= 0 {
+		return 0
+	}
+	return wait
+}
+
+// deliverNext 发送最早到期的一条通知
+func (q *DeliveryQueue) deliverNext(ctx context.Context) {
+	q.mutex.Lock()
+	var d *Delivery
+	for _, candidate := range q.pending {
+		if d == nil || candidate.NextAttempt.Before(d.NextAttempt) {
+			d = candidate
+		}
+	}
+	if d == nil {
+		q.mutex.Unlock()
+		return
+	}
+	q.sent = append(q.sent, time.Now())
+	q.mutex.Unlock()
+
+	sendCtx, cancel := context.WithTimeout(ctx, q.Config.SendTimeout)
+	var err error
+	if d.Kind == DeliverySignal {
+		err = SendSignalContext(sendCtx, q.Notifier, d.Signal)
+	} else {
+		err = SendMessageContext(sendCtx, q.Notifier, d.Message)
+	}
+	cancel()
+
+	// 程序退出导致的中断不计入重试次数
+	if err != nil && ctx.Err() != nil {
+		return
+	}
+
+	q.mutex.Lock()
+	q.remove(d.ID)
+	now := time.Now()
+	if err == nil {
+		q.mutex.Unlock()
+		q.saveLogged()
+		return
+	}
+
+	d.Attempts++
+	d.LastError = err.Error()
+	if d.Attempts >= q.Config.MaxAttempts || errors.Is(err, ErrSignatureMismatch) {
+		d.FailedAt = &now
+		q.dead = append(q.dead, d)
+		if over := len(q.dead) - q.Config.MaxDeadLetters; over > 0 {
+			q.dead = q.dead[over:]
+		}
+		q.mutex.Unlock()
+		log.Printf("❌ %s通知投递失败（已尝试%d次），已转入死信: %v", q.Name, d.Attempts, err)
+		q.saveLogged()
+		return
+	}
+
+	backoff := q.backoff(d.Attempts)
+	if errors.Is(err, ErrRateLimited) && backoff < time.Minute {
+		backoff = time.Minute // 频率超限时至少等待一个限流窗口
+	}
+	d.NextAttempt = now.Add(backoff)
+	q.pending = append(q.pending, d)
+	q.mutex.Unlock()
+
+	log.Printf("⚠️  %s通知发送失败（第%d次），%s后重试: %v", q.Name, d.Attempts, backoff, err)
+	q.saveLogged()
+}
+
+// backoff 第attempts次失败后的重试等待时间（指数退避）
+func (q *DeliveryQueue) backoff(attempts int) time.Duration {
+	backoff := q.Config.InitialBackoff
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= q.Config.MaxBackoff {
+			return q.Config.MaxBackoff
+		}
+	}
+	return backoff
+}
+
+// remove 从待投递列表中移除（调用方需持有锁）
+func (q *DeliveryQueue) remove(id string) {
+	for i, d := range q.pending {
+		if d.ID == id {
+			q.pending = append(q.pending[:i], q.pending[i+1:]...)
+			return
+		}
+	}
+}
+
+// Pending 返回待投递的通知（按创建时间排序）
+func (q *DeliveryQueue) Pending() []Delivery {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return copyDeliveries(q.pending)
+}
+
+// DeadLetters 返回死信（按失败时间倒序）
+func (q *DeliveryQueue) DeadLetters() []Delivery {
+	return MergeDeadLetters(q)
+}
+
+// MergeDeadLetters 合并多个队列的死信，按失败时间倒序（手工编辑等原因缺少failed_at的排在最后）
+func MergeDeadLetters(queues ...*DeliveryQueue) []Delivery {
+	list := []Delivery{}
+	for _, q := range queues {
+		q.mutex.Lock()
+		list = append(list, copyDeliveries(q.dead)...)
+		q.mutex.Unlock()
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return failedAt(list[i]).After(failedAt(list[j]))
+	})
+	return list
+}
+
+// failedAt 进入死信的时间，未记录时为零值
+func failedAt(d Delivery) time.Time {
+	if d.FailedAt == nil {
+		return time.Time{}
+	}
+	return *d.FailedAt
+}
+
+// Retry 将死信重新加入投递队列（重置尝试次数）
+func (q *DeliveryQueue) Retry(id string) error {
+	q.mutex.Lock()
+	var found *Delivery
+	for i, d := range q.dead {
+		if d.ID == id {
+			found = d
+			q.dead = append(q.dead[:i], q.dead[i+1:]...)
+			break
+		}
+	}
+	if found == nil {
+		q.mutex.Unlock()
+		return ErrDeliveryNotFound
+	}
+	found.Attempts = 0
+	found.FailedAt = nil
+	found.NextAttempt = time.Now()
+	q.pending = append(q.pending, found)
+	q.mutex.Unlock()
+
+	q.saveLogged()
+	q.notify()
+	return nil
+}
+
+// copyDeliveries 复制投递记录，按创建时间排序
+func copyDeliveries(list []*Delivery) []Delivery {
+	result := make([]Delivery, 0, len(list))
+	for _, d := range list {
+		result = append(result, *d)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result
+}
+
+// outboxFile 发件箱文件路径
+func (q *DeliveryQueue) outboxFile() string {
+	return filepath.Join(q.Config.OutboxDir, q.fileName()+"_outbox.json")
+}
+
+// deadLetterFile 死信文件路径
+func (q *DeliveryQueue) deadLetterFile() string {
+	return filepath.Join(q.Config.OutboxDir, q.fileName()+"_dead_letters.json")
+}
+
+// fileName 渠道名称中不适合作为文件名的字符替换为下划线
+func (q *DeliveryQueue) fileName() string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|', ' ':
+			return '_'
+		}
+		return r
+	}, q.Name)
+}
+
+// load 从OutboxDir加载发件箱和死信
+func (q *DeliveryQueue) load() error {
+	if q.Config.OutboxDir == "" {
+		return nil
+	}
+	if err := jsonfile.Load(q.outboxFile(), &q.pending); err != nil {
+		return fmt.Errorf("读取%s发件箱失败: %w", q.Name, err)
+	}
+	if err := jsonfile.Load(q.deadLetterFile(), &q.dead); err != nil {
+		return fmt.Errorf("读取%s死信失败: %w", q.Name, err)
+	}
+	return nil
+}
+
+// save 将发件箱和死信写入OutboxDir（两个文件使用同一时刻的快照，避免重启后投递重复或丢失）
+func (q *DeliveryQueue) save() error {
+	if q.Config.OutboxDir == "" {
+		return nil
+	}
+	q.saveMu.Lock()
+	defer q.saveMu.Unlock()
+
+	q.mutex.Lock()
+	pending, err := jsonfile.Marshal(q.pending)
+	if err != nil {
+		q.mutex.Unlock()
+		return err
+	}
+	dead, err := jsonfile.Marshal(q.dead)
+	q.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := jsonfile.WriteAtomic(q.outboxFile(), pending); err != nil {
+		return err
+	}
+	return jsonfile.WriteAtomic(q.deadLetterFile(), dead)
+}
+
+// saveLogged 保存发件箱，失败时只记录日志
+func (q *DeliveryQueue) saveLogged() {
+	if err := q.save(); err != nil {
+		log.Printf("⚠️  保存%s发件箱失败: %v", q.Name, err)
+	}
+}
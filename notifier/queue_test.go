@@ -0,0 +1,243 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubNotifier 测试用通知器：err不为nil时所有发送都返回该错误
+type stubNotifier struct {
+	mu       sync.Mutex
+	err      error
+	messages []string
+	sent     chan string
+}
+
+func (s *stubNotifier) SendSignal(signal *TradingSignal) error {
+	return s.SendMessage(signal.StockCode)
+}
+
+func (s *stubNotifier) SendMessage(message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.messages = append(s.messages, message)
+	if s.sent != nil {
+		s.sent <- message
+	}
+	return nil
+}
+
+// newTestQueue 创建投递队列，不启动投递协程
+func newTestQueue(t *testing.T, n Notifier, config DeliveryQueueConfig) *DeliveryQueue {
+	t.Helper()
+	q, err := NewDeliveryQueue("dingtalk", n, config)
+	if err != nil {
+		t.Fatalf("创建投递队列失败: %v", err)
+	}
+	return q
+}
+
+// makeDue 将所有待投递的通知设为立即到期
+func makeDue(q *DeliveryQueue) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for _, d := range q.pending {
+		d.NextAttempt = time.Now()
+	}
+}
+
+func TestDeliveryQueueBoundedRetries(t *testing.T) {
+	n := &stubNotifier{err: errors.New("connection refused")}
+	q := newTestQueue(t, n, DeliveryQueueConfig{MaxAttempts: 3})
+	q.SendMessage("系统已启动")
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		makeDue(q)
+		q.deliverNext(context.Background())
+	}
+	if pending := q.Pending(); len(pending) != 0 {
+		t.Fatalf("超过最大尝试次数后不应继续重试，仍有%d条", len(pending))
+	}
+	dead := q.DeadLetters()
+	if len(dead) != 1 || dead[0].Attempts != 3 || dead[0].FailedAt == nil || dead[0].LastError != "connection refused" {
+		t.Fatalf("应转入死信并记录尝试次数和错误: %+v", dead)
+	}
+
+	// 重新投递
+	n.err = nil
+	if err := q.Retry(dead[0].ID); err != nil {
+		t.Fatalf("Retry失败: %v", err)
+	}
+	if pending := q.Pending(); len(pending) != 1 || pending[0].Attempts != 0 || pending[0].FailedAt != nil {
+		t.Fatalf("重新投递应重置尝试次数: %+v", pending)
+	}
+	q.deliverNext(context.Background())
+	if len(n.messages) != 1 || len(q.Pending()) != 0 || len(q.DeadLetters()) != 0 {
+		t.Errorf("重新投递后应发送成功: %v", n.messages)
+	}
+	if err := q.Retry("no-such-id"); !errors.Is(err, ErrDeliveryNotFound) {
+		t.Errorf("Retry不存在的记录应返回ErrDeliveryNotFound: %v", err)
+	}
+}
+
+func TestDeliveryQueueSignatureMismatchGoesToDeadLetters(t *testing.T) {
+	n := &stubNotifier{err: &WebhookError{Channel: "dingtalk", Code: 310000, Message: "sign not match", Kind: ErrSignatureMismatch}}
+	q := newTestQueue(t, n, DeliveryQueueConfig{MaxAttempts: 5})
+	q.SendMessage("test")
+	q.deliverNext(context.Background())
+
+	if dead := q.DeadLetters(); len(dead) != 1 || dead[0].Attempts != 1 {
+		t.Fatalf("签名错误无法通过重试解决，应直接转入死信: %+v", dead)
+	}
+}
+
+func TestDeliveryQueueBackoff(t *testing.T) {
+	q := newTestQueue(t, &stubNotifier{}, DeliveryQueueConfig{InitialBackoff: 5 * time.Second, MaxBackoff: time.Minute})
+	for attempts, want := range map[int]time.Duration{1: 5 * time.Second, 2: 10 * time.Second, 3: 20 * time.Second, 4: 40 * time.Second, 5: time.Minute, 10: time.Minute} {
+		if got := q.backoff(attempts); got != want {
+			t.Errorf("backoff(%d) = %v，期望%v", attempts, got, want)
+		}
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want time.Duration
+	}{
+		{"普通错误按退避等待", errors.New("timeout"), 5 * time.Second},
+		{"频率超限至少等待1分钟", &WebhookError{Code: 130101, Kind: ErrRateLimited}, time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newTestQueue(t, &stubNotifier{err: tt.err}, DeliveryQueueConfig{InitialBackoff: 5 * time.Second, MaxBackoff: 10 * time.Minute})
+			q.SendMessage("test")
+			before := time.Now()
+			q.deliverNext(context.Background())
+
+			pending := q.Pending()
+			if len(pending) != 1 || pending[0].Attempts != 1 {
+				t.Fatalf("失败后应保留在发件箱: %+v", pending)
+			}
+			if wait := pending[0].NextAttempt.Sub(before); wait < tt.want || wait > tt.want+time.Second {
+				t.Errorf("重试等待 = %v，期望%v", wait, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeliveryQueueRateLimitWindow(t *testing.T) {
+	q := newTestQueue(t, &stubNotifier{}, DeliveryQueueConfig{RateLimit: 2})
+	if wait := q.nextWait(time.Now()); wait != -1 {
+		t.Errorf("队列为空时nextWait = %v，期望-1", wait)
+	}
+	q.SendMessage("a")
+
+	now := time.Now()
+	q.sent = []time.Time{now.Add(-70 * time.Second), now.Add(-40 * time.Second), now.Add(-10 * time.Second)}
+	// 窗口内2条已达上限，等待40秒前的那条滑出窗口
+	if wait := q.nextWait(now); wait != 20*time.Second {
+		t.Errorf("nextWait = %v，期望20s", wait)
+	}
+	if len(q.sent) != 2 {
+		t.Errorf("滑出窗口的发送记录应被清除，剩余%d条", len(q.sent))
+	}
+	if wait := q.nextWait(now.Add(21 * time.Second)); wait != 0 {
+		t.Errorf("窗口内只剩1条时应立即发送，nextWait = %v", wait)
+	}
+
+	unlimited := newTestQueue(t, &stubNotifier{}, DeliveryQueueConfig{})
+	unlimited.SendMessage("a")
+	now = time.Now()
+	unlimited.sent = []time.Time{now, now, now}
+	if wait := unlimited.nextWait(now); wait != 0 {
+		t.Errorf("RateLimit为0时不限流，nextWait = %v", wait)
+	}
+}
+
+func TestDeliveryQueueOutboxSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	config := DeliveryQueueConfig{OutboxDir: dir, MaxAttempts: 1}
+	failing := &stubNotifier{err: errors.New("down")}
+	q := newTestQueue(t, failing, config)
+	q.SendMessage("失败的消息")
+	q.deliverNext(context.Background())
+	q.SendSignal(&TradingSignal{StockCode: "600519", Signal: "BUY"})
+	q.SendMessage("待发送消息")
+
+	for _, name := range []string{"dingtalk_outbox.json", "dingtalk_dead_letters.json"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("%s未写入: %v", name, err)
+		}
+	}
+
+	n := &stubNotifier{sent: make(chan string, 4)}
+	restarted := newTestQueue(t, n, config)
+	pending := restarted.Pending()
+	if len(pending) != 2 || pending[0].Kind != DeliverySignal || pending[0].Signal.StockCode != "600519" || pending[1].Message != "待发送消息" {
+		t.Fatalf("重启后应恢复发件箱: %+v", pending)
+	}
+	if dead := restarted.DeadLetters(); len(dead) != 1 || dead[0].Message != "失败的消息" {
+		t.Fatalf("重启后应恢复死信: %+v", dead)
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		restarted.Start(stop)
+		close(done)
+	}()
+	for _, want := range []string{"600519", "待发送消息"} {
+		select {
+		case got := <-n.sent:
+			if got != want {
+				t.Errorf("投递顺序错误: %s，期望%s", got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("重启后未继续投递%s", want)
+		}
+	}
+	close(stop)
+	<-done
+
+	// 投递完成后发件箱为空
+	again := newTestQueue(t, &stubNotifier{}, config)
+	if len(again.Pending()) != 0 {
+		t.Errorf("投递成功后不应再留在发件箱")
+	}
+}
+
+func TestDeadLettersMissingFailedAt(t *testing.T) {
+	dir := t.TempDir()
+	// 手工编辑过的死信文件：第二条缺少failed_at
+	data := `[
+		{"id": "a", "channel": "dingtalk", "kind": "message", "message": "a", "failed_at": "2024-03-07T10:00:00Z"},
+		{"id": "b", "channel": "dingtalk", "kind": "message", "message": "b"},
+		{"id": "c", "channel": "dingtalk", "kind": "message", "message": "c", "failed_at": "2024-03-08T10:00:00Z"}
+	]`
+	if err := os.WriteFile(filepath.Join(dir, "dingtalk_dead_letters.json"), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	q := newTestQueue(t, &stubNotifier{}, DeliveryQueueConfig{OutboxDir: dir})
+	other := newTestQueue(t, &stubNotifier{}, DeliveryQueueConfig{})
+	failed := time.Date(2024, 3, 9, 10, 0, 0, 0, time.UTC)
+	other.dead = []*Delivery{{ID: "d", FailedAt: &failed}}
+
+	var ids []string
+	for _, d := range MergeDeadLetters(q, other) {
+		ids = append(ids, d.ID)
+	}
+	if len(ids) != 4 || ids[0] != "d" || ids[1] != "c" || ids[2] != "a" || ids[3] != "b" {
+		t.Errorf("合并后顺序 = %v，期望[d c a b]（缺少failed_at的排在最后）", ids)
+	}
+	if list := MergeDeadLetters(); list == nil || len(list) != 0 {
+		t.Errorf("没有队列时应返回空列表而不是nil")
+	}
+}
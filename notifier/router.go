@@ -0,0 +1,201 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// RouteRule 通知路由规则
+// 各条件之间为"且"，同一条件的多个值之间为"或"，未设置的条件不限制
+type RouteRule struct {
+	Name          string
+	StockCodes    []string            // 股票代码
+	Tags          []string            // 股票分组标签（如"持仓"）
+	Signals       []string            // 信号类型：BUY、SELL、HOLD
+	MinConfidence int                 // 信心度下限（含）
+	MaxConfidence int                 // 信心度上限（含），0表示不限制
+	Channels      []string            // 发送到的渠道名称，为空表示不发送
+	Mentions      map[string][]string // 各渠道需要@的成员（渠道名 → 手机号/用户ID列表）
+	Continue      bool                // 匹配后是否继续匹配后续规则（默认匹配第一条规则后停止）
+}
+
+// Match 判断信号是否符合规则
+func (r *RouteRule) Match(signal *TradingSignal, tags []string) bool {
+	if len(r.StockCodes) > 0 && !containsString(r.StockCodes, signal.StockCode) {
+		return false
+	}
+	if len(r.Tags) > 0 {
+		matched := false
+		for _, tag := range tags {
+			if containsString(r.Tags, tag) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(r.Signals) > 0 {
+		matched := false
+		for _, s := range r.Signals {
+			if strings.EqualFold(s, signal.Signal) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if signal.Confidence < r.MinConfidence {
+		return false
+	}
+	if r.MaxConfidence > 0 && signal.Confidence > r.MaxConfidence {
+		return false
+	}
+	return true
+}
+
+// Route 一次路由的结果：发送到的渠道和该渠道需要@的成员
+type Route struct {
+	Channel  string
+	Mentions []string
+}
+
+// Router 按规则将信号发送到指定渠道的通知器
+// 普通消息（启动通知、结果汇总等）发送到所有渠道
+type Router struct {
+	Rules           []RouteRule
+	DefaultChannels []string            // 没有规则匹配时发送的渠道，为空时发送到所有渠道
+	StockTags       map[string][]string // 股票代码 → 分组标签
+
+	names    []string
+	channels map[string]Notifier
+}
+
+// NewRouter 创建通知路由
+func NewRouter(rules []RouteRule, stockTags map[string][]string) *Router {
+	return &Router{
+		Rules:     rules,
+		StockTags: stockTags,
+		channels:  make(map[string]Notifier),
+	}
+}
+
+// AddChannel 添加渠道（按添加顺序发送）
+func (r *Router) AddChannel(name string, n Notifier) {
+	if _, ok := r.channels[name]; !ok {
+		r.names = append(r.names, name)
+	}
+	r.channels[name] = n
+}
+
+// Channels 返回所有渠道名称
+func (r *Router) Channels() []string {
+	return append([]string{}, r.names...)
+}
+
+// Route 计算信号需要发送到的渠道
+// 依次匹配规则，匹配到Continue为false的规则后停止；多条规则发送到同一渠道时合并@成员
+func (r *Router) Route(signal *TradingSignal) []Route {
+	tags := r.StockTags[signal.StockCode]
+
+	var routes []Route
+	index := make(map[string]int)
+	matched := false
+	for i := range r.Rules {
+		rule := &r.Rules[i]
+		if !rule.Match(signal, tags) {
+			continue
+		}
+		matched = true
+		for _, channel := range rule.Channels {
+			if _, ok := r.channels[channel]; !ok {
+				continue
+			}
+			pos, ok := index[channel]
+			if !ok {
+				pos = len(routes)
+				index[channel] = pos
+				routes = append(routes, Route{Channel: channel})
+			}
+			for _, mention := range rule.Mentions[channel] {
+				if !containsString(routes[pos].Mentions, mention) {
+					routes[pos].Mentions = append(routes[pos].Mentions, mention)
+				}
+			}
+		}
+		if !rule.Continue {
+			break
+		}
+	}
+	if matched {
+		return routes
+	}
+
+	defaults := r.DefaultChannels
+	if len(defaults) == 0 {
+		defaults = r.names
+	}
+	for _, channel := range defaults {
+		if _, ok := r.channels[channel]; ok {
+			routes = append(routes, Route{Channel: channel})
+		}
+	}
+	return routes
+}
+
+// SendSignal 按规则发送交易信号
+func (r *Router) SendSignal(signal *TradingSignal) error {
+	return r.SendSignalContext(context.Background(), signal)
+}
+
+// SendSignalContext 按规则发送交易信号（支持取消）
+func (r *Router) SendSignalContext(ctx context.Context, signal *TradingSignal) error {
+	var errs []error
+	for _, route := range r.Route(signal) {
+		routed := *signal
+		routed.Mentions = route.Mentions
+		if err := SendSignalContext(ctx, r.channels[route.Channel], &routed); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", route.Channel, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("部分通知渠道发送失败: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// SendMessage 发送消息到所有渠道
+func (r *Router) SendMessage(message string) error {
+	return r.SendMessageContext(context.Background(), message)
+}
+
+// SendMessageContext 发送消息到所有渠道（支持取消）
+func (r *Router) SendMessageContext(ctx context.Context, message string) error {
+	var errs []error
+	for _, name := range r.names {
+		if err := SendMessageContext(ctx, r.channels[name], message); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("部分通知渠道发送失败: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// containsString 字符串切片是否包含指定值
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
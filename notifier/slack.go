@@ -0,0 +1,149 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// Slack Block Kit字段长度限制
+const (
+	slackHeaderLimit  = 150  // header块纯文本最大字符数
+	slackSectionLimit = 3000 // section块文本最大字符数
+)
+
+// SlackNotifier Slack Incoming Webhook通知器
+// 文档: https://api.slack.com/messaging/webhooks
+type SlackNotifier struct {
+	WebhookURL string
+}
+
+// NewSlackNotifier 创建Slack通知器
+func NewSlackNotifier(webhookURL string) *SlackNotifier {
+	return &SlackNotifier{
+		WebhookURL: webhookURL,
+	}
+}
+
+// SendSignal 发送交易信号到Slack
+func (s *SlackNotifier) SendSignal(signal *TradingSignal) error {
+	return s.SendSignalContext(context.Background(), signal)
+}
+
+// SendSignalContext 发送交易信号到Slack（支持取消）
+func (s *SlackNotifier) SendSignalContext(ctx context.Context, signal *TradingSignal) error {
+	message := map[string]interface{}{
+		"text":   signalTitle(signal), // 通知预览和不支持Block Kit的客户端显示的文本
+		"blocks": s.formatSignalBlocks(signal),
+	}
+	return s.sendRequest(ctx, message)
+}
+
+// SendMessage 发送普通消息到Slack
+func (s *SlackNotifier) SendMessage(message string) error {
+	return s.SendMessageContext(context.Background(), message)
+}
+
+// SendMessageContext 发送普通消息到Slack（支持取消）
+func (s *SlackNotifier) SendMessageContext(ctx context.Context, message string) error {
+	return s.sendRequest(ctx, map[string]interface{}{
+		"text": message,
+	})
+}
+
+// formatSignalBlocks 格式化信号为Block Kit消息
+func (s *SlackNotifier) formatSignalBlocks(signal *TradingSignal) []map[string]interface{} {
+	fields := []map[string]interface{}{}
+	for _, field := range signalFields(signal) {
+		fields = append(fields, map[string]interface{}{
+			"type": "mrkdwn",
+			"text": fmt.Sprintf("*%s*\n%s", field.Name, slackEscape(field.Value)),
+		})
+	}
+
+	blocks := []map[string]interface{}{
+		{
+			"type": "header",
+			"text": map[string]interface{}{
+				"type":  "plain_text",
+				"text":  truncateRunes(signalTitle(signal), slackHeaderLimit),
+				"emoji": true,
+			},
+		},
+		{
+			"type":   "section",
+			"fields": fields,
+		},
+		{
+			"type": "divider",
+		},
+		{
+			"type": "section",
+			"text": map[string]interface{}{
+				"type": "mrkdwn",
+				"text": "*分析原因*\n" + truncateEscaped(signal.Reasoning, slackSectionLimit-utf8.RuneCountInString("*分析原因*\n"), slackEscape),
+			},
+		},
+		{
+			"type": "context",
+			"elements": []map[string]interface{}{
+				{
+					"type": "mrkdwn",
+					"text": fmt.Sprintf("AI股票分析系统 | %s", signal.Timestamp.Format("2006-01-02 15:04:05")),
+				},
+			},
+		},
+	}
+
+	// @成员（Slack用户ID，"all"表示频道内所有人）
+	if len(signal.Mentions) > 0 {
+		mentions := make([]string, 0, len(signal.Mentions))
+		for _, mention := range signal.Mentions {
+			if isMentionAll(mention) {
+				mentions = append(mentions, "<!channel>")
+			} else {
+				mentions = append(mentions, fmt.Sprintf("<@%s>", mention))
+			}
+		}
+		blocks = append(blocks, map[string]interface{}{
+			"type": "section",
+			"text": map[string]interface{}{
+				"type": "mrkdwn",
+				"text": strings.Join(mentions, " "),
+			},
+		})
+	}
+	return blocks
+}
+
+// sendRequest 发送HTTP请求到Slack
+// Slack成功时返回200和"ok"，失败时通过状态码和错误文本（如invalid_payload）表示
+func (s *SlackNotifier) sendRequest(ctx context.Context, message map[string]interface{}) error {
+	jsonData, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("序列化消息失败: %w", err)
+	}
+
+	status, body, err := postJSONStatus(ctx, s.WebhookURL, jsonData)
+	if err != nil {
+		return err
+	}
+
+	if status != http.StatusOK {
+		webhookErr := &WebhookError{Channel: "Slack", Code: status, Message: strings.TrimSpace(string(body))}
+		if status == http.StatusTooManyRequests {
+			webhookErr.Kind = ErrRateLimited
+		}
+		return webhookErr
+	}
+
+	return nil
+}
+
+// slackEscape 转义Slack mrkdwn中的控制字符
+func slackEscape(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
@@ -0,0 +1,125 @@
+package notifier
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// slackBlocks 解析Slack请求体中的blocks
+func slackBlocks(t *testing.T, body string) (string, []map[string]interface{}) {
+	t.Helper()
+	var payload struct {
+		Text   string                   `json:"text"`
+		Blocks []map[string]interface{} `json:"blocks"`
+	}
+	if err := json.Unmarshal([]byte(body), &payload); err != nil {
+		t.Fatalf("请求体不是JSON: %v", err)
+	}
+	return payload.Text, payload.Blocks
+}
+
+// blockText 块中text对象的文本
+func blockText(block map[string]interface{}) string {
+	text, _ := block["text"].(map[string]interface{})
+	s, _ := text["text"].(string)
+	return s
+}
+
+func TestSlackSignalBlocks(t *testing.T) {
+	server, requests := webhookServer(t, http.StatusOK, "ok")
+	s := NewSlackNotifier(server.URL)
+	signal := &TradingSignal{
+		StockCode: "600519", StockName: "贵州茅台", Signal: "BUY", Price: 1500, Confidence: 80,
+		TargetPrice: 1600, StopLoss: 1450, Reasoning: "放量突破<前高> & 站稳均线",
+		Timestamp: time.Date(2024, 3, 7, 10, 0, 0, 0, time.UTC),
+		Mentions:  []string{"U123", "all"},
+	}
+	if err := s.SendSignal(signal); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+
+	text, blocks := slackBlocks(t, (<-requests).Body)
+	if text != signalTitle(signal) {
+		t.Errorf("预览文本 = %q", text)
+	}
+	var types []string
+	for _, block := range blocks {
+		types = append(types, block["type"].(string))
+	}
+	if strings.Join(types, ",") != "header,section,divider,section,context,section" {
+		t.Fatalf("块顺序 = %v", types)
+	}
+	if header := blockText(blocks[0]); header != "🚀 BUY信号 - 贵州茅台(600519)" {
+		t.Errorf("header = %q", header)
+	}
+	fields := blocks[1]["fields"].([]interface{})
+	if len(fields) != 4 || fields[2].(map[string]interface{})["text"] != "*目标价格*\n1600.00元" {
+		t.Errorf("fields = %v", fields)
+	}
+	if reasoning := blockText(blocks[3]); reasoning != "*分析原因*\n放量突破&lt;前高&gt; &amp; 站稳均线" {
+		t.Errorf("分析原因未转义: %q", reasoning)
+	}
+	if mentions := blockText(blocks[5]); mentions != "<@U123> <!channel>" {
+		t.Errorf("@成员 = %q", mentions)
+	}
+}
+
+func TestSlackTruncation(t *testing.T) {
+	server, requests := webhookServer(t, http.StatusOK, "ok")
+	s := NewSlackNotifier(server.URL)
+	signal := &TradingSignal{
+		StockCode: "600519", StockName: strings.Repeat("名", 200), Signal: "SELL",
+		Reasoning: strings.Repeat("<", 2000),
+	}
+	if err := s.SendSignal(signal); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+
+	_, blocks := slackBlocks(t, (<-requests).Body)
+	if n := utf8.RuneCountInString(blockText(blocks[0])); n > slackHeaderLimit {
+		t.Errorf("header长度%d超过限制%d", n, slackHeaderLimit)
+	}
+	reasoning := blockText(blocks[3])
+	if n := utf8.RuneCountInString(reasoning); n > slackSectionLimit {
+		t.Errorf("section长度%d超过限制%d", n, slackSectionLimit)
+	}
+	if !strings.HasSuffix(reasoning, "&lt;...") {
+		t.Errorf("截断时不应拆分转义实体: ...%s", reasoning[len(reasoning)-10:])
+	}
+}
+
+func TestSlackMessageAndErrors(t *testing.T) {
+	server, requests := webhookServer(t, http.StatusOK, "ok")
+	if err := NewSlackNotifier(server.URL).SendMessage("系统已启动"); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	if body := (<-requests).Body; body != `{"text":"系统已启动"}` {
+		t.Errorf("请求体 = %s", body)
+	}
+
+	tests := []struct {
+		status      int
+		response    string
+		rateLimited bool
+	}{
+		{http.StatusBadRequest, "invalid_payload", false},
+		{http.StatusNotFound, "no_service", false},
+		{http.StatusTooManyRequests, "rate_limited", true},
+	}
+	for _, tt := range tests {
+		server, _ := webhookServer(t, tt.status, tt.response)
+		err := NewSlackNotifier(server.URL).SendMessage("test")
+		var webhookErr *WebhookError
+		if !errors.As(err, &webhookErr) || webhookErr.Code != tt.status || webhookErr.Message != tt.response {
+			t.Errorf("状态码%d: 应返回WebhookError，实际: %v", tt.status, err)
+		}
+		if errors.Is(err, ErrRateLimited) != tt.rateLimited {
+			t.Errorf("状态码%d: errors.Is(err, ErrRateLimited)应为%v", tt.status, tt.rateLimited)
+		}
+	}
+}
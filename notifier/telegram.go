@@ -0,0 +1,135 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultTelegramAPIURL Telegram Bot API默认地址
+const DefaultTelegramAPIURL = "https://api.telegram.org"
+
+// telegramMessageLimit Telegram单条消息最大字符数
+const telegramMessageLimit = 4096
+
+// TelegramNotifier Telegram机器人通知器
+// 文档: https://core.telegram.org/bots/api#sendmessage
+type TelegramNotifier struct {
+	BotToken string
+	ChatID   string // 用户、群组或频道ID（频道可用@channelname）
+	APIURL   string // Bot API地址，默认https://api.telegram.org（可配置为反向代理地址）
+}
+
+// NewTelegramNotifier 创建Telegram通知器
+func NewTelegramNotifier(botToken string, chatID string) *TelegramNotifier {
+	return &TelegramNotifier{
+		BotToken: botToken,
+		ChatID:   chatID,
+		APIURL:   DefaultTelegramAPIURL,
+	}
+}
+
+// SendSignal 发送交易信号到Telegram
+func (t *TelegramNotifier) SendSignal(signal *TradingSignal) error {
+	return t.SendSignalContext(context.Background(), signal)
+}
+
+// SendSignalContext 发送交易信号到Telegram（支持取消）
+func (t *TelegramNotifier) SendSignalContext(ctx context.Context, signal *TradingSignal) error {
+	return t.sendRequest(ctx, t.formatSignalHTML(signal), "HTML")
+}
+
+// SendMessage 发送普通消息到Telegram
+func (t *TelegramNotifier) SendMessage(message string) error {
+	return t.SendMessageContext(context.Background(), message)
+}
+
+// SendMessageContext 发送普通消息到Telegram（支持取消）
+func (t *TelegramNotifier) SendMessageContext(ctx context.Context, message string) error {
+	return t.sendRequest(ctx, truncateRunes(message, telegramMessageLimit), "")
+}
+
+// formatSignalHTML 格式化信号为Telegram HTML消息
+// 超长时只截断分析原因，避免截断HTML标签；其余部分已超长（如@成员过多）时改为纯文本后截断
+func (t *TelegramNotifier) formatSignalHTML(signal *TradingSignal) string {
+	var head strings.Builder
+	head.WriteString(fmt.Sprintf("<b>%s</b>\n\n", html.EscapeString(signalTitle(signal))))
+	for _, field := range signalFields(signal) {
+		head.WriteString(fmt.Sprintf("<b>%s</b>: %s\n", field.Name, html.EscapeString(field.Value)))
+	}
+	head.WriteString("\n<b>分析原因</b>\n")
+
+	mentions := make([]string, 0, len(signal.Mentions))
+	for _, mention := range signal.Mentions {
+		mentions = append(mentions, "@"+strings.TrimPrefix(mention, "@"))
+	}
+	tail := fmt.Sprintf("\n\n<i>%s</i>", signal.Timestamp.Format("2006-01-02 15:04:05"))
+	if len(mentions) > 0 {
+		tail += "\n" + html.EscapeString(strings.Join(mentions, " "))
+	}
+
+	budget := telegramMessageLimit - utf8.RuneCountInString(head.String()) - utf8.RuneCountInString(tail)
+	if budget >= len("...") {
+		return head.String() + truncateEscaped(signal.Reasoning, budget, html.EscapeString) + tail
+	}
+
+	var plain strings.Builder
+	plain.WriteString(signalTitle(signal) + "\n\n")
+	for _, field := range signalFields(signal) {
+		plain.WriteString(fmt.Sprintf("%s: %s\n", field.Name, field.Value))
+	}
+	plain.WriteString(fmt.Sprintf("\n分析原因\n%s\n\n%s", signal.Reasoning, signal.Timestamp.Format("2006-01-02 15:04:05")))
+	if len(mentions) > 0 {
+		plain.WriteString("\n" + strings.Join(mentions, " "))
+	}
+	return truncateEscaped(plain.String(), telegramMessageLimit, html.EscapeString)
+}
+
+// sendRequest 调用sendMessage接口
+func (t *TelegramNotifier) sendRequest(ctx context.Context, text string, parseMode string) error {
+	message := map[string]interface{}{
+		"chat_id":                  t.ChatID,
+		"text":                     text,
+		"disable_web_page_preview": true,
+	}
+	if parseMode != "" {
+		message["parse_mode"] = parseMode
+	}
+
+	jsonData, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("序列化消息失败: %w", err)
+	}
+
+	apiURL := strings.TrimRight(t.APIURL, "/")
+	if apiURL == "" {
+		apiURL = DefaultTelegramAPIURL
+	}
+	body, err := postJSON(ctx, fmt.Sprintf("%s/bot%s/sendMessage", apiURL, t.BotToken), jsonData)
+	if err != nil {
+		return err
+	}
+
+	var result struct {
+		OK          bool   `json:"ok"`
+		ErrorCode   int    `json:"error_code"`
+		Description string `json:"description"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("解析响应失败: %w", err)
+	}
+
+	if !result.OK {
+		webhookErr := &WebhookError{Channel: "Telegram", Code: result.ErrorCode, Message: result.Description}
+		if result.ErrorCode == http.StatusTooManyRequests {
+			webhookErr.Kind = ErrRateLimited
+		}
+		return webhookErr
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package notifier
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestTelegramFormatSignalHTMLLength(t *testing.T) {
+	tg := NewTelegramNotifier("token", "chat")
+	base := TradingSignal{
+		StockCode: "600519", StockName: "贵州茅台", Signal: "BUY", Price: 1500, Confidence: 80,
+		Timestamp: time.Date(2024, 3, 7, 10, 0, 0, 0, time.UTC),
+	}
+	manyMentions := make([]string, 1500)
+	for i := range manyMentions {
+		manyMentions[i] = "trader_with_long_name"
+	}
+
+	tests := []struct {
+		name      string
+		reasoning string
+		mentions  []string
+		html      bool // 是否保留HTML格式
+		contains  string
+	}{
+		{"未超长", "放量突破<前高>", []string{"alice"}, true, "放量突破&lt;前高&gt;"},
+		{"截断分析原因", strings.Repeat("放量突破。", 2000), []string{"alice"}, true, "@alice"},
+		{"截断时不拆分转义实体", strings.Repeat("<", 3000), nil, true, "&lt;..."},
+		{"@成员过多时改为纯文本", "放量突破", manyMentions, false, "贵州茅台"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signal := base
+			signal.Reasoning = tt.reasoning
+			signal.Mentions = tt.mentions
+
+			text := tg.formatSignalHTML(&signal)
+			if n := utf8.RuneCountInString(text); n > telegramMessageLimit {
+				t.Errorf("消息长度%d超过限制%d", n, telegramMessageLimit)
+			}
+			if strings.Contains(text, "<b>") != tt.html {
+				t.Errorf("HTML格式 = %v，期望%v", strings.Contains(text, "<b>"), tt.html)
+			}
+			if !strings.Contains(text, tt.contains) {
+				t.Errorf("消息中缺少%q", tt.contains)
+			}
+			if strings.Contains(text, "&l...") || strings.Contains(text, "&...") {
+				t.Errorf("转义实体被截断")
+			}
+		})
+	}
+}
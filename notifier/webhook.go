@@ -2,10 +2,19 @@ package notifier
 
 import (
 	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"nofx/stock/indicators"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -15,25 +24,115 @@ type Notifier interface {
 	SendMessage(message string) error
 }
 
+// ContextNotifier 支持取消的通知器接口
+type ContextNotifier interface {
+	Notifier
+	SendSignalContext(ctx context.Context, signal *TradingSignal) error
+	SendMessageContext(ctx context.Context, message string) error
+}
+
+// SendSignalContext 发送交易信号，通知器实现了ContextNotifier时使用ctx控制请求
+func SendSignalContext(ctx context.Context, n Notifier, signal *TradingSignal) error {
+	if cn, ok := n.(ContextNotifier); ok {
+		return cn.SendSignalContext(ctx, signal)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return n.SendSignal(signal)
+}
+
+// SendMessageContext 发送普通消息，通知器实现了ContextNotifier时使用ctx控制请求
+func SendMessageContext(ctx context.Context, n Notifier, message string) error {
+	if cn, ok := n.(ContextNotifier); ok {
+		return cn.SendMessageContext(ctx, message)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return n.SendMessage(message)
+}
+
+// 通知渠道返回的错误类型，可用errors.Is判断
+var (
+	ErrSignatureMismatch = errors.New("签名校验失败")
+	ErrRateLimited       = errors.New("发送频率超限")
+)
+
+// WebhookError 通知渠道返回的业务错误
+type WebhookError struct {
+	Channel string // 渠道名称，如"飞书"
+	Code    int    // 渠道返回的错误码
+	Message string // 渠道返回的错误信息
+	Kind    error  // 错误类型（ErrSignatureMismatch、ErrRateLimited），未识别的错误码为nil
+}
+
+// Error 实现error接口
+func (e *WebhookError) Error() string {
+	if e.Kind != nil {
+		return fmt.Sprintf("%sAPI错误(%d): %s: %s", e.Channel, e.Code, e.Kind, e.Message)
+	}
+	return fmt.Sprintf("%sAPI错误(%d): %s", e.Channel, e.Code, e.Message)
+}
+
+// Unwrap 支持errors.Is(err, ErrSignatureMismatch)等判断
+func (e *WebhookError) Unwrap() error {
+	return e.Kind
+}
+
+// httpClient 通知渠道共用的HTTP客户端，避免渠道无响应时请求一直挂起
+var httpClient = &http.Client{Timeout: 15 * time.Second}
+
+// postJSON 以POST方式发送JSON请求并返回响应体
+func postJSON(ctx context.Context, webhookURL string, jsonData []byte) ([]byte, error) {
+	_, body, err := postJSONStatus(ctx, webhookURL, jsonData)
+	return body, err
+}
+
+// postJSONStatus 以POST方式发送JSON请求并返回HTTP状态码和响应体
+// 用于通过状态码表示错误的渠道（Slack、Discord等）
+func postJSONStatus(ctx context.Context, webhookURL string, jsonData []byte) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return 0, nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	return resp.StatusCode, body, nil
+}
+
 // TradingSignal 交易信号
 type TradingSignal struct {
-	StockCode     string                 `json:"stock_code"`               // 股票代码
-	StockName     string                 `json:"stock_name"`               // 股票名称
-	Signal        string                 `json:"signal"`                   // 信号类型: BUY/SELL/HOLD
-	Price         float64                `json:"price"`                    // 当前价格
-	Confidence    int                    `json:"confidence"`               // 信心度 (0-100)
-	Reasoning     string                 `json:"reasoning"`                // 推理原因
-	TargetPrice   float64                `json:"target_price"`             // 目标价格
-	StopLoss      float64                `json:"stop_loss"`                // 止损价格
-	RiskReward    string                 `json:"risk_reward"`              // 风险回报比
-	Timestamp     time.Time              `json:"timestamp"`                // 时间戳
-	TechnicalData map[string]interface{} `json:"technical_data,omitempty"` // 技术指标数据
+	StockCode     string                        `json:"stock_code"`               // 股票代码
+	StockName     string                        `json:"stock_name"`               // 股票名称
+	Signal        string                        `json:"signal"`                   // 信号类型: BUY/SELL/HOLD
+	Price         float64                       `json:"price"`                    // 当前价格
+	Confidence    int                           `json:"confidence"`               // 信心度 (0-100)
+	Reasoning     string                        `json:"reasoning"`                // 推理原因
+	TargetPrice   float64                       `json:"target_price"`             // 目标价格
+	StopLoss      float64                       `json:"stop_loss"`                // 止损价格
+	RiskReward    string                        `json:"risk_reward"`              // 风险回报比
+	Timestamp     time.Time                     `json:"timestamp"`                // 时间戳
+	TechnicalData *indicators.TechnicalSnapshot `json:"technical_data,omitempty"` // 技术指标数据
+	Mentions      []string                      `json:"mentions,omitempty"`       // 需要@的成员，由路由规则按渠道设置（"all"表示所有人）
 }
 
 // DingTalkNotifier 钉钉通知器
 type DingTalkNotifier struct {
 	WebhookURL string
-	Secret     string // 加签密钥（可选）
+	Secret     string // 加签密钥（可选，SEC开头），只用于计算签名，不会出现在消息中
+	Keyword    string // 自定义关键词（可选），机器人开启关键词安全设置时会加入每条消息
 }
 
 // NewDingTalkNotifier 创建钉钉通知器
@@ -46,6 +145,11 @@ func NewDingTalkNotifier(webhookURL string, secret string) *DingTalkNotifier {
 
 // SendSignal 发送交易信号到钉钉
 func (d *DingTalkNotifier) SendSignal(signal *TradingSignal) error {
+	return d.SendSignalContext(context.Background(), signal)
+}
+
+// SendSignalContext 发送交易信号到钉钉（支持取消）
+func (d *DingTalkNotifier) SendSignalContext(ctx context.Context, signal *TradingSignal) error {
 	// 构建Markdown格式的消息
 	markdown := d.formatSignalMarkdown(signal)
 
@@ -56,23 +160,29 @@ func (d *DingTalkNotifier) SendSignal(signal *TradingSignal) error {
 			"title": fmt.Sprintf("【%s】%s %s", signal.Signal, signal.StockName, signal.StockCode),
 			"text":  markdown,
 		},
-		"at": map[string]interface{}{
-			"isAtAll": false,
-		},
+		"at": d.formatAt(signal.Mentions),
 	}
 
-	return d.sendRequest(message)
+	return d.sendRequest(ctx, message)
 }
 
 // SendMessage 发送普通消息到钉钉
 func (d *DingTalkNotifier) SendMessage(message string) error {
+	return d.SendMessageContext(context.Background(), message)
+}
+
+// SendMessageContext 发送普通消息到钉钉（支持取消）
+func (d *DingTalkNotifier) SendMessageContext(ctx context.Context, message string) error {
+	if d.Keyword != "" && !strings.Contains(message, d.Keyword) {
+		message = fmt.Sprintf("【%s】%s", d.Keyword, message)
+	}
 	msg := map[string]interface{}{
 		"msgtype": "text",
 		"text": map[string]string{
 			"content": message,
 		},
 	}
-	return d.sendRequest(msg)
+	return d.sendRequest(ctx, msg)
 }
 
 // formatSignalMarkdown 格式化信号为Markdown
@@ -89,9 +199,13 @@ func (d *DingTalkNotifier) formatSignalMarkdown(signal *TradingSignal) string {
 		emoji = "📊"
 	}
 
-	// 添加关键词以通过钉钉安全验证
 	markdown := fmt.Sprintf("# %s %s信号 - %s(%s)\n\n", emoji, signal.Signal, signal.StockName, signal.StockCode)
-	markdown += fmt.Sprintf("> **【%s】AI股票分析系统**\n\n", d.Secret)
+	// 添加关键词以通过钉钉关键词安全验证
+	if d.Keyword != "" {
+		markdown += fmt.Sprintf("> **【%s】AI股票分析系统**\n\n", d.Keyword)
+	} else {
+		markdown += "> **AI股票分析系统**\n\n"
+	}
 	markdown += fmt.Sprintf("---\n\n")
 	markdown += fmt.Sprintf("**当前价格**: %.2f元\n\n", signal.Price)
 	markdown += fmt.Sprintf("**信心度**: %d%%\n\n", signal.Confidence)
@@ -111,28 +225,38 @@ func (d *DingTalkNotifier) formatSignalMarkdown(signal *TradingSignal) string {
 	markdown += fmt.Sprintf("---\n\n")
 	markdown += fmt.Sprintf("**时间**: %s\n\n", signal.Timestamp.Format("2006-01-02 15:04:05"))
 
+	// 钉钉要求被@的手机号出现在消息正文中才会高亮提醒
+	if _, mobiles := splitMentions(signal.Mentions); len(mobiles) > 0 {
+		markdown += "@" + strings.Join(mobiles, " @") + "\n"
+	}
+
 	return markdown
 }
 
+// formatAt 构建钉钉@参数，mentions为手机号，"all"表示@所有人
+func (d *DingTalkNotifier) formatAt(mentions []string) map[string]interface{} {
+	atAll, mobiles := splitMentions(mentions)
+	return map[string]interface{}{
+		"isAtAll":   atAll,
+		"atMobiles": mobiles,
+	}
+}
+
 // sendRequest 发送HTTP请求到钉钉
-func (d *DingTalkNotifier) sendRequest(message map[string]interface{}) error {
+func (d *DingTalkNotifier) sendRequest(ctx context.Context, message map[string]interface{}) error {
 	jsonData, err := json.Marshal(message)
 	if err != nil {
 		return fmt.Errorf("序列化消息失败: %w", err)
 	}
 
-	// TODO: 如果有Secret，需要进行加签处理
-	// 钉钉加签文档: https://open.dingtalk.com/document/robots/custom-robot-access
-
-	resp, err := http.Post(d.WebhookURL, "application/json", bytes.NewBuffer(jsonData))
+	webhookURL, err := d.signedURL(time.Now())
 	if err != nil {
-		return fmt.Errorf("发送请求失败: %w", err)
+		return err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	body, err := postJSON(ctx, webhookURL, jsonData)
 	if err != nil {
-		return fmt.Errorf("读取响应失败: %w", err)
+		return err
 	}
 
 	var result map[string]interface{}
@@ -147,12 +271,47 @@ func (d *DingTalkNotifier) sendRequest(message map[string]interface{}) error {
 	return nil
 }
 
+// signedURL 配置了Secret时在Webhook地址上附加timestamp和sign参数
+// 钉钉加签文档: https://open.dingtalk.com/document/robots/customize-robot-security-settings
+// sign = urlEncode(base64(HmacSHA256(secret, timestamp + "\n" + secret)))，timestamp为毫秒时间戳
+func (d *DingTalkNotifier) signedURL(now time.Time) (string, error) {
+	if d.Secret == "" {
+		return d.WebhookURL, nil
+	}
+
+	u, err := url.Parse(d.WebhookURL)
+	if err != nil {
+		return "", fmt.Errorf("解析钉钉Webhook地址失败: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(now.UnixMilli(), 10)
+	query := u.Query()
+	query.Set("timestamp", timestamp)
+	query.Set("sign", dingTalkSign(timestamp, d.Secret))
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// dingTalkSign 计算钉钉加签签名（未URL编码）
+func dingTalkSign(timestamp string, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "\n" + secret))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
 // FeishuNotifier 飞书通知器
 type FeishuNotifier struct {
 	WebhookURL string
-	Secret     string // 签名密钥（可选）
+	Secret     string // 签名校验密钥（可选），机器人开启签名校验时必须配置
 }
 
+// 飞书自定义机器人错误码
+const (
+	feishuCodeSignatureMismatch = 19021 // 签名不匹配或时间戳与服务器时间相差超过1小时
+	feishuCodeRateLimited       = 9499  // 请求过于频繁
+	feishuCodeFrequencyLimited  = 11232 // 发送消息频率超限
+)
+
 // NewFeishuNotifier 创建飞书通知器
 func NewFeishuNotifier(webhookURL string, secret string) *FeishuNotifier {
 	return &FeishuNotifier{
@@ -163,6 +322,11 @@ func NewFeishuNotifier(webhookURL string, secret string) *FeishuNotifier {
 
 // SendSignal 发送交易信号到飞书
 func (f *FeishuNotifier) SendSignal(signal *TradingSignal) error {
+	return f.SendSignalContext(context.Background(), signal)
+}
+
+// SendSignalContext 发送交易信号到飞书（支持取消）
+func (f *FeishuNotifier) SendSignalContext(ctx context.Context, signal *TradingSignal) error {
 	// 构建富文本消息
 	content := f.formatSignalRichText(signal)
 
@@ -172,18 +336,23 @@ func (f *FeishuNotifier) SendSignal(signal *TradingSignal) error {
 		"card":     content,
 	}
 
-	return f.sendRequest(message)
+	return f.sendRequest(ctx, message)
 }
 
 // SendMessage 发送普通消息到飞书
 func (f *FeishuNotifier) SendMessage(message string) error {
+	return f.SendMessageContext(context.Background(), message)
+}
+
+// SendMessageContext 发送普通消息到飞书（支持取消）
+func (f *FeishuNotifier) SendMessageContext(ctx context.Context, message string) error {
 	msg := map[string]interface{}{
 		"msg_type": "text",
 		"content": map[string]string{
 			"text": message,
 		},
 	}
-	return f.sendRequest(msg)
+	return f.sendRequest(ctx, msg)
 }
 
 // formatSignalRichText 格式化信号为飞书卡片
@@ -281,6 +450,24 @@ func (f *FeishuNotifier) formatSignalRichText(signal *TradingSignal) map[string]
 		},
 	})
 
+	// 添加@成员（open_id，"all"表示所有人）
+	if len(signal.Mentions) > 0 {
+		var at strings.Builder
+		for _, mention := range signal.Mentions {
+			if isMentionAll(mention) {
+				mention = "all"
+			}
+			at.WriteString(fmt.Sprintf("<at id=%s></at> ", mention))
+		}
+		card["elements"] = append(card["elements"].([]map[string]interface{}), map[string]interface{}{
+			"tag": "div",
+			"text": map[string]string{
+				"tag":     "lark_md",
+				"content": strings.TrimSpace(at.String()),
+			},
+		})
+	}
+
 	// 添加时间戳
 	card["elements"] = append(card["elements"].([]map[string]interface{}), map[string]interface{}{
 		"tag": "note",
@@ -296,38 +483,60 @@ func (f *FeishuNotifier) formatSignalRichText(signal *TradingSignal) map[string]
 }
 
 // sendRequest 发送HTTP请求到飞书
-func (f *FeishuNotifier) sendRequest(message map[string]interface{}) error {
+func (f *FeishuNotifier) sendRequest(ctx context.Context, message map[string]interface{}) error {
+	if f.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		sign, err := feishuSign(timestamp, f.Secret)
+		if err != nil {
+			return err
+		}
+		message["timestamp"] = timestamp
+		message["sign"] = sign
+	}
+
 	jsonData, err := json.Marshal(message)
 	if err != nil {
 		return fmt.Errorf("序列化消息失败: %w", err)
 	}
 
-	// TODO: 如果有Secret，需要进行签名处理
-	// 飞书签名文档: https://open.feishu.cn/document/ukTMukTMukTM/ucTM5YjL3ETO24yNxkjN
-
-	resp, err := http.Post(f.WebhookURL, "application/json", bytes.NewBuffer(jsonData))
+	body, err := postJSON(ctx, f.WebhookURL, jsonData)
 	if err != nil {
-		return fmt.Errorf("发送请求失败: %w", err)
+		return err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("读取响应失败: %w", err)
+	var result struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
 	}
-
-	var result map[string]interface{}
 	if err := json.Unmarshal(body, &result); err != nil {
 		return fmt.Errorf("解析响应失败: %w", err)
 	}
 
-	if code, ok := result["code"].(float64); ok && code != 0 {
-		return fmt.Errorf("飞书API错误: %v", result["msg"])
+	if result.Code != 0 {
+		webhookErr := &WebhookError{Channel: "飞书", Code: result.Code, Message: result.Msg}
+		switch result.Code {
+		case feishuCodeSignatureMismatch:
+			webhookErr.Kind = ErrSignatureMismatch
+		case feishuCodeRateLimited, feishuCodeFrequencyLimited:
+			webhookErr.Kind = ErrRateLimited
+		}
+		return webhookErr
 	}
 
 	return nil
 }
 
+// feishuSign 计算飞书签名
+// 飞书签名文档: https://open.feishu.cn/document/client-docs/bot-v3/add-custom-bot
+// 以 timestamp + "\n" + secret 为密钥对空字符串做HmacSHA256，再进行base64编码，timestamp为秒级时间戳
+func feishuSign(timestamp string, secret string) (string, error) {
+	mac := hmac.New(sha256.New, []byte(timestamp+"\n"+secret))
+	if _, err := mac.Write([]byte{}); err != nil {
+		return "", fmt.Errorf("计算飞书签名失败: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
 // MultiNotifier 多通知器（同时发送到多个平台）
 type MultiNotifier struct {
 	Notifiers []Notifier
@@ -342,30 +551,40 @@ func NewMultiNotifier(notifiers ...Notifier) *MultiNotifier {
 
 // SendSignal 发送信号到所有通知器
 func (m *MultiNotifier) SendSignal(signal *TradingSignal) error {
-	var errors []error
+	return m.SendSignalContext(context.Background(), signal)
+}
+
+// SendSignalContext 发送信号到所有通知器（支持取消）
+func (m *MultiNotifier) SendSignalContext(ctx context.Context, signal *TradingSignal) error {
+	var errs []error
 	for _, notifier := range m.Notifiers {
-		if err := notifier.SendSignal(signal); err != nil {
-			errors = append(errors, err)
+		if err := SendSignalContext(ctx, notifier, signal); err != nil {
+			errs = append(errs, err)
 		}
 	}
 
-	if len(errors) > 0 {
-		return fmt.Errorf("部分通知器发送失败: %v", errors)
+	if len(errs) > 0 {
+		return fmt.Errorf("部分通知器发送失败: %w", errors.Join(errs...))
 	}
 	return nil
 }
 
 // SendMessage 发送消息到所有通知器
 func (m *MultiNotifier) SendMessage(message string) error {
-	var errors []error
+	return m.SendMessageContext(context.Background(), message)
+}
+
+// SendMessageContext 发送消息到所有通知器（支持取消）
+func (m *MultiNotifier) SendMessageContext(ctx context.Context, message string) error {
+	var errs []error
 	for _, notifier := range m.Notifiers {
-		if err := notifier.SendMessage(message); err != nil {
-			errors = append(errors, err)
+		if err := SendMessageContext(ctx, notifier, message); err != nil {
+			errs = append(errs, err)
 		}
 	}
 
-	if len(errors) > 0 {
-		return fmt.Errorf("部分通知器发送失败: %v", errors)
+	if len(errs) > 0 {
+		return fmt.Errorf("部分通知器发送失败: %w", errors.Join(errs...))
 	}
 	return nil
 }
@@ -0,0 +1,219 @@
+package notifier
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dingTalkRequest 测试钉钉机器人收到的请求
+type dingTalkRequest struct {
+	Query url.Values
+	Body  map[string]interface{}
+}
+
+// dingTalkServer 模拟钉钉机器人Webhook：按官方算法校验加签，返回errcode
+func dingTalkServer(t *testing.T, secret string, requests chan<- dingTalkRequest) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var body map[string]interface{}
+		if err := json.Unmarshal(data, &body); err != nil {
+			t.Errorf("请求体不是JSON: %s", data)
+		}
+		requests <- dingTalkRequest{Query: r.URL.Query(), Body: body}
+
+		if secret != "" {
+			timestamp := r.URL.Query().Get("timestamp")
+			ms, err := strconv.ParseInt(timestamp, 10, 64)
+			mac := hmac.New(sha256.New, []byte(secret))
+			mac.Write([]byte(timestamp + "\n" + secret))
+			want := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+			if err != nil || time.Since(time.UnixMilli(ms)) > time.Hour || r.URL.Query().Get("sign") != want {
+				w.Write([]byte(`{"errcode":310000,"errmsg":"sign not match"}`))
+				return
+			}
+		}
+		w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDingTalkSignedRequest(t *testing.T) {
+	const secret = "SEC0123456789abcdef"
+	requests := make(chan dingTalkRequest, 1)
+	server := dingTalkServer(t, secret, requests)
+
+	d := NewDingTalkNotifier(server.URL+"/robot/send?access_token=abc", secret)
+	d.Keyword = "股票"
+	signal := &TradingSignal{
+		StockCode: "600519", StockName: "贵州茅台", Signal: "BUY", Price: 1500, Confidence: 80,
+		Reasoning: "放量突破", Timestamp: time.Date(2024, 3, 7, 10, 0, 0, 0, time.UTC),
+		Mentions: []string{"13800000000"},
+	}
+	if err := d.SendSignal(signal); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+
+	req := <-requests
+	if req.Query.Get("access_token") != "abc" {
+		t.Errorf("access_token丢失: %v", req.Query)
+	}
+	markdown := req.Body["markdown"].(map[string]interface{})["text"].(string)
+	if strings.Contains(markdown, secret) {
+		t.Errorf("消息正文不应包含加签密钥")
+	}
+	if !strings.Contains(markdown, "【股票】") || !strings.Contains(markdown, "@13800000000") {
+		t.Errorf("消息正文缺少关键词或@手机号: %s", markdown)
+	}
+	at := req.Body["at"].(map[string]interface{})
+	if mobiles := at["atMobiles"].([]interface{}); len(mobiles) != 1 || mobiles[0] != "13800000000" {
+		t.Errorf("atMobiles = %v", mobiles)
+	}
+}
+
+func TestDingTalkKeywordMessage(t *testing.T) {
+	requests := make(chan dingTalkRequest, 2)
+	server := dingTalkServer(t, "", requests)
+
+	d := NewDingTalkNotifier(server.URL, "")
+	d.Keyword = "股票"
+	if err := d.SendMessage("系统已启动"); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	req := <-requests
+	if _, ok := req.Query["sign"]; ok {
+		t.Errorf("未配置Secret时不应加签: %v", req.Query)
+	}
+	if content := req.Body["text"].(map[string]interface{})["content"]; content != "【股票】系统已启动" {
+		t.Errorf("content = %v", content)
+	}
+
+	// 已包含关键词的消息不重复添加
+	if err := d.SendMessage("股票分析完成"); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	if content := (<-requests).Body["text"].(map[string]interface{})["content"]; content != "股票分析完成" {
+		t.Errorf("content = %v", content)
+	}
+}
+
+func TestDingTalkSignMismatch(t *testing.T) {
+	requests := make(chan dingTalkRequest, 1)
+	server := dingTalkServer(t, "SECright", requests)
+
+	d := NewDingTalkNotifier(server.URL, "SECwrong")
+	err := d.SendMessage("test")
+	<-requests
+	if err == nil || !strings.Contains(err.Error(), "sign not match") {
+		t.Errorf("签名错误时应返回钉钉的错误信息，实际: %v", err)
+	}
+}
+
+func TestFeishuSignVector(t *testing.T) {
+	// 按飞书文档算法独立计算的参考值：HmacSHA256(key=timestamp+"\n"+secret, data="")，base64编码
+	tests := []struct {
+		timestamp string
+		secret    string
+		want      string
+	}{
+		{"1599360473", "SEC5f4e7e1e", "RbqWhFHvp2V3TlPzBncvT3ZYAHFTOxTeBV/X52nWhg8="},
+		{"1700000000", "demo-secret", "mYHw2R2SOm8Rw/sne3lQdmz4sOfntKR+1P/8RKKTwmA="},
+	}
+	for _, tt := range tests {
+		got, err := feishuSign(tt.timestamp, tt.secret)
+		if err != nil || got != tt.want {
+			t.Errorf("feishuSign(%s, %s) = %s (%v)，期望%s", tt.timestamp, tt.secret, got, err, tt.want)
+		}
+	}
+}
+
+// feishuServer 模拟飞书机器人Webhook：校验请求体中的timestamp和sign，返回code
+func feishuServer(t *testing.T, secret string, code int, requests chan<- map[string]interface{}) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("请求体不是JSON: %v", err)
+		}
+		requests <- body
+
+		if secret != "" {
+			timestamp, _ := body["timestamp"].(string)
+			seconds, err := strconv.ParseInt(timestamp, 10, 64)
+			mac := hmac.New(sha256.New, []byte(timestamp+"\n"+secret))
+			want := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+			if err != nil || time.Since(time.Unix(seconds, 0)) > time.Hour || body["sign"] != want {
+				w.Write([]byte(`{"code":19021,"msg":"sign match fail or timestamp is not within one hour from current time"}`))
+				return
+			}
+		}
+		w.Write([]byte(`{"code":` + strconv.Itoa(code) + `,"msg":"test"}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFeishuSignedRequest(t *testing.T) {
+	requests := make(chan map[string]interface{}, 1)
+	server := feishuServer(t, "feishu-secret", 0, requests)
+
+	f := NewFeishuNotifier(server.URL, "feishu-secret")
+	if err := f.SendMessage("系统已启动"); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	body := <-requests
+	if body["msg_type"] != "text" || body["content"].(map[string]interface{})["text"] != "系统已启动" {
+		t.Errorf("请求体 = %v", body)
+	}
+
+	unsigned := NewFeishuNotifier(server.URL, "")
+	unsigned.SendMessage("test")
+	if _, ok := (<-requests)["sign"]; ok {
+		t.Errorf("未配置Secret时不应签名")
+	}
+}
+
+func TestFeishuErrorCodes(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string // 通知器使用的密钥，服务器固定为feishu-secret
+		code   int
+		kind   error
+	}{
+		{"签名不匹配", "wrong-secret", 0, ErrSignatureMismatch},
+		{"请求过于频繁", "feishu-secret", 9499, ErrRateLimited},
+		{"发送频率超限", "feishu-secret", 11232, ErrRateLimited},
+		{"其他错误", "feishu-secret", 19001, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := make(chan map[string]interface{}, 1)
+			server := feishuServer(t, "feishu-secret", tt.code, requests)
+
+			err := NewFeishuNotifier(server.URL, tt.secret).SendMessage("test")
+			<-requests
+			var webhookErr *WebhookError
+			if !errors.As(err, &webhookErr) || webhookErr.Channel != "飞书" {
+				t.Fatalf("应返回WebhookError，实际: %v", err)
+			}
+			if webhookErr.Kind != tt.kind {
+				t.Errorf("Kind = %v，期望%v", webhookErr.Kind, tt.kind)
+			}
+			if tt.kind != nil && !errors.Is(err, tt.kind) {
+				t.Errorf("errors.Is(err, %v) = false", tt.kind)
+			}
+		})
+	}
+}
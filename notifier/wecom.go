@@ -0,0 +1,202 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// 企业微信消息类型
+const (
+	WeComMessageMarkdown     = "markdown"
+	WeComMessageTemplateCard = "template_card"
+)
+
+// 企业微信群机器人错误码
+const (
+	wecomCodeRateLimited = 45009 // 接口调用超过限制（每个机器人每分钟最多20条）
+)
+
+// wecomMarkdownLimit 企业微信markdown内容最大字节数
+const wecomMarkdownLimit = 4096
+
+// WeComNotifier 企业微信群机器人通知器
+// 文档: https://developer.work.weixin.qq.com/document/path/91770
+type WeComNotifier struct {
+	WebhookURL     string
+	MessageType    string   // 信号消息类型："markdown"（默认）或 "template_card"
+	MentionMobiles []string // 需要@的成员手机号，"@all"表示所有人
+	CardURL        string   // 模板卡片点击后跳转的地址（template_card时必填）
+}
+
+// NewWeComNotifier 创建企业微信通知器
+func NewWeComNotifier(webhookURL string, mentionMobiles []string) *WeComNotifier {
+	return &WeComNotifier{
+		WebhookURL:     webhookURL,
+		MessageType:    WeComMessageMarkdown,
+		MentionMobiles: mentionMobiles,
+	}
+}
+
+// SendSignal 发送交易信号到企业微信
+func (w *WeComNotifier) SendSignal(signal *TradingSignal) error {
+	return w.SendSignalContext(context.Background(), signal)
+}
+
+// SendSignalContext 发送交易信号到企业微信（支持取消）
+// markdown和模板卡片消息不支持按手机号@成员，配置了MentionMobiles时会再发送一条文本消息提醒；
+// 信号消息发送成功后提醒消息失败只记录日志，避免重试时重复发送信号消息
+func (w *WeComNotifier) SendSignalContext(ctx context.Context, signal *TradingSignal) error {
+	var message map[string]interface{}
+	if w.MessageType == WeComMessageTemplateCard {
+		message = map[string]interface{}{
+			"msgtype":       "template_card",
+			"template_card": w.formatSignalCard(signal),
+		}
+	} else {
+		message = map[string]interface{}{
+			"msgtype": "markdown",
+			"markdown": map[string]string{
+				"content": w.formatSignalMarkdown(signal),
+			},
+		}
+	}
+
+	if err := w.sendRequest(ctx, message); err != nil {
+		return err
+	}
+
+	mentions := append(append([]string{}, w.MentionMobiles...), signal.Mentions...)
+	if len(mentions) > 0 {
+		if err := w.sendText(ctx, fmt.Sprintf("%s %s(%s) 出现%s信号，请关注",
+			signalEmoji(signal.Signal), signal.StockName, signal.StockCode, signal.Signal), mentions); err != nil {
+			log.Printf("⚠️  企业微信@提醒发送失败（信号消息已发送）: %v", err)
+		}
+	}
+	return nil
+}
+
+// SendMessage 发送普通消息到企业微信
+func (w *WeComNotifier) SendMessage(message string) error {
+	return w.SendMessageContext(context.Background(), message)
+}
+
+// SendMessageContext 发送普通消息到企业微信（支持取消）
+func (w *WeComNotifier) SendMessageContext(ctx context.Context, message string) error {
+	return w.sendText(ctx, message, w.MentionMobiles)
+}
+
+// sendText 发送文本消息并按手机号@成员（"@all"表示所有人）
+func (w *WeComNotifier) sendText(ctx context.Context, message string, mentionMobiles []string) error {
+	text := map[string]interface{}{
+		"content": message,
+	}
+	if len(mentionMobiles) > 0 {
+		mobiles := make([]string, 0, len(mentionMobiles))
+		for _, mobile := range mentionMobiles {
+			if isMentionAll(mobile) {
+				mobile = "@all"
+			}
+			mobiles = append(mobiles, mobile)
+		}
+		text["mentioned_mobile_list"] = mobiles
+	}
+	msg := map[string]interface{}{
+		"msgtype": "text",
+		"text":    text,
+	}
+	return w.sendRequest(ctx, msg)
+}
+
+// formatSignalMarkdown 格式化信号为企业微信markdown
+// 企业微信markdown只支持部分语法，用<font color>区分买卖方向
+func (w *WeComNotifier) formatSignalMarkdown(signal *TradingSignal) string {
+	color := "comment"
+	switch signal.Signal {
+	case "BUY":
+		color = "warning" // 橙红色
+	case "SELL":
+		color = "info" // 绿色
+	}
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("## %s <font color=\"%s\">%s信号</font> - %s(%s)\n",
+		signalEmoji(signal.Signal), color, signal.Signal, signal.StockName, signal.StockCode))
+	b.WriteString(fmt.Sprintf("> 当前价格: <font color=\"%s\">%.2f元</font>\n", color, signal.Price))
+	b.WriteString(fmt.Sprintf("> 信心度: %d%%\n", signal.Confidence))
+	if signal.TargetPrice > 0 {
+		b.WriteString(fmt.Sprintf("> 目标价格: %.2f元\n", signal.TargetPrice))
+	}
+	if signal.StopLoss > 0 {
+		b.WriteString(fmt.Sprintf("> 止损价格: %.2f元\n", signal.StopLoss))
+	}
+	if signal.RiskReward != "" {
+		b.WriteString(fmt.Sprintf("> 风险回报比: %s\n", signal.RiskReward))
+	}
+	b.WriteString(fmt.Sprintf("\n**分析原因**\n%s\n", signal.Reasoning))
+	b.WriteString(fmt.Sprintf("\n<font color=\"comment\">%s</font>", signal.Timestamp.Format("2006-01-02 15:04:05")))
+
+	return truncateUTF8(b.String(), wecomMarkdownLimit)
+}
+
+// formatSignalCard 格式化信号为企业微信文本通知模板卡片
+func (w *WeComNotifier) formatSignalCard(signal *TradingSignal) map[string]interface{} {
+	fields := []map[string]interface{}{}
+	for _, field := range signalFields(signal)[1:] { // 当前价格已作为关键数据展示
+		fields = append(fields, map[string]interface{}{"keyname": field.Name, "value": field.Value})
+	}
+
+	return map[string]interface{}{
+		"card_type": "text_notice",
+		"source": map[string]interface{}{
+			"desc": "AI股票分析系统",
+		},
+		"main_title": map[string]interface{}{
+			"title": signalTitle(signal),
+			"desc":  signal.Timestamp.Format("2006-01-02 15:04:05"),
+		},
+		"emphasis_content": map[string]interface{}{
+			"title": fmt.Sprintf("%.2f", signal.Price),
+			"desc":  "当前价格（元）",
+		},
+		"sub_title_text":          truncateUTF8(signal.Reasoning, 300),
+		"horizontal_content_list": fields,
+		"card_action": map[string]interface{}{
+			"type": 1,
+			"url":  w.CardURL,
+		},
+	}
+}
+
+// sendRequest 发送HTTP请求到企业微信
+func (w *WeComNotifier) sendRequest(ctx context.Context, message map[string]interface{}) error {
+	jsonData, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("序列化消息失败: %w", err)
+	}
+
+	body, err := postJSON(ctx, w.WebhookURL, jsonData)
+	if err != nil {
+		return err
+	}
+
+	var result struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("解析响应失败: %w", err)
+	}
+
+	if result.ErrCode != 0 {
+		webhookErr := &WebhookError{Channel: "企业微信", Code: result.ErrCode, Message: result.ErrMsg}
+		if result.ErrCode == wecomCodeRateLimited {
+			webhookErr.Kind = ErrRateLimited
+		}
+		return webhookErr
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package notifier
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWeComMentionFailureDoesNotFailSignal(t *testing.T) {
+	var msgTypes []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var body struct {
+			MsgType string `json:"msgtype"`
+		}
+		json.Unmarshal(data, &body)
+		msgTypes = append(msgTypes, body.MsgType)
+		if body.MsgType == "text" {
+			// @提醒因频率限制失败
+			w.Write([]byte(`{"errcode":45009,"errmsg":"api freq out of limit"}`))
+			return
+		}
+		w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	}))
+	defer server.Close()
+
+	w := NewWeComNotifier(server.URL, []string{"13800000000"})
+	signal := &TradingSignal{StockCode: "600519", StockName: "贵州茅台", Signal: "BUY", Price: 1500, Confidence: 80}
+	if err := w.SendSignal(signal); err != nil {
+		t.Fatalf("信号消息已发送，@提醒失败不应返回错误: %v", err)
+	}
+	if len(msgTypes) != 2 || msgTypes[0] != "markdown" || msgTypes[1] != "text" {
+		t.Errorf("请求顺序 = %v，期望[markdown text]", msgTypes)
+	}
+}
+
+func TestWeComSignalFailureReturnsError(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`{"errcode":45009,"errmsg":"api freq out of limit"}`))
+	}))
+	defer server.Close()
+
+	w := NewWeComNotifier(server.URL, []string{"13800000000"})
+	err := w.SendSignal(&TradingSignal{StockCode: "600519", Signal: "SELL"})
+	if err == nil {
+		t.Fatalf("信号消息发送失败时应返回错误")
+	}
+	if requests != 1 {
+		t.Errorf("信号消息失败后不应再发送@提醒，实际请求%d次", requests)
+	}
+}
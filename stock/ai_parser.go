@@ -85,7 +85,7 @@ func ParseAIResponse(response string) (*AIDecisionResponse, error) {
 }
 
 // ConvertToAnalysisResult 将AI决策转换为分析结果
-func ConvertToAnalysisResult(aiDecision *AIDecisionResponse, stockCode, stockName string, currentPrice float64, technical map[string]interface{}) *AnalysisResult {
+func ConvertToAnalysisResult(aiDecision *AIDecisionResponse, stockCode, stockName string, currentPrice float64, technical *TechnicalSnapshot) *AnalysisResult {
 	return &AnalysisResult{
 		StockCode:     stockCode,
 		StockName:     stockName,
@@ -0,0 +1,191 @@
+package stock
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// JobStatus 分析任务状态
+type JobStatus string
+
+const (
+	JobStatusPending   JobStatus = "pending"   // 等待执行（等待定时分析结束）
+	JobStatusRunning   JobStatus = "running"   // 执行中
+	JobStatusSucceeded JobStatus = "succeeded" // 执行成功
+	JobStatusFailed    JobStatus = "failed"    // 执行失败
+)
+
+// AnalysisJob 手动触发的分析任务
+type AnalysisJob struct {
+	ID         string          `json:"id"`
+	StockCode  string          `json:"stock_code"`
+	Status     JobStatus       `json:"status"`
+	Result     *AnalysisResult `json:"result,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	StartedAt  *time.Time      `json:"started_at,omitempty"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+
+	done chan struct{}
+}
+
+// Done 任务是否已结束
+func (j *AnalysisJob) Done() bool {
+	return j.Status == JobStatusSucceeded || j.Status == JobStatusFailed
+}
+
+// AnalysisJobManager 分析任务管理器
+// 同一只股票同时只保留一个未结束的任务，重复提交会返回已有任务
+type AnalysisJobManager struct {
+	mutex   sync.Mutex
+	jobs    map[string]*AnalysisJob
+	order   []string                // 任务ID按创建顺序排列，用于清理旧任务
+	active  map[string]*AnalysisJob // 股票代码 -> 未结束的任务
+	maxJobs int
+	seq     uint64
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewAnalysisJobManager 创建分析任务管理器，maxJobs为保留的历史任务数量
+func NewAnalysisJobManager(maxJobs int) *AnalysisJobManager {
+	if maxJobs <= 0 {
+		maxJobs = 200
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &AnalysisJobManager{
+		jobs:    make(map[string]*AnalysisJob),
+		active:  make(map[string]*AnalysisJob),
+		maxJobs: maxJobs,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Submit 提交立即分析任务，返回任务快照
+func (m *AnalysisJobManager) Submit(analyzer *StockAnalyzer) AnalysisJob {
+	code := analyzer.AnalysisConfig.StockCode
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if job, ok := m.active[code]; ok {
+		return m.snapshot(job)
+	}
+
+	id := fmt.Sprintf("%s-%s-%d", code, time.Now().Format("20060102150405"), atomic.AddUint64(&m.seq, 1))
+	job := &AnalysisJob{
+		ID:        id,
+		StockCode: code,
+		Status:    JobStatusPending,
+		CreatedAt: time.Now(),
+		done:      make(chan struct{}),
+	}
+	m.jobs[id] = job
+	m.order = append(m.order, id)
+	m.active[code] = job
+	m.evict()
+
+	go m.run(job, analyzer)
+
+	return m.snapshot(job)
+}
+
+// Get 获取任务快照
+func (m *AnalysisJobManager) Get(id string) (AnalysisJob, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return AnalysisJob{}, false
+	}
+	return m.snapshot(job), true
+}
+
+// Wait 等待任务结束并返回快照，ctx取消时返回当前状态
+func (m *AnalysisJobManager) Wait(ctx context.Context, id string) (AnalysisJob, error) {
+	m.mutex.Lock()
+	job, ok := m.jobs[id]
+	m.mutex.Unlock()
+	if !ok {
+		return AnalysisJob{}, fmt.Errorf("任务不存在: %s", id)
+	}
+
+	select {
+	case <-job.done:
+	case <-ctx.Done():
+	}
+
+	snapshot, _ := m.Get(id)
+	return snapshot, nil
+}
+
+// Shutdown 取消所有未结束的任务
+func (m *AnalysisJobManager) Shutdown() {
+	m.cancel()
+}
+
+// run 执行任务
+func (m *AnalysisJobManager) run(job *AnalysisJob, analyzer *StockAnalyzer) {
+	// 等待定时分析结束后执行（AnalyzeContext内部串行化）
+	analyzer.runMu.Lock()
+	defer analyzer.runMu.Unlock()
+
+	m.mutex.Lock()
+	now := time.Now()
+	job.Status = JobStatusRunning
+	job.StartedAt = &now
+	m.mutex.Unlock()
+
+	log.Printf("▶️  执行手动分析任务 %s", job.ID)
+	result, err := analyzer.analyze(m.ctx)
+
+	m.mutex.Lock()
+	finished := time.Now()
+	job.FinishedAt = &finished
+	if err != nil {
+		job.Status = JobStatusFailed
+		job.Error = err.Error()
+	} else {
+		job.Status = JobStatusSucceeded
+		job.Result = result
+	}
+	if m.active[job.StockCode] == job {
+		delete(m.active, job.StockCode)
+	}
+	m.mutex.Unlock()
+
+	close(job.done)
+}
+
+// evict 清理超出数量限制的已结束任务（调用方需持有mutex）
+func (m *AnalysisJobManager) evict() {
+	for len(m.order) > m.maxJobs {
+		evicted := false
+		for i, id := range m.order {
+			if job := m.jobs[id]; job != nil && !job.Done() {
+				continue
+			}
+			delete(m.jobs, id)
+			m.order = append(m.order[:i], m.order[i+1:]...)
+			evicted = true
+			break
+		}
+		if !evicted {
+			return
+		}
+	}
+}
+
+// snapshot 复制任务当前状态（调用方需持有mutex）
+func (m *AnalysisJobManager) snapshot(job *AnalysisJob) AnalysisJob {
+	copied := *job
+	copied.done = nil
+	return copied
+}
@@ -1,24 +1,41 @@
 package stock
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"math"
 	"nofx/mcp"
 	"nofx/notifier"
+	"nofx/stock/indicators"
 	"strings"
+	"sync"
 	"time"
 )
 
 // StockAnalyzer 股票分析器
 type StockAnalyzer struct {
 	TDXClient          *TDXClient
-	MCPClient          *mcp.Client
+	MCPClient          mcp.AIClient
 	Notifier           notifier.Notifier
 	AnalysisConfig     *AnalysisConfig
 	TradingTimeChecker *TradingTimeChecker
+	ResultStore        ResultStore     // 分析结果存储（可选）
+	PromptTemplate     *PromptTemplate // 提示词模板（为nil时使用内置提示词）
+	SignalTracker      *SignalTracker  // 信号结果跟踪器（可选）
+	SignalGate         *SignalGate     // 信号去重与冷却（可选，为nil时每次扫描都通知）
+	Ensemble           *Ensemble       // 多模型集成投票（可选，设置后代替MCPClient做决策）
+	UsageTracker       *UsageTracker   // AI用量和费用统计（可选，超出每日预算时暂停分析）
+
+	runMu      sync.Mutex // 保证同一股票同时只有一个分析在执行
+	cancelMu   sync.Mutex
+	cancelFunc context.CancelFunc // 当前进行中分析的取消函数
 }
 
+// ErrAnalysisInProgress 已有分析正在执行
+var ErrAnalysisInProgress = errors.New("已有分析正在执行")
+
 // AnalysisConfig 分析配置
 type AnalysisConfig struct {
 	StockCode          string        // 股票代码
@@ -26,10 +43,11 @@ type AnalysisConfig struct {
 	ScanInterval       time.Duration // 扫描间隔
 	EnableNotification bool          // 是否启用通知
 	MinConfidence      int           // 最小信心度阈值（低于此值不发送通知）
+	AnalysisTimeout    time.Duration // 单次分析超时时间（0表示不限制）
 }
 
 // NewStockAnalyzer 创建股票分析器
-func NewStockAnalyzer(tdxClient *TDXClient, mcpClient *mcp.Client, notif notifier.Notifier, config *AnalysisConfig, tradingTimeChecker *TradingTimeChecker) *StockAnalyzer {
+func NewStockAnalyzer(tdxClient *TDXClient, mcpClient mcp.AIClient, notif notifier.Notifier, config *AnalysisConfig, tradingTimeChecker *TradingTimeChecker) *StockAnalyzer {
 	return &StockAnalyzer{
 		TDXClient:          tdxClient,
 		MCPClient:          mcpClient,
@@ -39,226 +57,321 @@ func NewStockAnalyzer(tdxClient *TDXClient, mcpClient *mcp.Client, notif notifie
 	}
 }
 
+// TechnicalSnapshot 行情与技术指标快照
+type TechnicalSnapshot = indicators.TechnicalSnapshot
+
 // AnalysisResult 分析结果
 type AnalysisResult struct {
-	StockCode     string                 `json:"stock_code"`
-	StockName     string                 `json:"stock_name"`
-	CurrentPrice  float64                `json:"current_price"`
-	Signal        string                 `json:"signal"` // BUY/SELL/HOLD
-	Confidence    int                    `json:"confidence"`
-	Reasoning     string                 `json:"reasoning"`
-	TargetPrice   float64                `json:"target_price,omitempty"`
-	StopLoss      float64                `json:"stop_loss,omitempty"`
-	RiskReward    string                 `json:"risk_reward,omitempty"`
-	TechnicalData map[string]interface{} `json:"technical_data"`
-	Timestamp     time.Time              `json:"timestamp"`
+	StockCode     string             `json:"stock_code"`
+	StockName     string             `json:"stock_name"`
+	CurrentPrice  float64            `json:"current_price"`
+	Signal        string             `json:"signal"` // BUY/SELL/HOLD
+	Confidence    int                `json:"confidence"`
+	Reasoning     string             `json:"reasoning"`
+	TargetPrice   float64            `json:"target_price,omitempty"`
+	StopLoss      float64            `json:"stop_loss,omitempty"`
+	RiskReward    string             `json:"risk_reward,omitempty"`
+	TechnicalData *TechnicalSnapshot `json:"technical_data"`
+	AIProvider    string             `json:"ai_provider,omitempty"` // 产生该结果的AI提供商
+	AIModel       string             `json:"ai_model,omitempty"`
+	Ensemble      *EnsembleReport    `json:"ensemble,omitempty"` // 集成投票详情（含各成员原始回答）
+	Timestamp     time.Time          `json:"timestamp"`
 }
 
 // Analyze 执行单次分析
 func (a *StockAnalyzer) Analyze() (*AnalysisResult, error) {
+	return a.AnalyzeContext(context.Background())
+}
+
+// AnalyzeContext 执行单次分析，ctx取消或超过AnalysisTimeout时中断行情请求、AI调用和通知发送
+// 如果已有分析正在执行，会等待其结束后再执行
+func (a *StockAnalyzer) AnalyzeContext(ctx context.Context) (*AnalysisResult, error) {
+	a.runMu.Lock()
+	defer a.runMu.Unlock()
+	return a.analyze(ctx)
+}
+
+// TryAnalyzeContext 执行单次分析，如果已有分析正在执行则立即返回ErrAnalysisInProgress
+func (a *StockAnalyzer) TryAnalyzeContext(ctx context.Context) (*AnalysisResult, error) {
+	if !a.runMu.TryLock() {
+		return nil, ErrAnalysisInProgress
+	}
+	defer a.runMu.Unlock()
+	return a.analyze(ctx)
+}
+
+// analyze 执行单次分析（调用方需持有runMu）
+func (a *StockAnalyzer) analyze(ctx context.Context) (*AnalysisResult, error) {
+	var cancel context.CancelFunc
+	if a.AnalysisConfig.AnalysisTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, a.AnalysisConfig.AnalysisTimeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	a.cancelMu.Lock()
+	a.cancelFunc = cancel
+	a.cancelMu.Unlock()
+	defer func() {
+		a.cancelMu.Lock()
+		a.cancelFunc = nil
+		a.cancelMu.Unlock()
+	}()
+
 	// 0. 检查是否在交易时间内
 	if a.TradingTimeChecker != nil && !a.TradingTimeChecker.IsTradingTime(time.Now()) {
 		status := a.TradingTimeChecker.GetTradingTimeStatus(time.Now())
 		log.Printf("⏸️  非交易时段，跳过分析 | 下次交易时间: %v", status["next_trading_time"])
 		return nil, fmt.Errorf("非交易时段")
 	}
+	if a.UsageTracker != nil && a.UsageTracker.BudgetExceeded() {
+		log.Printf("⏸️  %s 今日AI费用已超出预算，跳过分析", a.AnalysisConfig.StockCode)
+		return nil, ErrBudgetExceeded
+	}
 
 	log.Printf("📊 开始分析股票 %s(%s)...", a.AnalysisConfig.StockName, a.AnalysisConfig.StockCode)
 
 	// 1. 获取实时行情
-	quote, err := a.TDXClient.GetQuote(a.AnalysisConfig.StockCode)
+	quote, err := a.TDXClient.GetQuoteContext(ctx, a.AnalysisConfig.StockCode)
 	if err != nil {
 		return nil, fmt.Errorf("获取行情失败: %w", err)
 	}
 
 	// 2. 获取日K线数据（最近60天）
-	dayKline, err := a.TDXClient.GetKline(a.AnalysisConfig.StockCode, "day", 60)
+	dayKline, err := a.TDXClient.GetKlineContext(ctx, a.AnalysisConfig.StockCode, "day", 60)
 	if err != nil {
 		return nil, fmt.Errorf("获取日K线失败: %w", err)
 	}
 
 	// 3. 获取30分钟K线数据（最近100条）
-	min30Kline, err := a.TDXClient.GetKline(a.AnalysisConfig.StockCode, "minute30", 100)
+	min30Kline, err := a.TDXClient.GetKlineContext(ctx, a.AnalysisConfig.StockCode, "minute30", 100)
 	if err != nil {
 		return nil, fmt.Errorf("获取30分钟K线失败: %w", err)
 	}
 
 	// 4. 获取今日分时数据
-	minuteData, err := a.TDXClient.GetMinute(a.AnalysisConfig.StockCode, "")
+	minuteData, err := a.TDXClient.GetMinuteContext(ctx, a.AnalysisConfig.StockCode, "")
 	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("分析已取消: %w", ctx.Err())
+		}
 		log.Printf("⚠️  获取分时数据失败（可能非交易时间）: %v", err)
 		minuteData = nil // 非交易时间可能获取不到，设为nil
 	}
 
 	// 5. 计算技术指标
-	technicalData := a.calculateTechnicalIndicators(quote, dayKline, min30Kline)
+	input := &AnalysisInput{
+		Time:       time.Now(),
+		Quote:      quote,
+		DayKline:   dayKline,
+		Min30Kline: min30Kline,
+		Minute:     minuteData,
+		Technical:  CalculateTechnicalSnapshot(quote, dayKline),
+	}
+	technicalData := input.Technical
 
 	// 6. 构建AI分析提示词
-	prompt := a.buildAnalysisPrompt(quote, dayKline, min30Kline, minuteData, technicalData)
+	systemPrompt, prompt, err := a.BuildPrompts(input)
+	if err != nil {
+		return nil, err
+	}
+
+	// 7. 调用AI进行分析并解析响应
+	var result *AnalysisResult
+	if a.Ensemble != nil {
+		result, err = a.analyzeEnsemble(ctx, systemPrompt, prompt, technicalData)
+	} else {
+		result, err = a.analyzeSingle(ctx, systemPrompt, prompt, quote, technicalData)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 9. 保存分析结果
+	if a.ResultStore != nil {
+		if err := a.ResultStore.Save(result); err != nil {
+			log.Printf("⚠️  保存分析结果失败: %v", err)
+		}
+	}
+
+	// 10. 发送通知（如果启用且信心度达到阈值），并跟踪信号的后续表现
+	if result.Confidence >= a.AnalysisConfig.MinConfidence &&
+		(result.Signal == "BUY" || result.Signal == "SELL") {
+		a.emitSignal(ctx, result)
+	} else if a.SignalGate != nil {
+		a.SignalGate.Observe(result)
+	}
+
+	return result, nil
+}
 
-	// 7. 调用AI进行分析
+// analyzeSingle 调用单个AI客户端（可能包含故障转移）做决策
+func (a *StockAnalyzer) analyzeSingle(ctx context.Context, systemPrompt, prompt string, quote *QuoteData, technical *TechnicalSnapshot) (*AnalysisResult, error) {
 	log.Printf("🤖 调用AI进行深度分析...")
-	systemPrompt := "你是一位专业的A股分析师，精通技术分析和市场研判。"
-	aiResponse, err := a.MCPClient.CallWithMessages(systemPrompt, prompt)
+	aiResponse, err := a.MCPClient.ChatContext(ctx, systemPrompt, prompt)
 	if err != nil {
 		return nil, fmt.Errorf("AI分析失败: %w", err)
 	}
+	if a.UsageTracker != nil {
+		a.UsageTracker.Record(a.AnalysisConfig.StockCode, aiResponse.Provider, aiResponse.Model, aiResponse.Usage)
+	}
 
 	// 8. 解析AI响应
-	result, err := a.parseAIResponse(aiResponse, quote, technicalData)
+	result, err := a.parseAIResponse(aiResponse.Content, quote, technical)
 	if err != nil {
-		return nil, fmt.Errorf("解析AI响应失败: %w", err)
+		return nil, fmt.Errorf("解析AI响应失败(%s): %w", aiResponse.Provider, err)
 	}
+	result.AIProvider = aiResponse.Provider
+	result.AIModel = aiResponse.Model
+	return result, nil
+}
 
-	// 9. 发送通知（如果启用且信心度达到阈值）
-	if a.AnalysisConfig.EnableNotification &&
-		result.Confidence >= a.AnalysisConfig.MinConfidence &&
-		(result.Signal == "BUY" || result.Signal == "SELL") {
-		a.sendNotification(result)
+// analyzeEnsemble 使用集成投票做决策
+func (a *StockAnalyzer) analyzeEnsemble(ctx context.Context, systemPrompt, prompt string, technical *TechnicalSnapshot) (*AnalysisResult, error) {
+	log.Printf("🤖 调用%d个AI成员进行集成投票...", len(a.Ensemble.Members))
+	decision, report, err := a.Ensemble.Decide(ctx, systemPrompt, prompt)
+	if a.UsageTracker != nil && report != nil {
+		for _, vote := range report.Votes {
+			if vote.Provider != "" {
+				a.UsageTracker.Record(a.AnalysisConfig.StockCode, vote.Provider, vote.Model, vote.Usage)
+			}
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("AI分析失败: %w", err)
 	}
 
+	result := a.decisionResult(decision, technical)
+	result.AIProvider = a.Ensemble.ProviderName()
+	result.Ensemble = report
 	return result, nil
 }
 
-// calculateTechnicalIndicators 计算技术指标
-func (a *StockAnalyzer) calculateTechnicalIndicators(quote *QuoteData, dayKline *KlineData, min30Kline *KlineData) map[string]interface{} {
-	data := make(map[string]interface{})
+// CancelAnalysis 取消当前进行中的分析，没有进行中的分析时返回false
+func (a *StockAnalyzer) CancelAnalysis() bool {
+	a.cancelMu.Lock()
+	defer a.cancelMu.Unlock()
+
+	if a.cancelFunc == nil {
+		return false
+	}
+	a.cancelFunc()
+	return true
+}
+
+// AnalysisInput 单次分析使用的行情数据
+// 实时分析时为当前行情；回测时为按历史K线重建的某一时刻的行情
+type AnalysisInput struct {
+	Time       time.Time // 分析时间
+	Quote      *QuoteData
+	DayKline   *KlineData
+	Min30Kline *KlineData
+	Minute     *MinuteData // 非交易时间或回测时可能为nil
+	Technical  *TechnicalSnapshot
+}
 
-	// 当前价格信息
-	currentPrice := PriceToYuan(quote.K.Close)
-	data["current_price"] = currentPrice
-	data["open_price"] = PriceToYuan(quote.K.Open)
-	data["high_price"] = PriceToYuan(quote.K.High)
-	data["low_price"] = PriceToYuan(quote.K.Low)
-	data["prev_close"] = PriceToYuan(quote.K.Last)
+// CalculateTechnicalSnapshot 计算技术指标
+// 数据不足的指标保持为nil，由提示词渲染为"数据不足"
+func CalculateTechnicalSnapshot(quote *QuoteData, dayKline *KlineData) *TechnicalSnapshot {
+	data := &TechnicalSnapshot{
+		CurrentPrice: PriceToYuan(quote.K.Close),
+		OpenPrice:    PriceToYuan(quote.K.Open),
+		HighPrice:    PriceToYuan(quote.K.High),
+		LowPrice:     PriceToYuan(quote.K.Low),
+		PrevClose:    PriceToYuan(quote.K.Last),
+		Volume:       VolumeToShares(quote.TotalHand),
+		Amount:       AmountToYuan(quote.Amount),
+	}
 
 	// 涨跌幅
 	if quote.K.Last > 0 {
 		changePercent := (float64(quote.K.Close-quote.K.Last) / float64(quote.K.Last)) * 100
-		data["change_percent"] = fmt.Sprintf("%.2f%%", changePercent)
+		data.ChangePercent = indicators.Float(changePercent)
 	}
 
-	// 成交量和成交额
-	data["volume"] = VolumeToShares(quote.TotalHand)
-	data["amount"] = AmountToYuan(quote.Amount)
-
 	// 内外盘比
 	if quote.InsideDish+quote.OuterDisc > 0 {
 		outerRatio := float64(quote.OuterDisc) / float64(quote.InsideDish+quote.OuterDisc) * 100
-		data["outer_ratio"] = fmt.Sprintf("%.1f%%", outerRatio)
+		data.OuterRatio = indicators.Float(outerRatio)
 	}
 
 	// 买卖盘力度
-	if len(quote.BuyLevel) > 0 && len(quote.SellLevel) > 0 {
-		buyPower := 0
-		sellPower := 0
-		for _, level := range quote.BuyLevel {
-			buyPower += level.Number
-		}
-		for _, level := range quote.SellLevel {
-			sellPower += level.Number
-		}
-		data["buy_sell_ratio"] = fmt.Sprintf("%.2f", float64(buyPower)/float64(sellPower))
+	buyPower := 0
+	sellPower := 0
+	for _, level := range quote.BuyLevel {
+		buyPower += level.Number
+	}
+	for _, level := range quote.SellLevel {
+		sellPower += level.Number
+	}
+	if sellPower > 0 {
+		data.BuySellRatio = indicators.Float(float64(buyPower) / float64(sellPower))
 	}
 
-	// 日K线指标（简化版MA和趋势）
+	// 日K线指标
 	// 注意：K线数据List按时间升序排列，List[0]是最旧的，List[len-1]是最新的
-	// 因此计算MA时需要从末尾开始取数据
-	if len(dayKline.List) >= 5 {
-		listLen := len(dayKline.List)
-
-		// 计算5日均价（使用最近5天）
-		sum5 := 0
-		for i := listLen - 5; i < listLen; i++ {
-			sum5 += dayKline.List[i].Close
-		}
-		ma5 := PriceToYuan(sum5 / 5)
-		data["ma5"] = ma5
-
-		// 计算10日均价
-		if len(dayKline.List) >= 10 {
-			sum10 := 0
-			for i := listLen - 10; i < listLen; i++ {
-				sum10 += dayKline.List[i].Close
-			}
-			ma10 := PriceToYuan(sum10 / 10)
-			data["ma10"] = ma10
-		}
-
-		// 计算20日均价
-		if len(dayKline.List) >= 20 {
-			sum20 := 0
-			for i := listLen - 20; i < listLen; i++ {
-				sum20 += dayKline.List[i].Close
-			}
-			ma20 := PriceToYuan(sum20 / 20)
-			data["ma20"] = ma20
-		}
-
-		// 计算60日均价（季线）
-		if len(dayKline.List) >= 60 {
-			sum60 := 0
-			for i := listLen - 60; i < listLen; i++ {
-				sum60 += dayKline.List[i].Close
-			}
-			ma60 := PriceToYuan(sum60 / 60)
-			data["ma60"] = ma60
-		}
+	if dayKline == nil {
+		return data
 	}
+	bars := KlineToBars(dayKline.List)
+	closes := indicators.Closes(bars)
 
-	// 计算简化RSI（相对强弱指标）
-	if len(dayKline.List) >= 14 {
-		rsi14 := a.calculateRSI(dayKline.List, 14)
-		data["rsi14"] = fmt.Sprintf("%.2f", rsi14)
+	// 均线
+	data.MA5 = indicators.LastPtr(indicators.SMA(closes, 5))
+	data.MA10 = indicators.LastPtr(indicators.SMA(closes, 10))
+	data.MA20 = indicators.LastPtr(indicators.SMA(closes, 20))
+	data.MA60 = indicators.LastPtr(indicators.SMA(closes, 60))
+	if len(closes) >= 12 {
+		data.EMA12 = indicators.LastPtr(indicators.EMA(closes, 12))
 	}
-
-	// 计算近期波动率
-	if len(dayKline.List) >= 20 {
-		volatility := a.calculateVolatility(dayKline.List, 20)
-		data["volatility_20d"] = fmt.Sprintf("%.2f%%", volatility*100)
+	if len(closes) >= 26 {
+		data.EMA26 = indicators.LastPtr(indicators.EMA(closes, 26))
 	}
 
-	return data
-}
+	// MACD(12,26,9)，EMA需要足够的预热数据才稳定
+	if len(closes) >= 35 {
+		macd := indicators.MACD(closes, 12, 26, 9)
+		data.MACDDIF = indicators.LastPtr(macd.DIF)
+		data.MACDDEA = indicators.LastPtr(macd.DEA)
+		data.MACDHist = indicators.LastPtr(macd.Hist)
+	}
 
-// calculateRSI 计算RSI指标（简化版）
-func (a *StockAnalyzer) calculateRSI(klines []KlineItem, period int) float64 {
-	if len(klines) < period+1 {
-		return 50.0 // 数据不足返回中性值
+	// KDJ(9,3,3)
+	if len(bars) >= 9 {
+		kdj := indicators.KDJ(bars, 9, 3, 3)
+		data.KDJK = indicators.LastPtr(kdj.K)
+		data.KDJD = indicators.LastPtr(kdj.D)
+		data.KDJJ = indicators.LastPtr(kdj.J)
 	}
 
-	gains := 0.0
-	losses := 0.0
+	// BOLL(20,2)
+	boll := indicators.Boll(closes, 20, 2)
+	data.BollUpper = indicators.LastPtr(boll.Upper)
+	data.BollMid = indicators.LastPtr(boll.Mid)
+	data.BollLower = indicators.LastPtr(boll.Lower)
 
-	// K线数据按时间升序排列，从末尾往前计算最近period天的RSI
-	listLen := len(klines)
-	for i := listLen - period; i < listLen; i++ {
-		// 当前K线的收盘价与前一根K线的收盘价比较
-		if i > 0 {
-			change := float64(klines[i].Close - klines[i-1].Close)
-			if change > 0 {
-				gains += change
-			} else {
-				losses += -change
-			}
-		}
+	// ATR(14)、CCI(14)、OBV
+	data.ATR14 = indicators.LastPtr(indicators.ATR(bars, 14))
+	data.CCI14 = indicators.LastPtr(indicators.CCI(bars, 14))
+	if len(bars) >= 2 {
+		data.OBV = indicators.LastPtr(indicators.OBV(bars))
 	}
 
-	avgGain := gains / float64(period)
-	avgLoss := losses / float64(period)
+	// RSI(14)，Wilder平滑
+	data.RSI14 = indicators.LastPtr(indicators.RSI(closes, 14))
 
-	if avgLoss == 0 {
-		return 100.0
+	// 计算近期波动率
+	if len(dayKline.List) >= 21 {
+		volatility := calculateVolatility(dayKline.List, 20)
+		data.Volatility20d = indicators.Float(volatility * 100)
 	}
 
-	rs := avgGain / avgLoss
-	rsi := 100 - (100 / (1 + rs))
-
-	return rsi
+	return data
 }
 
 // calculateVolatility 计算波动率（标准差）
-func (a *StockAnalyzer) calculateVolatility(klines []KlineItem, period int) float64 {
+func calculateVolatility(klines []KlineItem, period int) float64 {
 	if len(klines) < period+1 {
 		return 0
 	}
@@ -295,8 +408,52 @@ func (a *StockAnalyzer) calculateVolatility(klines []KlineItem, period int) floa
 	return math.Sqrt(variance)
 }
 
+// BuildPrompts 生成系统提示词和分析提示词，未配置模板的部分使用内置提示词
+func (a *StockAnalyzer) BuildPrompts(input *AnalysisInput) (string, string, error) {
+	if input.Technical == nil {
+		input.Technical = CalculateTechnicalSnapshot(input.Quote, input.DayKline)
+	}
+
+	systemPrompt := DefaultSystemPrompt
+	userPrompt := ""
+
+	if a.PromptTemplate != nil {
+		system, user, err := a.PromptTemplate.Render(&PromptData{
+			StockCode:    a.AnalysisConfig.StockCode,
+			StockName:    a.AnalysisConfig.StockName,
+			AnalysisTime: input.Time,
+			Quote:        input.Quote,
+			DayKline:     input.DayKline,
+			Min30Kline:   input.Min30Kline,
+			Minute:       input.Minute,
+			Technical:    input.Technical,
+		})
+		if err != nil {
+			return "", "", fmt.Errorf("生成提示词失败: %w", err)
+		}
+		if a.PromptTemplate.SystemFile != "" {
+			systemPrompt = system
+		}
+		userPrompt = user
+	}
+
+	if userPrompt == "" {
+		userPrompt = a.buildAnalysisPrompt(input)
+	}
+	return systemPrompt, userPrompt, nil
+}
+
 // buildAnalysisPrompt 构建AI分析提示词
-func (a *StockAnalyzer) buildAnalysisPrompt(quote *QuoteData, dayKline *KlineData, min30Kline *KlineData, minuteData *MinuteData, technical map[string]interface{}) string {
+func (a *StockAnalyzer) buildAnalysisPrompt(input *AnalysisInput) string {
+	quote, dayKline, technical := input.Quote, input.DayKline, input.Technical
+	if dayKline == nil {
+		dayKline = &KlineData{}
+	}
+	min30Kline := input.Min30Kline
+	if min30Kline == nil {
+		min30Kline = &KlineData{}
+	}
+
 	prompt := fmt.Sprintf(`# 股票深度分析任务
 
 你是一位专业的A股分析师，请对以下股票进行深度技术分析，并给出明确的操作建议。
@@ -323,17 +480,17 @@ func (a *StockAnalyzer) buildAnalysisPrompt(quote *QuoteData, dayKline *KlineDat
 `,
 		a.AnalysisConfig.StockCode,
 		a.AnalysisConfig.StockName,
-		time.Now().Format("2006-01-02 15:04:05"),
-		technical["current_price"].(float64),
-		technical["open_price"].(float64),
-		technical["high_price"].(float64),
-		technical["low_price"].(float64),
-		technical["prev_close"].(float64),
-		technical["change_percent"].(string),
-		technical["volume"].(int64),
-		AmountToYuan(quote.Amount)/10000,
-		technical["outer_ratio"].(string),
-		technical["buy_sell_ratio"].(string),
+		input.Time.Format("2006-01-02 15:04:05"),
+		technical.CurrentPrice,
+		technical.OpenPrice,
+		technical.HighPrice,
+		technical.LowPrice,
+		technical.PrevClose,
+		indicators.FormatOptional(technical.ChangePercent, "%.2f%%"),
+		technical.Volume,
+		technical.Amount/10000,
+		indicators.FormatOptional(technical.OuterRatio, "%.1f%%"),
+		indicators.FormatOptional(technical.BuySellRatio, "%.2f"),
 	)
 
 	// 添加买五档
@@ -350,28 +507,55 @@ func (a *StockAnalyzer) buildAnalysisPrompt(quote *QuoteData, dayKline *KlineDat
 	// 添加技术指标
 	prompt += fmt.Sprintf(`
 ## 技术指标
-- **MA5**: %.2f元
-- **MA10**: %.2f元
-- **MA20**: %.2f元
-- **MA60**: %.2f元（季线）
+- **MA5**: %s
+- **MA10**: %s
+- **MA20**: %s
+- **MA60**: %s（季线）
 - **RSI(14)**: %s
 - **近20日波动率**: %s
 
+**趋势与摆动指标（日线）**:
+- **EMA12/EMA26**: %s / %s
+- **MACD(12,26,9)**: DIF=%s, DEA=%s, MACD柱=%s
+- **KDJ(9,3,3)**: K=%s, D=%s, J=%s
+- **BOLL(20,2)**: 上轨=%s, 中轨=%s, 下轨=%s
+- **ATR(14)**: %s
+- **CCI(14)**: %s
+- **OBV**: %s
+
 `,
-		technical["ma5"].(float64),
-		technical["ma10"].(float64),
-		technical["ma20"].(float64),
-		technical["ma60"].(float64),
-		technical["rsi14"].(string),
-		technical["volatility_20d"].(string),
+		indicators.FormatOptional(technical.MA5, "%.2f元"),
+		indicators.FormatOptional(technical.MA10, "%.2f元"),
+		indicators.FormatOptional(technical.MA20, "%.2f元"),
+		indicators.FormatOptional(technical.MA60, "%.2f元"),
+		indicators.FormatOptional(technical.RSI14, "%.2f"),
+		indicators.FormatOptional(technical.Volatility20d, "%.2f%%"),
+		indicators.FormatOptional(technical.EMA12, "%.2f元"),
+		indicators.FormatOptional(technical.EMA26, "%.2f元"),
+		indicators.FormatOptional(technical.MACDDIF, "%.3f"),
+		indicators.FormatOptional(technical.MACDDEA, "%.3f"),
+		indicators.FormatOptional(technical.MACDHist, "%.3f"),
+		indicators.FormatOptional(technical.KDJK, "%.2f"),
+		indicators.FormatOptional(technical.KDJD, "%.2f"),
+		indicators.FormatOptional(technical.KDJJ, "%.2f"),
+		indicators.FormatOptional(technical.BollUpper, "%.2f元"),
+		indicators.FormatOptional(technical.BollMid, "%.2f元"),
+		indicators.FormatOptional(technical.BollLower, "%.2f元"),
+		indicators.FormatOptional(technical.ATR14, "%.2f元"),
+		indicators.FormatOptional(technical.CCI14, "%.2f"),
+		indicators.FormatOptional(technical.OBV, "%.0f手"),
 	)
 
-	// 添加K线概况
+	// 添加K线概况（回测等场景可能没有日K线）
+	dayKlineSummary := "数据不足"
+	if len(dayKline.List) > 0 {
+		dayKlineSummary = fmt.Sprintf("最近%d个交易日数据", len(dayKline.List))
+	}
 	prompt += fmt.Sprintf(`## K线数据概况
-- **日K线**: 最近%d个交易日数据
+- **日K线**: %s
 - **30分钟K线**: 最近%d条数据
 `,
-		len(dayKline.List),
+		dayKlineSummary,
 		len(min30Kline.List),
 	)
 
@@ -398,7 +582,7 @@ func (a *StockAnalyzer) buildAnalysisPrompt(quote *QuoteData, dayKline *KlineDat
 1. **趋势分析**: 当前价格与均线的关系，是否处于上升/下降/盘整趋势
 2. **量价关系**: 成交量的变化是否支持价格走势
 3. **盘口分析**: 买卖盘力量对比，大单情况
-4. **技术指标**: RSI是否超买超卖，均线排列情况
+4. **技术指标**: RSI/KDJ是否超买超卖，MACD金叉死叉与背离，布林带位置，均线排列情况
 5. **风险评估**: 当前位置的风险收益比
 
 ## 输出格式
@@ -429,7 +613,7 @@ func (a *StockAnalyzer) buildAnalysisPrompt(quote *QuoteData, dayKline *KlineDat
 }
 
 // parseAIResponse 解析AI响应
-func (a *StockAnalyzer) parseAIResponse(aiResponse string, quote *QuoteData, technical map[string]interface{}) (*AnalysisResult, error) {
+func (a *StockAnalyzer) parseAIResponse(aiResponse string, quote *QuoteData, technical *TechnicalSnapshot) (*AnalysisResult, error) {
 	// 1. 解析AI响应中的JSON决策
 	aiDecision, err := ParseAIResponse(aiResponse)
 	if err != nil {
@@ -440,7 +624,7 @@ func (a *StockAnalyzer) parseAIResponse(aiResponse string, quote *QuoteData, tec
 		return &AnalysisResult{
 			StockCode:     a.AnalysisConfig.StockCode,
 			StockName:     a.AnalysisConfig.StockName,
-			CurrentPrice:  technical["current_price"].(float64),
+			CurrentPrice:  technical.CurrentPrice,
 			Signal:        "HOLD",
 			Confidence:    30,
 			Reasoning:     fmt.Sprintf("AI响应解析失败，建议观望。原始响应: %s", aiResponse),
@@ -449,8 +633,13 @@ func (a *StockAnalyzer) parseAIResponse(aiResponse string, quote *QuoteData, tec
 		}, nil
 	}
 
+	return a.decisionResult(aiDecision, technical), nil
+}
+
+// decisionResult 验证AI决策并转换为分析结果
+func (a *StockAnalyzer) decisionResult(aiDecision *AIDecisionResponse, technical *TechnicalSnapshot) *AnalysisResult {
 	// 2. 验证决策合理性
-	currentPrice := technical["current_price"].(float64)
+	currentPrice := technical.CurrentPrice
 	warnings := ValidateDecision(aiDecision, currentPrice)
 	if len(warnings) > 0 {
 		log.Printf("⚠️  决策验证警告:")
@@ -481,13 +670,39 @@ func (a *StockAnalyzer) parseAIResponse(aiResponse string, quote *QuoteData, tec
 			result.TargetPrice, result.StopLoss, result.RiskReward)
 	}
 
-	return result, nil
+	return result
+}
+
+// emitSignal 通过去重检查后发送通知并跟踪信号
+// 通知发送失败时不记录去重状态，下次扫描会重新发送
+func (a *StockAnalyzer) emitSignal(ctx context.Context, result *AnalysisResult) {
+	if a.SignalGate != nil {
+		ok, reason := a.SignalGate.Check(result)
+		if !ok {
+			log.Printf("🔕 %s信号不重复通知: %s", result.Signal, reason)
+			return
+		}
+		log.Printf("🔔 %s信号需要通知: %s", result.Signal, reason)
+	}
+
+	if a.AnalysisConfig.EnableNotification {
+		if err := a.sendNotification(ctx, result); err != nil {
+			return
+		}
+	}
+
+	if a.SignalGate != nil {
+		a.SignalGate.Record(result)
+	}
+	if a.SignalTracker != nil {
+		a.SignalTracker.Track(result)
+	}
 }
 
 // sendNotification 发送通知
-func (a *StockAnalyzer) sendNotification(result *AnalysisResult) {
+func (a *StockAnalyzer) sendNotification(ctx context.Context, result *AnalysisResult) error {
 	if a.Notifier == nil {
-		return
+		return nil
 	}
 
 	signal := &notifier.TradingSignal{
@@ -504,15 +719,26 @@ func (a *StockAnalyzer) sendNotification(result *AnalysisResult) {
 		TechnicalData: result.TechnicalData,
 	}
 
-	if err := a.Notifier.SendSignal(signal); err != nil {
+	if err := notifier.SendSignalContext(ctx, a.Notifier, signal); err != nil {
 		log.Printf("❌ 发送通知失败: %v", err)
-	} else {
-		log.Printf("✅ 已发送%s信号通知", result.Signal)
+		return err
 	}
+	log.Printf("✅ 已发送%s信号通知", result.Signal)
+	return nil
 }
 
-// StartMonitoring 启动持续监控
+// StartMonitoring 启动持续监控，stopChan关闭时会同时中断进行中的分析
 func (a *StockAnalyzer) StartMonitoring(stopChan <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stopChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
 	ticker := time.NewTicker(a.AnalysisConfig.ScanInterval)
 	defer ticker.Stop()
 
@@ -522,19 +748,29 @@ func (a *StockAnalyzer) StartMonitoring(stopChan <-chan struct{}) {
 		a.AnalysisConfig.ScanInterval)
 
 	// 立即执行一次分析
-	if _, err := a.Analyze(); err != nil {
-		log.Printf("❌ 分析失败: %v", err)
-	}
+	a.runScheduled(ctx)
 
 	for {
 		select {
 		case <-ticker.C:
-			if _, err := a.Analyze(); err != nil {
-				log.Printf("❌ 分析失败: %v", err)
-			}
+			a.runScheduled(ctx)
 		case <-stopChan:
 			log.Printf("⏹️  停止监控股票 %s", a.AnalysisConfig.StockCode)
 			return
 		}
 	}
 }
+
+// runScheduled 执行一次定时分析，手动触发的分析仍在执行时跳过本轮
+func (a *StockAnalyzer) runScheduled(ctx context.Context) {
+	if _, err := a.TryAnalyzeContext(ctx); err != nil {
+		if errors.Is(err, ErrAnalysisInProgress) {
+			log.Printf("⏭️  %s 已有分析正在执行，跳过本轮定时分析", a.AnalysisConfig.StockCode)
+			return
+		}
+		if errors.Is(err, ErrBudgetExceeded) {
+			return
+		}
+		log.Printf("❌ 分析失败: %v", err)
+	}
+}
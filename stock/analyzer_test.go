@@ -0,0 +1,44 @@
+package stock
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildPromptsWithoutDayKline(t *testing.T) {
+	tmpl, err := LoadPromptTemplate("", "../prompts/analysis.tmpl")
+	if err != nil {
+		t.Fatalf("加载模板失败: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		template *PromptTemplate
+	}{
+		{"内置提示词", nil},
+		{"模板提示词", tmpl},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &StockAnalyzer{
+				AnalysisConfig: &AnalysisConfig{StockCode: "600519", StockName: "贵州茅台"},
+				PromptTemplate: tt.template,
+			}
+			input := &AnalysisInput{
+				Time:  time.Date(2024, 3, 7, 10, 0, 0, 0, cst),
+				Quote: &QuoteData{},
+			}
+			_, prompt, err := a.BuildPrompts(input)
+			if err != nil {
+				t.Fatalf("生成提示词失败: %v", err)
+			}
+			if !strings.Contains(prompt, "- **日K线**: 数据不足") {
+				t.Errorf("缺少日K线时应显示数据不足")
+			}
+			if strings.Contains(prompt, "近5日收盘价趋势") {
+				t.Errorf("缺少日K线时不应输出近5日趋势")
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package stock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"nofx/mcp"
+	"strings"
+	"sync"
+)
+
+// 集成投票方式
+const (
+	EnsembleVotingMajority = "majority" // 每票权重相同
+	EnsembleVotingWeighted = "weighted" // 按成员权重计票
+)
+
+// EnsembleMember 集成投票成员
+type EnsembleMember struct {
+	Name    string
+	Client  mcp.AIClient
+	Weight  float64 // 投票权重，<=0时按1计算
+	Samples int     // 同一提示词的采样次数，<=0时按1计算
+}
+
+// EnsembleVote 单个成员单次采样的回答（保留原始响应用于审计）
+type EnsembleVote struct {
+	Member      string    `json:"member"`
+	Provider    string    `json:"provider,omitempty"`
+	Model       string    `json:"model,omitempty"`
+	Weight      float64   `json:"weight"`
+	Signal      string    `json:"signal,omitempty"`
+	Confidence  int       `json:"confidence,omitempty"`
+	TargetPrice float64   `json:"target_price,omitempty"`
+	StopLoss    float64   `json:"stop_loss,omitempty"`
+	Raw         string    `json:"raw,omitempty"`
+	Usage       mcp.Usage `json:"usage"`
+	Error       string    `json:"error,omitempty"` // 调用或解析失败的原因，失败的回答不参与投票
+}
+
+// EnsembleReport 集成投票结果
+type EnsembleReport struct {
+	Voting     string         `json:"voting"`
+	Agreement  float64        `json:"agreement"`  // 获胜信号的得票占比（0-1）
+	Confidence int            `json:"confidence"` // 获胜成员的平均信心度（未按一致度折算）
+	Votes      []EnsembleVote `json:"votes"`
+}
+
+// Ensemble 将同一提示词发送给多个模型（或同一模型多次采样），按多数/加权投票汇总决策
+// 最终信心度 = 获胜成员的平均信心度 × 一致度；目标价和止损价取获胜成员的加权平均
+type Ensemble struct {
+	Members []EnsembleMember
+	Voting  string // majority（默认）或 weighted
+}
+
+// NewEnsemble 创建集成投票
+func NewEnsemble(members []EnsembleMember, voting string) (*Ensemble, error) {
+	if len(members) == 0 {
+		return nil, fmt.Errorf("集成投票至少需要一个成员")
+	}
+	if voting == "" {
+		voting = EnsembleVotingMajority
+	}
+	if voting != EnsembleVotingMajority && voting != EnsembleVotingWeighted {
+		return nil, fmt.Errorf("不支持的投票方式: %s", voting)
+	}
+	return &Ensemble{Members: members, Voting: voting}, nil
+}
+
+// Decide 并发调用所有成员并投票，没有任何有效回答或ctx取消时返回错误（report始终包含各成员的回答）
+func (e *Ensemble) Decide(ctx context.Context, systemPrompt, userPrompt string) (*AIDecisionResponse, *EnsembleReport, error) {
+	var votes []EnsembleVote
+	var clients []mcp.AIClient
+	for _, member := range e.Members {
+		weight := member.Weight
+		if weight <= 0 || e.Voting == EnsembleVotingMajority {
+			weight = 1
+		}
+		samples := member.Samples
+		if samples <= 0 {
+			samples = 1
+		}
+		for i := 0; i < samples; i++ {
+			votes = append(votes, EnsembleVote{Member: member.Name, Weight: weight})
+			clients = append(clients, member.Client)
+		}
+	}
+
+	decisions := make([]*AIDecisionResponse, len(votes))
+	var wg sync.WaitGroup
+	for i := range votes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			vote := &votes[i]
+			resp, err := clients[i].ChatContext(ctx, systemPrompt, userPrompt)
+			if err != nil {
+				vote.Error = err.Error()
+				return
+			}
+			vote.Provider = resp.Provider
+			vote.Model = resp.Model
+			vote.Raw = resp.Content
+			vote.Usage = resp.Usage
+			decision, err := ParseAIResponse(resp.Content)
+			if err != nil {
+				vote.Error = err.Error()
+				return
+			}
+			vote.Signal = decision.Signal
+			vote.Confidence = decision.Confidence
+			vote.TargetPrice = decision.TargetPrice
+			vote.StopLoss = decision.StopLoss
+			decisions[i] = decision
+		}()
+	}
+	wg.Wait()
+
+	// 取消时也返回已完成的回答，调用方据此记录已产生的token用量
+	report := &EnsembleReport{Voting: e.Voting, Votes: votes}
+	if ctx.Err() != nil {
+		return nil, report, fmt.Errorf("AI分析已取消: %w", ctx.Err())
+	}
+
+	decision, err := e.aggregate(votes, decisions, report)
+	if err != nil {
+		return nil, report, err
+	}
+	return decision, report, nil
+}
+
+// aggregate 按投票汇总决策
+func (e *Ensemble) aggregate(votes []EnsembleVote, decisions []*AIDecisionResponse, report *EnsembleReport) (*AIDecisionResponse, error) {
+	scores := make(map[string]float64)
+	total := 0.0
+	var errs []error
+	for i, decision := range decisions {
+		if decision == nil {
+			errs = append(errs, fmt.Errorf("%s: %s", votes[i].Member, votes[i].Error))
+			continue
+		}
+		scores[decision.Signal] += votes[i].Weight
+		total += votes[i].Weight
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("集成投票没有有效回答: %w", errors.Join(errs...))
+	}
+
+	// 得票最多的信号获胜；平票时偏向保守：HOLD优先，BUY与SELL平票时为HOLD
+	winner := "HOLD"
+	best := scores["HOLD"]
+	for _, signal := range []string{"BUY", "SELL"} {
+		if scores[signal] > best {
+			winner, best = signal, scores[signal]
+		}
+	}
+	if winner != "HOLD" && scores["BUY"] == scores["SELL"] {
+		winner, best = "HOLD", scores["HOLD"]
+	}
+	agreement := best / total
+
+	// 获胜成员的加权平均信心度、目标价、止损价，理由取信心度最高的成员
+	var representative *AIDecisionResponse
+	confidenceSum, targetSum, targetWeight, stopSum, stopWeight := 0.0, 0.0, 0.0, 0.0, 0.0
+	for i, decision := range decisions {
+		if decision == nil || decision.Signal != winner {
+			continue
+		}
+		weight := votes[i].Weight
+		confidenceSum += float64(decision.Confidence) * weight
+		if decision.TargetPrice > 0 {
+			targetSum += decision.TargetPrice * weight
+			targetWeight += weight
+		}
+		if decision.StopLoss > 0 {
+			stopSum += decision.StopLoss * weight
+			stopWeight += weight
+		}
+		if representative == nil || decision.Confidence > representative.Confidence {
+			representative = decision
+		}
+	}
+
+	result := &AIDecisionResponse{Signal: winner}
+	reasoning := "成员意见分歧（BUY与SELL平票），建议观望"
+	if representative != nil {
+		result.RiskReward = representative.RiskReward
+		reasoning = representative.Reasoning
+	}
+	if best > 0 {
+		report.Confidence = int(math.Round(confidenceSum / best))
+	}
+	report.Agreement = math.Round(agreement*1000) / 1000
+	result.Confidence = int(math.Round(float64(report.Confidence) * agreement))
+	if targetWeight > 0 {
+		result.TargetPrice = math.Round(targetSum/targetWeight*100) / 100
+	}
+	if stopWeight > 0 {
+		result.StopLoss = math.Round(stopSum/stopWeight*100) / 100
+	}
+
+	valid := 0
+	for _, decision := range decisions {
+		if decision != nil {
+			valid++
+		}
+	}
+	var tally []string
+	for _, signal := range []string{"BUY", "SELL", "HOLD"} {
+		if scores[signal] > 0 {
+			tally = append(tally, fmt.Sprintf("%s %g票", signal, scores[signal]))
+		}
+	}
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("【集成投票】%s 一致度%.0f%%（%s，有效回答%d/%d），平均信心度%d%%\n\n",
+		winner, agreement*100, strings.Join(tally, " / "), valid, len(votes), report.Confidence))
+	b.WriteString(reasoning)
+	result.Reasoning = b.String()
+
+	log.Printf("🗳️  集成投票: %s 一致度%.0f%% 信心度%d%%（有效回答%d/%d）",
+		winner, agreement*100, result.Confidence, valid, len(votes))
+	return result, nil
+}
+
+// ProviderName 返回参与投票的成员名称，如"ensemble(deepseek,qwen)"
+func (e *Ensemble) ProviderName() string {
+	names := make([]string, 0, len(e.Members))
+	for _, member := range e.Members {
+		names = append(names, member.Name)
+	}
+	return "ensemble(" + strings.Join(names, ",") + ")"
+}
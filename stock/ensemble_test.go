@@ -0,0 +1,237 @@
+package stock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nofx/mcp"
+	"strings"
+	"testing"
+)
+
+// stubAIClient 返回固定回答的测试AI客户端
+type stubAIClient struct {
+	content string
+	err     error
+	block   bool   // 为true时一直等待到ctx取消
+	done    func() // 返回前调用
+}
+
+func (s *stubAIClient) ProviderName() string { return "stub" }
+
+func (s *stubAIClient) ChatContext(ctx context.Context, systemPrompt, userPrompt string) (*mcp.Response, error) {
+	if s.done != nil {
+		defer s.done()
+	}
+	if s.block {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &mcp.Response{
+		Content:  s.content,
+		Provider: "stub",
+		Model:    "stub-model",
+		Usage:    mcp.Usage{PromptTokens: 100, CompletionTokens: 20, TotalTokens: 120},
+	}, nil
+}
+
+// vote 返回给定信号的AI回答，BUY带目标价和止损价
+func vote(signal string, confidence int, target, stop float64) *stubAIClient {
+	return &stubAIClient{content: fmt.Sprintf(
+		"```json\n{\"signal\":%q,\"confidence\":%d,\"reasoning\":\"%s理由\",\"target_price\":%g,\"stop_loss\":%g}\n```",
+		signal, confidence, signal, target, stop)}
+}
+
+func TestEnsembleVoting(t *testing.T) {
+	tests := []struct {
+		name           string
+		voting         string
+		members        []EnsembleMember
+		wantSignal     string
+		wantConfidence int
+		wantAgreement  float64
+		wantTarget     float64
+		wantStop       float64
+		wantReasoning  string
+	}{
+		{
+			name:   "多数票",
+			voting: EnsembleVotingMajority,
+			members: []EnsembleMember{
+				{Name: "a", Client: vote("BUY", 80, 11, 9)},
+				{Name: "b", Client: vote("BUY", 70, 12, 9.5)},
+				{Name: "c", Client: vote("SELL", 60, 0, 0)},
+			},
+			// 平均信心度75 × 一致度2/3
+			wantSignal: "BUY", wantConfidence: 50, wantAgreement: 0.667, wantTarget: 11.5, wantStop: 9.25,
+			wantReasoning: "BUY理由",
+		},
+		{
+			name:   "按权重计票",
+			voting: EnsembleVotingWeighted,
+			members: []EnsembleMember{
+				{Name: "a", Client: vote("BUY", 90, 11, 9), Weight: 3},
+				{Name: "b", Client: vote("SELL", 60, 0, 0), Weight: 1},
+				{Name: "c", Client: vote("SELL", 60, 0, 0)}, // 权重<=0按1计算
+			},
+			wantSignal: "BUY", wantConfidence: 54, wantAgreement: 0.6, wantTarget: 11, wantStop: 9,
+			wantReasoning: "BUY理由",
+		},
+		{
+			name:   "多数票忽略权重",
+			voting: EnsembleVotingMajority,
+			members: []EnsembleMember{
+				{Name: "a", Client: vote("BUY", 90, 11, 9), Weight: 3},
+				{Name: "b", Client: vote("SELL", 60, 0, 0), Weight: 1},
+				{Name: "c", Client: vote("SELL", 60, 0, 0)},
+			},
+			wantSignal: "SELL", wantConfidence: 40, wantAgreement: 0.667,
+			wantReasoning: "SELL理由",
+		},
+		{
+			name:   "HOLD与BUY平票时为HOLD",
+			voting: EnsembleVotingMajority,
+			members: []EnsembleMember{
+				{Name: "a", Client: vote("BUY", 90, 11, 9)},
+				{Name: "b", Client: vote("HOLD", 50, 0, 0)},
+			},
+			wantSignal: "HOLD", wantConfidence: 25, wantAgreement: 0.5,
+			wantReasoning: "HOLD理由",
+		},
+		{
+			name:   "三方平票时为HOLD",
+			voting: EnsembleVotingMajority,
+			members: []EnsembleMember{
+				{Name: "a", Client: vote("BUY", 90, 11, 9)},
+				{Name: "b", Client: vote("SELL", 90, 0, 0)},
+				{Name: "c", Client: vote("HOLD", 60, 0, 0)},
+			},
+			wantSignal: "HOLD", wantConfidence: 20, wantAgreement: 0.333,
+			wantReasoning: "HOLD理由",
+		},
+		{
+			name:   "BUY与SELL平票时为HOLD",
+			voting: EnsembleVotingMajority,
+			members: []EnsembleMember{
+				{Name: "a", Client: vote("BUY", 90, 11, 9)},
+				{Name: "b", Client: vote("SELL", 80, 0, 0)},
+			},
+			wantSignal: "HOLD", wantConfidence: 0, wantAgreement: 0,
+			wantReasoning: "成员意见分歧（BUY与SELL平票），建议观望",
+		},
+		{
+			name:   "解析失败和调用失败的回答不参与投票",
+			voting: EnsembleVotingMajority,
+			members: []EnsembleMember{
+				{Name: "a", Client: vote("SELL", 70, 0, 0)},
+				{Name: "b", Client: &stubAIClient{content: "我认为应该买入"}},
+				{Name: "c", Client: vote("BUY", 90, 0, 0)}, // BUY缺少目标价，解析失败
+				{Name: "d", Client: &stubAIClient{err: errors.New("429 Too Many Requests")}},
+			},
+			wantSignal: "SELL", wantConfidence: 70, wantAgreement: 1,
+			wantReasoning: "SELL理由",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := NewEnsemble(tt.members, tt.voting)
+			if err != nil {
+				t.Fatalf("创建集成投票失败: %v", err)
+			}
+			decision, report, err := e.Decide(context.Background(), "system", "user")
+			if err != nil {
+				t.Fatalf("Decide() 返回错误: %v", err)
+			}
+			if decision.Signal != tt.wantSignal || decision.Confidence != tt.wantConfidence {
+				t.Errorf("决策 = %s %d%%，期望%s %d%%", decision.Signal, decision.Confidence, tt.wantSignal, tt.wantConfidence)
+			}
+			if report.Agreement != tt.wantAgreement {
+				t.Errorf("一致度 = %v，期望%v", report.Agreement, tt.wantAgreement)
+			}
+			if decision.TargetPrice != tt.wantTarget || decision.StopLoss != tt.wantStop {
+				t.Errorf("目标价/止损价 = %v/%v，期望%v/%v", decision.TargetPrice, decision.StopLoss, tt.wantTarget, tt.wantStop)
+			}
+			if !strings.HasSuffix(decision.Reasoning, tt.wantReasoning) {
+				t.Errorf("理由 = %q，期望以%q结尾", decision.Reasoning, tt.wantReasoning)
+			}
+			if len(report.Votes) != len(tt.members) {
+				t.Errorf("记录了%d个回答，期望%d", len(report.Votes), len(tt.members))
+			}
+		})
+	}
+}
+
+func TestEnsembleParseFailureRecorded(t *testing.T) {
+	e, _ := NewEnsemble([]EnsembleMember{
+		{Name: "a", Client: vote("HOLD", 60, 0, 0)},
+		{Name: "b", Client: &stubAIClient{content: "无法判断"}},
+	}, "")
+	_, report, err := e.Decide(context.Background(), "system", "user")
+	if err != nil {
+		t.Fatalf("Decide() 返回错误: %v", err)
+	}
+	failed := report.Votes[1]
+	if failed.Error == "" || failed.Signal != "" {
+		t.Errorf("解析失败的回答应记录错误且没有信号: %+v", failed)
+	}
+	if failed.Raw != "无法判断" || failed.Usage.TotalTokens != 120 {
+		t.Errorf("解析失败的回答仍应保留原始响应和用量: %+v", failed)
+	}
+}
+
+func TestEnsembleSamples(t *testing.T) {
+	e, _ := NewEnsemble([]EnsembleMember{
+		{Name: "a", Client: vote("HOLD", 60, 0, 0), Samples: 3},
+		{Name: "b", Client: vote("SELL", 60, 0, 0)},
+	}, EnsembleVotingMajority)
+	decision, report, err := e.Decide(context.Background(), "system", "user")
+	if err != nil {
+		t.Fatalf("Decide() 返回错误: %v", err)
+	}
+	if len(report.Votes) != 4 || decision.Signal != "HOLD" || report.Agreement != 0.75 {
+		t.Errorf("采样3次应计3票: %d票 %s 一致度%v", len(report.Votes), decision.Signal, report.Agreement)
+	}
+}
+
+func TestEnsembleAllFailed(t *testing.T) {
+	e, _ := NewEnsemble([]EnsembleMember{
+		{Name: "a", Client: &stubAIClient{err: errors.New("连接超时")}},
+		{Name: "b", Client: &stubAIClient{content: "not json"}},
+	}, EnsembleVotingMajority)
+	decision, report, err := e.Decide(context.Background(), "system", "user")
+	if err == nil || decision != nil {
+		t.Fatalf("没有有效回答时应返回错误")
+	}
+	if !strings.Contains(err.Error(), "a: 连接超时") || !strings.Contains(err.Error(), "b: ") {
+		t.Errorf("错误信息应包含各成员的失败原因: %v", err)
+	}
+	if report == nil || len(report.Votes) != 2 || report.Votes[1].Usage.TotalTokens != 120 {
+		t.Errorf("失败时也应返回各成员的回答: %+v", report)
+	}
+}
+
+func TestEnsembleCancelledKeepsCompletedVotes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	e, _ := NewEnsemble([]EnsembleMember{
+		{Name: "fast", Client: &stubAIClient{content: `{"signal":"HOLD","confidence":60}`, done: cancel}},
+		{Name: "slow", Client: &stubAIClient{block: true}},
+	}, EnsembleVotingMajority)
+
+	decision, report, err := e.Decide(ctx, "system", "user")
+	if !errors.Is(err, context.Canceled) || decision != nil {
+		t.Fatalf("取消时应返回context.Canceled，实际: %v", err)
+	}
+	if report == nil || len(report.Votes) != 2 {
+		t.Fatalf("取消时应返回已完成的回答: %+v", report)
+	}
+	if fast := report.Votes[0]; fast.Signal != "HOLD" || fast.Usage.TotalTokens != 120 || fast.Provider != "stub" {
+		t.Errorf("已完成的回答丢失: %+v", fast)
+	}
+	if slow := report.Votes[1]; slow.Error == "" || slow.Provider != "" {
+		t.Errorf("未完成的回答应记录取消原因: %+v", slow)
+	}
+}
@@ -0,0 +1,307 @@
+// Package indicators 技术指标计算库
+//
+// 所有函数返回与输入等长的序列，数据不足的位置为NaN，可用Last获取最新有效值。
+// 计算口径与通达信/同花顺等A股行情软件保持一致（如MACD、KDJ使用SMA(X,N,1)式平滑）。
+package indicators
+
+import "math"
+
+// Bar K线（价格单位：元，成交量单位：手）
+type Bar struct {
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	Volume float64
+}
+
+// Closes 提取收盘价序列
+func Closes(bars []Bar) []float64 {
+	values := make([]float64, len(bars))
+	for i, bar := range bars {
+		values[i] = bar.Close
+	}
+	return values
+}
+
+// Last 获取序列最后一个值，为NaN或序列为空时返回false
+func Last(series []float64) (float64, bool) {
+	if len(series) == 0 {
+		return 0, false
+	}
+	v := series[len(series)-1]
+	if math.IsNaN(v) {
+		return 0, false
+	}
+	return v, true
+}
+
+// nanSeries 创建全部为NaN的序列
+func nanSeries(n int) []float64 {
+	series := make([]float64, n)
+	for i := range series {
+		series[i] = math.NaN()
+	}
+	return series
+}
+
+// SMA 简单移动平均，前period-1个位置为NaN
+func SMA(values []float64, period int) []float64 {
+	out := nanSeries(len(values))
+	if period <= 0 || len(values) < period {
+		return out
+	}
+
+	sum := 0.0
+	for i, v := range values {
+		sum += v
+		if i >= period {
+			sum -= values[i-period]
+		}
+		if i >= period-1 {
+			out[i] = sum / float64(period)
+		}
+	}
+	return out
+}
+
+// EMA 指数移动平均，α=2/(period+1)，以第一个值作为初始值（通达信EMA口径）
+func EMA(values []float64, period int) []float64 {
+	out := nanSeries(len(values))
+	if period <= 0 || len(values) == 0 {
+		return out
+	}
+
+	alpha := 2.0 / float64(period+1)
+	out[0] = values[0]
+	for i := 1; i < len(values); i++ {
+		out[i] = alpha*values[i] + (1-alpha)*out[i-1]
+	}
+	return out
+}
+
+// MACDResult MACD指标
+type MACDResult struct {
+	DIF  []float64 // 快线：EMA(fast) - EMA(slow)
+	DEA  []float64 // 慢线：EMA(DIF, signal)
+	Hist []float64 // 柱：2 * (DIF - DEA)，A股惯例乘以2
+}
+
+// MACD 计算MACD指标，常用参数(12, 26, 9)
+func MACD(closes []float64, fast, slow, signal int) MACDResult {
+	emaFast := EMA(closes, fast)
+	emaSlow := EMA(closes, slow)
+
+	dif := make([]float64, len(closes))
+	for i := range closes {
+		dif[i] = emaFast[i] - emaSlow[i]
+	}
+	dea := EMA(dif, signal)
+
+	hist := make([]float64, len(closes))
+	for i := range closes {
+		hist[i] = 2 * (dif[i] - dea[i])
+	}
+
+	return MACDResult{DIF: dif, DEA: dea, Hist: hist}
+}
+
+// KDJResult KDJ指标
+type KDJResult struct {
+	K []float64
+	D []float64
+	J []float64
+}
+
+// KDJ 计算KDJ随机指标，常用参数(9, 3, 3)
+// RSV=(C-LLV(L,n))/(HHV(H,n)-LLV(L,n))*100，K=SMA(RSV,m1,1)，D=SMA(K,m2,1)，J=3K-2D，K、D初始值为50
+// 不足n根K线时使用已有K线计算RSV
+func KDJ(bars []Bar, n, m1, m2 int) KDJResult {
+	k := nanSeries(len(bars))
+	d := nanSeries(len(bars))
+	j := nanSeries(len(bars))
+	if n <= 0 || m1 <= 0 || m2 <= 0 {
+		return KDJResult{K: k, D: d, J: j}
+	}
+
+	prevK, prevD := 50.0, 50.0
+	for i := range bars {
+		start := i - n + 1
+		if start < 0 {
+			start = 0
+		}
+		highest, lowest := bars[start].High, bars[start].Low
+		for _, bar := range bars[start : i+1] {
+			highest = math.Max(highest, bar.High)
+			lowest = math.Min(lowest, bar.Low)
+		}
+
+		// 最高价等于最低价（如一字板）时RSV无意义，保持K值不变
+		rsv := prevK
+		if highest > lowest {
+			rsv = (bars[i].Close - lowest) / (highest - lowest) * 100
+		}
+
+		curK := (float64(m1-1)*prevK + rsv) / float64(m1)
+		curD := (float64(m2-1)*prevD + curK) / float64(m2)
+		k[i], d[i], j[i] = curK, curD, 3*curK-2*curD
+		prevK, prevD = curK, curD
+	}
+
+	return KDJResult{K: k, D: d, J: j}
+}
+
+// BollResult 布林带
+type BollResult struct {
+	Upper []float64
+	Mid   []float64
+	Lower []float64
+}
+
+// Boll 计算布林带，常用参数(20, 2)，标准差使用总体标准差
+func Boll(closes []float64, period int, multiplier float64) BollResult {
+	mid := SMA(closes, period)
+	upper := nanSeries(len(closes))
+	lower := nanSeries(len(closes))
+
+	for i := range closes {
+		if math.IsNaN(mid[i]) {
+			continue
+		}
+		variance := 0.0
+		for _, v := range closes[i-period+1 : i+1] {
+			variance += (v - mid[i]) * (v - mid[i])
+		}
+		std := math.Sqrt(variance / float64(period))
+		upper[i] = mid[i] + multiplier*std
+		lower[i] = mid[i] - multiplier*std
+	}
+
+	return BollResult{Upper: upper, Mid: mid, Lower: lower}
+}
+
+// TrueRange 真实波幅序列，第一根K线为最高价-最低价
+func TrueRange(bars []Bar) []float64 {
+	tr := make([]float64, len(bars))
+	for i, bar := range bars {
+		tr[i] = bar.High - bar.Low
+		if i > 0 {
+			prevClose := bars[i-1].Close
+			tr[i] = math.Max(tr[i], math.Max(math.Abs(bar.High-prevClose), math.Abs(bar.Low-prevClose)))
+		}
+	}
+	return tr
+}
+
+// ATR 平均真实波幅（Wilder平滑），常用参数14
+// 第period根K线处取前period个TR的简单平均，之后ATR=(前值*(period-1)+TR)/period
+func ATR(bars []Bar, period int) []float64 {
+	out := nanSeries(len(bars))
+	if period <= 0 || len(bars) < period {
+		return out
+	}
+
+	tr := TrueRange(bars)
+	sum := 0.0
+	for i := 0; i < period; i++ {
+		sum += tr[i]
+	}
+	out[period-1] = sum / float64(period)
+	for i := period; i < len(bars); i++ {
+		out[i] = (out[i-1]*float64(period-1) + tr[i]) / float64(period)
+	}
+	return out
+}
+
+// OBV 能量潮，收盘价上涨累加成交量、下跌累减，首根K线为0
+func OBV(bars []Bar) []float64 {
+	out := make([]float64, len(bars))
+	for i := 1; i < len(bars); i++ {
+		switch {
+		case bars[i].Close > bars[i-1].Close:
+			out[i] = out[i-1] + bars[i].Volume
+		case bars[i].Close < bars[i-1].Close:
+			out[i] = out[i-1] - bars[i].Volume
+		default:
+			out[i] = out[i-1]
+		}
+	}
+	return out
+}
+
+// CCI 顺势指标，常用参数14
+// TP=(H+L+C)/3，CCI=(TP-MA(TP,n))/(0.015*平均绝对偏差)
+func CCI(bars []Bar, period int) []float64 {
+	out := nanSeries(len(bars))
+	if period <= 0 || len(bars) < period {
+		return out
+	}
+
+	tp := make([]float64, len(bars))
+	for i, bar := range bars {
+		tp[i] = (bar.High + bar.Low + bar.Close) / 3
+	}
+	ma := SMA(tp, period)
+
+	for i := period - 1; i < len(bars); i++ {
+		meanDev := 0.0
+		for _, v := range tp[i-period+1 : i+1] {
+			meanDev += math.Abs(v - ma[i])
+		}
+		meanDev /= float64(period)
+		if meanDev == 0 {
+			out[i] = 0
+			continue
+		}
+		out[i] = (tp[i] - ma[i]) / (0.015 * meanDev)
+	}
+	return out
+}
+
+// RSI 相对强弱指标（Wilder平滑），常用参数14
+// 第period个涨跌幅处取简单平均，之后平均涨跌=(前值*(period-1)+当期)/period
+func RSI(closes []float64, period int) []float64 {
+	out := nanSeries(len(closes))
+	if period <= 0 || len(closes) <= period {
+		return out
+	}
+
+	gain, loss := 0.0, 0.0
+	for i := 1; i <= period; i++ {
+		change := closes[i] - closes[i-1]
+		if change > 0 {
+			gain += change
+		} else {
+			loss -= change
+		}
+	}
+	avgGain := gain / float64(period)
+	avgLoss := loss / float64(period)
+	out[period] = rsiValue(avgGain, avgLoss)
+
+	for i := period + 1; i < len(closes); i++ {
+		change := closes[i] - closes[i-1]
+		g, l := 0.0, 0.0
+		if change > 0 {
+			g = change
+		} else {
+			l = -change
+		}
+		avgGain = (avgGain*float64(period-1) + g) / float64(period)
+		avgLoss = (avgLoss*float64(period-1) + l) / float64(period)
+		out[i] = rsiValue(avgGain, avgLoss)
+	}
+	return out
+}
+
+// rsiValue 由平均涨幅和平均跌幅计算RSI
+func rsiValue(avgGain, avgLoss float64) float64 {
+	if avgLoss == 0 {
+		if avgGain == 0 {
+			return 50
+		}
+		return 100
+	}
+	rs := avgGain / avgLoss
+	return 100 - 100/(1+rs)
+}
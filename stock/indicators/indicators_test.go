@@ -0,0 +1,217 @@
+package indicators
+
+import (
+	"math"
+	"testing"
+)
+
+// nan 测试中表示预热期（数据不足）的位置
+var nan = math.NaN()
+
+// assertSeries 逐项比较序列，NaN只与NaN相等
+func assertSeries(t *testing.T, name string, got, want []float64, tolerance float64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: 长度为%d，期望%d", name, len(got), len(want))
+	}
+	for i := range want {
+		if math.IsNaN(want[i]) {
+			if !math.IsNaN(got[i]) {
+				t.Errorf("%s[%d] = %v，期望NaN", name, i, got[i])
+			}
+			continue
+		}
+		if math.IsNaN(got[i]) || math.Abs(got[i]-want[i]) > tolerance {
+			t.Errorf("%s[%d] = %v，期望%v", name, i, got[i], want[i])
+		}
+	}
+}
+
+// barsFromTypical 构造典型价格(H+L+C)/3等于tp的K线
+func barsFromTypical(tp []float64) []Bar {
+	bars := make([]Bar, len(tp))
+	for i, v := range tp {
+		bars[i] = Bar{Open: v, High: v + 1, Low: v - 1, Close: v}
+	}
+	return bars
+}
+
+// wilderCloses StockCharts RSI示例（cs-rsi）中的收盘价
+var wilderCloses = []float64{
+	44.3389, 44.0902, 44.1497, 43.6124, 44.3278, 44.8264, 45.0955, 45.4245, 45.8433, 46.0826,
+	45.8931, 46.0328, 45.6140, 46.2820, 46.2820, 46.0028, 46.0328, 46.4116, 46.2222, 45.6439,
+	46.2122, 46.2521, 45.7137, 46.4515, 45.7835, 45.3548, 44.0288, 44.1783, 44.2181, 44.5672,
+	43.4205, 42.6628, 43.1314,
+}
+
+func TestRSIWilderReference(t *testing.T) {
+	// StockCharts发布的14日RSI（保留两位小数）
+	published := []float64{
+		70.53, 66.32, 66.55, 69.41, 66.36, 57.97, 62.93, 63.26, 56.06, 62.38,
+		54.71, 50.42, 39.99, 41.46, 41.87, 45.46, 37.30, 33.08, 37.77,
+	}
+	want := append(nanSeries(14), published...)
+	assertSeries(t, "RSI", RSI(wilderCloses, 14), want, 0.005)
+}
+
+func TestRSIEdgeCases(t *testing.T) {
+	tests := []struct {
+		name   string
+		closes []float64
+		period int
+		want   []float64
+	}{
+		{"空序列", nil, 14, []float64{}},
+		{"K线数等于周期", []float64{1, 2, 3}, 3, []float64{nan, nan, nan}},
+		{"周期为0", []float64{1, 2, 3}, 0, []float64{nan, nan, nan}},
+		{"只涨不跌", []float64{1, 2, 3, 4}, 3, []float64{nan, nan, nan, 100}},
+		{"价格不变", []float64{5, 5, 5, 5}, 3, []float64{nan, nan, nan, 50}},
+		{"只跌不涨", []float64{4, 3, 2, 1}, 3, []float64{nan, nan, nan, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertSeries(t, "RSI", RSI(tt.closes, tt.period), tt.want, 1e-9)
+		})
+	}
+}
+
+func TestMovingAverages(t *testing.T) {
+	values := []float64{1, 2, 3, 4, 5}
+	tests := []struct {
+		name string
+		got  []float64
+		want []float64
+	}{
+		{"SMA(3)", SMA(values, 3), []float64{nan, nan, 2, 3, 4}},
+		{"SMA数据不足", SMA(values[:2], 3), []float64{nan, nan}},
+		{"SMA周期为0", SMA(values, 0), []float64{nan, nan, nan, nan, nan}},
+		// α=2/(3+1)=0.5，以第一个值为初始值
+		{"EMA(3)", EMA(values, 3), []float64{1, 1.5, 2.25, 3.125, 4.0625}},
+		{"EMA单个值", EMA([]float64{7}, 12), []float64{7}},
+		{"EMA空序列", EMA(nil, 3), []float64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertSeries(t, tt.name, tt.got, tt.want, 1e-9)
+		})
+	}
+}
+
+func TestMACD(t *testing.T) {
+	// fast=2(α=2/3)、slow=3(α=1/2)、signal=2(α=2/3)，逐步手算：
+	// EMA2: 10, 32/3, 104/9, 302/27；EMA3: 10, 10.5, 11.25, 11.125
+	// DIF: 0, 1/6, 11/36, 13/216；DEA: 0, 1/9, 13/54, 13/108；柱=2*(DIF-DEA)
+	result := MACD([]float64{10, 11, 12, 11}, 2, 3, 2)
+	assertSeries(t, "DIF", result.DIF, []float64{0, 1.0 / 6, 11.0 / 36, 13.0 / 216}, 1e-9)
+	assertSeries(t, "DEA", result.DEA, []float64{0, 1.0 / 9, 13.0 / 54, 13.0 / 108}, 1e-9)
+	assertSeries(t, "Hist", result.Hist, []float64{0, 1.0 / 9, 7.0 / 54, -13.0 / 108}, 1e-9)
+
+	empty := MACD(nil, 12, 26, 9)
+	if len(empty.DIF) != 0 || len(empty.DEA) != 0 || len(empty.Hist) != 0 {
+		t.Errorf("空序列的MACD应为空序列")
+	}
+}
+
+func TestKDJ(t *testing.T) {
+	bars := []Bar{
+		{High: 10, Low: 8, Close: 9},
+		{High: 11, Low: 9, Close: 10.5},
+		{High: 12, Low: 10, Close: 11},
+		{High: 12, Low: 11, Close: 11},
+	}
+	// n=3：前两根K线不足3根，使用已有K线计算RSV
+	// RSV: 50, 250/3, 75, 200/3；K=(2*前K+RSV)/3，D=(2*前D+K)/3，初始值50
+	k := []float64{50, 550.0 / 9, 1775.0 / 27, 5350.0 / 81}
+	d := []float64{50, 1450.0 / 27, 4675.0 / 81, 14700.0 / 243}
+	j := make([]float64, len(k))
+	for i := range k {
+		j[i] = 3*k[i] - 2*d[i]
+	}
+
+	result := KDJ(bars, 3, 3, 3)
+	assertSeries(t, "K", result.K, k, 1e-9)
+	assertSeries(t, "D", result.D, d, 1e-9)
+	assertSeries(t, "J", result.J, j, 1e-9)
+}
+
+func TestKDJEdgeCases(t *testing.T) {
+	// 一字板：最高价等于最低价，K、D保持初始值50
+	flat := []Bar{{High: 10, Low: 10, Close: 10}, {High: 10, Low: 10, Close: 10}}
+	result := KDJ(flat, 9, 3, 3)
+	assertSeries(t, "K", result.K, []float64{50, 50}, 1e-9)
+	assertSeries(t, "D", result.D, []float64{50, 50}, 1e-9)
+	assertSeries(t, "J", result.J, []float64{50, 50}, 1e-9)
+
+	invalid := KDJ(flat, 0, 3, 3)
+	assertSeries(t, "K", invalid.K, []float64{nan, nan}, 0)
+}
+
+func TestBoll(t *testing.T) {
+	// 均值3，总体方差(4+1+0+1+4)/5=2
+	closes := []float64{1, 2, 3, 4, 5}
+	result := Boll(closes, 5, 2)
+	assertSeries(t, "Mid", result.Mid, []float64{nan, nan, nan, nan, 3}, 1e-9)
+	assertSeries(t, "Upper", result.Upper, []float64{nan, nan, nan, nan, 3 + 2*math.Sqrt2}, 1e-9)
+	assertSeries(t, "Lower", result.Lower, []float64{nan, nan, nan, nan, 3 - 2*math.Sqrt2}, 1e-9)
+
+	short := Boll(closes[:3], 5, 2)
+	assertSeries(t, "Upper", short.Upper, []float64{nan, nan, nan}, 0)
+}
+
+func TestTrueRangeAndATR(t *testing.T) {
+	bars := []Bar{
+		{High: 10, Low: 8, Close: 9},
+		{High: 11, Low: 9, Close: 10},
+		{High: 12, Low: 9.5, Close: 11},
+		{High: 11, Low: 10, Close: 10.5},
+		{High: 14, Low: 13, Close: 13.5}, // 跳空高开：TR=最高价-前收盘=3.5
+	}
+	assertSeries(t, "TR", TrueRange(bars), []float64{2, 2, 2.5, 1, 3.5}, 1e-9)
+
+	// ATR(3)：第3根取前3个TR平均=13/6，之后(前值*2+TR)/3
+	assertSeries(t, "ATR", ATR(bars, 3), []float64{nan, nan, 13.0 / 6, 16.0 / 9, 127.0 / 54}, 1e-9)
+	assertSeries(t, "ATR数据不足", ATR(bars[:2], 3), []float64{nan, nan}, 0)
+}
+
+func TestOBV(t *testing.T) {
+	bars := []Bar{
+		{Close: 10, Volume: 100},
+		{Close: 11, Volume: 200},
+		{Close: 11, Volume: 300},
+		{Close: 10.5, Volume: 400},
+		{Close: 12, Volume: 500},
+	}
+	assertSeries(t, "OBV", OBV(bars), []float64{0, 200, 200, -200, 300}, 1e-9)
+	assertSeries(t, "OBV空序列", OBV(nil), []float64{}, 0)
+}
+
+func TestCCI(t *testing.T) {
+	tests := []struct {
+		name   string
+		tp     []float64
+		period int
+		want   []float64
+	}{
+		// i=3: MA=12，平均绝对偏差1.5，CCI=3/(0.015*1.5)；i=4: MA=12.75，偏差1.25，CCI=0.25/(0.015*1.25)
+		{"CCI(4)", []float64{10, 12, 11, 15, 13}, 4, []float64{nan, nan, nan, 400.0 / 3, 40.0 / 3}},
+		{"价格不变", []float64{5, 5, 5}, 3, []float64{nan, nan, 0}},
+		{"数据不足", []float64{10, 12}, 4, []float64{nan, nan}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertSeries(t, "CCI", CCI(barsFromTypical(tt.tp), tt.period), tt.want, 1e-9)
+		})
+	}
+}
+
+func TestLast(t *testing.T) {
+	if _, ok := Last(nil); ok {
+		t.Errorf("空序列不应有最新值")
+	}
+	if _, ok := Last([]float64{1, nan}); ok {
+		t.Errorf("最后一个值为NaN时不应有最新值")
+	}
+	if v, ok := Last([]float64{nan, 2}); !ok || v != 2 {
+		t.Errorf("Last = %v, %v，期望2, true", v, ok)
+	}
+}
@@ -0,0 +1,76 @@
+package indicators
+
+import "fmt"
+
+// MissingText 指标缺失时的显示文本
+const MissingText = "数据不足"
+
+// TechnicalSnapshot 某一时刻的行情与技术指标快照
+// 指针字段为可选指标，数据不足（如新股K线不够、盘口为空）时为nil
+type TechnicalSnapshot struct {
+	// 实时行情（价格单位：元）
+	CurrentPrice  float64  `json:"current_price"`
+	OpenPrice     float64  `json:"open_price"`
+	HighPrice     float64  `json:"high_price"`
+	LowPrice      float64  `json:"low_price"`
+	PrevClose     float64  `json:"prev_close"`
+	ChangePercent *float64 `json:"change_percent,omitempty"` // 涨跌幅（%）
+	Volume        int64    `json:"volume"`                   // 成交量（股）
+	Amount        float64  `json:"amount"`                   // 成交额（元）
+
+	// 盘口
+	OuterRatio   *float64 `json:"outer_ratio,omitempty"`    // 外盘占比（%）
+	BuySellRatio *float64 `json:"buy_sell_ratio,omitempty"` // 五档买盘/卖盘挂单量比
+
+	// 日线均线
+	MA5   *float64 `json:"ma5,omitempty"`
+	MA10  *float64 `json:"ma10,omitempty"`
+	MA20  *float64 `json:"ma20,omitempty"`
+	MA60  *float64 `json:"ma60,omitempty"`
+	EMA12 *float64 `json:"ema12,omitempty"`
+	EMA26 *float64 `json:"ema26,omitempty"`
+
+	// MACD(12,26,9)
+	MACDDIF  *float64 `json:"macd_dif,omitempty"`
+	MACDDEA  *float64 `json:"macd_dea,omitempty"`
+	MACDHist *float64 `json:"macd_hist,omitempty"`
+
+	// KDJ(9,3,3)
+	KDJK *float64 `json:"kdj_k,omitempty"`
+	KDJD *float64 `json:"kdj_d,omitempty"`
+	KDJJ *float64 `json:"kdj_j,omitempty"`
+
+	// BOLL(20,2)
+	BollUpper *float64 `json:"boll_upper,omitempty"`
+	BollMid   *float64 `json:"boll_mid,omitempty"`
+	BollLower *float64 `json:"boll_lower,omitempty"`
+
+	// 其他指标
+	ATR14         *float64 `json:"atr14,omitempty"`
+	CCI14         *float64 `json:"cci14,omitempty"`
+	OBV           *float64 `json:"obv,omitempty"`
+	RSI14         *float64 `json:"rsi14,omitempty"`
+	Volatility20d *float64 `json:"volatility_20d,omitempty"` // 近20日收益率标准差（%）
+}
+
+// Float 返回v的指针，用于填充可选字段
+func Float(v float64) *float64 {
+	return &v
+}
+
+// LastPtr 获取序列最新有效值的指针，无有效值时返回nil
+func LastPtr(series []float64) *float64 {
+	v, ok := Last(series)
+	if !ok {
+		return nil
+	}
+	return &v
+}
+
+// FormatOptional 格式化可选指标，缺失时返回"数据不足"
+func FormatOptional(v *float64, format string) string {
+	if v == nil {
+		return MissingText
+	}
+	return fmt.Sprintf(format, *v)
+}
@@ -0,0 +1,352 @@
+// Package market A股交易规则：T+1、整手交易、涨跌停限制和交易费用
+// 供回测和模拟交易使用，价格单位均为元
+package market
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+// Board 交易板块
+type Board string
+
+const (
+	BoardMain    Board = "main"    // 沪深主板
+	BoardChiNext Board = "chinext" // 创业板（300xxx、301xxx）
+	BoardSTAR    Board = "star"    // 科创板（688xxx、689xxx）
+	BoardBSE     Board = "bse"     // 北交所（8xxxxx、4xxxxx、920xxx）
+)
+
+// Side 买卖方向
+type Side string
+
+const (
+	SideBuy  Side = "BUY"
+	SideSell Side = "SELL"
+)
+
+// 下单被拒绝的原因
+var (
+	ErrLimitUp      = errors.New("涨停价无法买入")
+	ErrLimitDown    = errors.New("跌停价无法卖出")
+	ErrTPlusOne     = errors.New("T+1限制，当日买入的股票不能卖出")
+	ErrLotSize      = errors.New("买入数量不足一手")
+	ErrInvalidPrice = errors.New("成交价格无效")
+)
+
+// FeeConfig 交易费用，从JSON加载时未配置的字段使用默认值（显式配置为0时不收取，如免五佣金）
+type FeeConfig struct {
+	CommissionRate  float64 `json:"commission_rate"`   // 佣金费率（双向），默认万2.5
+	MinCommission   float64 `json:"min_commission"`    // 单笔最低佣金（元），默认5元
+	StampDutyRate   float64 `json:"stamp_duty_rate"`   // 印花税率（仅卖出），默认万5
+	TransferFeeRate float64 `json:"transfer_fee_rate"` // 过户费率（双向），默认十万分之1
+}
+
+// UnmarshalJSON 以默认费用为基础解析，只覆盖配置了的字段
+func (f *FeeConfig) UnmarshalJSON(data []byte) error {
+	type plain FeeConfig
+	fees := plain(defaultFees)
+	if err := json.Unmarshal(data, &fees); err != nil {
+		return err
+	}
+	*f = FeeConfig(fees)
+	return nil
+}
+
+// BoardRules 单个板块的交易规则
+type BoardRules struct {
+	PriceLimit   float64   `json:"price_limit"`    // 涨跌幅限制，如0.1表示±10%
+	STPriceLimit float64   `json:"st_price_limit"` // ST股票的涨跌幅限制
+	LotSize      int64     `json:"lot_size"`       // 每手股数，买入数量必须是其整数倍
+	Fees         FeeConfig `json:"fees"`
+}
+
+// Rules A股交易规则（按板块配置）
+type Rules struct {
+	SettlementDays *int                 `json:"settlement_days"` // 买入后需经过的交易日数才能卖出，未配置时为1（T+1），显式配置为0表示T+0
+	Boards         map[Board]BoardRules `json:"boards"`
+}
+
+// Settlement 买入后需经过的交易日数，未配置时为1
+func (r *Rules) Settlement() int {
+	if r.SettlementDays == nil {
+		return 1
+	}
+	return *r.SettlementDays
+}
+
+// defaultFees 默认费用（2023年8月印花税减半后的标准）
+var defaultFees = FeeConfig{
+	CommissionRate:  0.00025,
+	MinCommission:   5,
+	StampDutyRate:   0.0005,
+	TransferFeeRate: 0.00001,
+}
+
+// DefaultRules 默认交易规则
+// 主板±10%（ST ±5%），创业板和科创板±20%（含ST），北交所±30%，均为100股一手
+func DefaultRules() *Rules {
+	settlementDays := 1
+	return &Rules{
+		SettlementDays: &settlementDays,
+		Boards: map[Board]BoardRules{
+			BoardMain:    {PriceLimit: 0.10, STPriceLimit: 0.05, LotSize: 100, Fees: defaultFees},
+			BoardChiNext: {PriceLimit: 0.20, STPriceLimit: 0.20, LotSize: 100, Fees: defaultFees},
+			BoardSTAR:    {PriceLimit: 0.20, STPriceLimit: 0.20, LotSize: 100, Fees: defaultFees},
+			BoardBSE:     {PriceLimit: 0.30, STPriceLimit: 0.30, LotSize: 100, Fees: defaultFees},
+		},
+	}
+}
+
+// LoadRules 从JSON文件加载交易规则，未配置的板块和字段使用默认值
+func LoadRules(path string) (*Rules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取交易规则文件失败: %w", err)
+	}
+
+	var rules Rules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("解析交易规则文件失败: %w", err)
+	}
+	rules.ApplyDefaults()
+	return &rules, nil
+}
+
+// ApplyDefaults 用默认规则填充未配置的板块和零值字段
+func (r *Rules) ApplyDefaults() {
+	defaults := DefaultRules()
+	if r.SettlementDays == nil || *r.SettlementDays < 0 {
+		r.SettlementDays = defaults.SettlementDays
+	}
+	if r.Boards == nil {
+		r.Boards = make(map[Board]BoardRules)
+	}
+	for board, def := range defaults.Boards {
+		br, ok := r.Boards[board]
+		if !ok {
+			r.Boards[board] = def
+			continue
+		}
+		if br.PriceLimit <= 0 {
+			br.PriceLimit = def.PriceLimit
+		}
+		if br.STPriceLimit <= 0 {
+			br.STPriceLimit = def.STPriceLimit
+		}
+		if br.LotSize <= 0 {
+			br.LotSize = def.LotSize
+		}
+		// 未配置fees时整体使用默认值；部分配置时由FeeConfig.UnmarshalJSON按字段补齐
+		if br.Fees == (FeeConfig{}) {
+			br.Fees = def.Fees
+		}
+		r.Boards[board] = br
+	}
+}
+
+// DetectBoard 根据股票代码判断所属板块
+func DetectBoard(code string) Board {
+	code = strings.ToLower(strings.TrimSpace(code))
+	// 兼容带市场前缀的代码，如sh600000、sz000001、bj830799
+	for _, prefix := range []string{"sh", "sz", "bj"} {
+		code = strings.TrimPrefix(code, prefix)
+	}
+
+	switch {
+	case strings.HasPrefix(code, "300"), strings.HasPrefix(code, "301"):
+		return BoardChiNext
+	case strings.HasPrefix(code, "688"), strings.HasPrefix(code, "689"):
+		return BoardSTAR
+	case strings.HasPrefix(code, "8"), strings.HasPrefix(code, "4"), strings.HasPrefix(code, "920"):
+		return BoardBSE
+	default:
+		return BoardMain
+	}
+}
+
+// IsST 根据股票名称判断是否为ST股票（ST、*ST、SST等）
+func IsST(name string) bool {
+	return strings.Contains(strings.ToUpper(name), "ST")
+}
+
+// BoardRules 获取股票代码对应的板块规则
+func (r *Rules) BoardRules(code string) BoardRules {
+	if br, ok := r.Boards[DetectBoard(code)]; ok {
+		return br
+	}
+	return DefaultRules().Boards[BoardMain]
+}
+
+// LimitPrices 计算涨停价和跌停价（四舍五入到分），prevClose<=0时返回0
+func (r *Rules) LimitPrices(code string, st bool, prevClose float64) (limitUp float64, limitDown float64) {
+	if prevClose <= 0 {
+		return 0, 0
+	}
+	br := r.BoardRules(code)
+	limit := br.PriceLimit
+	if st {
+		limit = br.STPriceLimit
+	}
+	return RoundPrice(prevClose * (1 + limit)), RoundPrice(prevClose * (1 - limit))
+}
+
+// CheckPrice 检查价格是否可以成交：涨停价不能买入，跌停价不能卖出
+// prevClose<=0（如上市首日、缺少前收盘价）时不做限制
+func (r *Rules) CheckPrice(code string, st bool, side Side, price float64, prevClose float64) error {
+	if price <= 0 {
+		return ErrInvalidPrice
+	}
+	limitUp, limitDown := r.LimitPrices(code, st, prevClose)
+	if limitUp == 0 {
+		return nil
+	}
+	if side == SideBuy && RoundPrice(price) >= limitUp {
+		return ErrLimitUp
+	}
+	if side == SideSell && RoundPrice(price) <= limitDown {
+		return ErrLimitDown
+	}
+	return nil
+}
+
+// CanSell 检查买入的股票在指定时间是否可以卖出
+// 按日期比较，T+1时买入次日（下一个交易日）起可以卖出
+func (r *Rules) CanSell(buyTime time.Time, sellTime time.Time) bool {
+	settlementDays := r.Settlement()
+	if settlementDays <= 0 {
+		return true
+	}
+	buyDay := time.Date(buyTime.Year(), buyTime.Month(), buyTime.Day(), 0, 0, 0, 0, time.UTC)
+	sellDay := time.Date(sellTime.Year(), sellTime.Month(), sellTime.Day(), 0, 0, 0, 0, time.UTC)
+	return sellDay.Sub(buyDay) >= time.Duration(settlementDays)*24*time.Hour
+}
+
+// RoundLot 将买入数量向下取整到整手
+func (r *Rules) RoundLot(code string, shares int64) int64 {
+	lot := r.BoardRules(code).LotSize
+	if lot <= 1 {
+		return shares
+	}
+	return shares / lot * lot
+}
+
+// Fee 一笔成交的费用明细（元）
+type Fee struct {
+	Commission  float64 `json:"commission"`
+	StampDuty   float64 `json:"stamp_duty"`
+	TransferFee float64 `json:"transfer_fee"`
+	Total       float64 `json:"total"`
+}
+
+// CalculateFee 计算成交金额对应的交易费用
+func (r *Rules) CalculateFee(code string, side Side, amount float64) Fee {
+	fees := r.BoardRules(code).Fees
+	fee := Fee{
+		Commission:  math.Max(amount*fees.CommissionRate, fees.MinCommission),
+		TransferFee: amount * fees.TransferFeeRate,
+	}
+	if side == SideSell {
+		fee.StampDuty = amount * fees.StampDutyRate
+	}
+	fee.Commission = RoundPrice(fee.Commission)
+	fee.StampDuty = RoundPrice(fee.StampDuty)
+	fee.TransferFee = RoundPrice(fee.TransferFee)
+	fee.Total = RoundPrice(fee.Commission + fee.StampDuty + fee.TransferFee)
+	return fee
+}
+
+// MaxBuyShares 计算可用资金在指定价格下最多能买入的股数（整手，含费用）
+func (r *Rules) MaxBuyShares(code string, cash float64, price float64) int64 {
+	if cash <= 0 || price <= 0 {
+		return 0
+	}
+	lot := r.BoardRules(code).LotSize
+	if lot <= 0 {
+		lot = 1
+	}
+	shares := r.RoundLot(code, int64(cash/price))
+	for shares > 0 {
+		amount := price * float64(shares)
+		if amount+r.CalculateFee(code, SideBuy, amount).Total <= cash {
+			return shares
+		}
+		shares -= lot
+	}
+	return 0
+}
+
+// Order 委托
+type Order struct {
+	Code      string
+	ST        bool
+	Side      Side
+	Price     float64   // 委托成交价（元）
+	Shares    int64     // 买入时向下取整到整手；卖出允许零股（清仓）
+	PrevClose float64   // 前收盘价，用于计算涨跌停
+	Time      time.Time // 成交时间
+	BuyTime   time.Time // 卖出时持仓的买入时间，用于T+1检查
+}
+
+// Fill 成交回报
+type Fill struct {
+	Side   Side      `json:"side"`
+	Price  float64   `json:"price"`
+	Shares int64     `json:"shares"`
+	Amount float64   `json:"amount"` // 成交金额（不含费用）
+	Fee    Fee       `json:"fee"`
+	Time   time.Time `json:"time"`
+}
+
+// NetAmount 资金变动：买入为负（金额+费用），卖出为正（金额-费用）
+func (f *Fill) NetAmount() float64 {
+	if f.Side == SideBuy {
+		return -(f.Amount + f.Fee.Total)
+	}
+	return f.Amount - f.Fee.Total
+}
+
+// Execute 按交易规则撮合委托，不满足规则时返回对应的错误
+func (r *Rules) Execute(order Order) (*Fill, error) {
+	if err := r.CheckPrice(order.Code, order.ST, order.Side, order.Price, order.PrevClose); err != nil {
+		return nil, err
+	}
+
+	shares := order.Shares
+	switch order.Side {
+	case SideBuy:
+		shares = r.RoundLot(order.Code, shares)
+		if shares <= 0 {
+			return nil, ErrLotSize
+		}
+	case SideSell:
+		if shares <= 0 {
+			return nil, fmt.Errorf("卖出数量无效: %d", shares)
+		}
+		if !order.BuyTime.IsZero() && !r.CanSell(order.BuyTime, order.Time) {
+			return nil, ErrTPlusOne
+		}
+	default:
+		return nil, fmt.Errorf("不支持的买卖方向: %s", order.Side)
+	}
+
+	amount := order.Price * float64(shares)
+	return &Fill{
+		Side:   order.Side,
+		Price:  order.Price,
+		Shares: shares,
+		Amount: amount,
+		Fee:    r.CalculateFee(order.Code, order.Side, amount),
+		Time:   order.Time,
+	}, nil
+}
+
+// RoundPrice 价格四舍五入到分
+func RoundPrice(price float64) float64 {
+	return math.Round(price*100) / 100
+}
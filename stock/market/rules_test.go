@@ -0,0 +1,236 @@
+package market
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDetectBoard(t *testing.T) {
+	tests := []struct {
+		code string
+		want Board
+	}{
+		{"600000", BoardMain},
+		{"000001", BoardMain},
+		{"002594", BoardMain},
+		{"300750", BoardChiNext},
+		{"sz301001", BoardChiNext},
+		{"688981", BoardSTAR},
+		{"SH689009", BoardSTAR},
+		{"830799", BoardBSE},
+		{"bj430047", BoardBSE},
+		{"920001", BoardBSE},
+		{" 600519 ", BoardMain},
+	}
+	for _, tt := range tests {
+		if got := DetectBoard(tt.code); got != tt.want {
+			t.Errorf("DetectBoard(%q) = %s，期望%s", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestLimitPrices(t *testing.T) {
+	rules := DefaultRules()
+	tests := []struct {
+		name      string
+		code      string
+		st        bool
+		prevClose float64
+		up, down  float64
+	}{
+		{"主板", "600000", false, 10, 11, 9},
+		{"主板ST", "600000", true, 10, 10.5, 9.5},
+		{"创业板四舍五入到分", "300750", false, 10.01, 12.01, 8.01},
+		{"创业板ST仍为20%", "300750", true, 10, 12, 8},
+		{"科创板", "688981", false, 25.55, 30.66, 20.44},
+		{"北交所", "830799", false, 10, 13, 7},
+		{"缺少前收盘价", "600000", false, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			up, down := rules.LimitPrices(tt.code, tt.st, tt.prevClose)
+			if up != tt.up || down != tt.down {
+				t.Errorf("LimitPrices = %v/%v，期望%v/%v", up, down, tt.up, tt.down)
+			}
+		})
+	}
+}
+
+func TestCheckPrice(t *testing.T) {
+	rules := DefaultRules()
+	tests := []struct {
+		name      string
+		side      Side
+		price     float64
+		prevClose float64
+		want      error
+	}{
+		{"涨停价买入", SideBuy, 11, 10, ErrLimitUp},
+		{"涨停价卖出", SideSell, 11, 10, nil},
+		{"跌停价卖出", SideSell, 9, 10, ErrLimitDown},
+		{"跌停价买入", SideBuy, 9, 10, nil},
+		{"舍入后达到涨停价", SideBuy, 10.996, 10, ErrLimitUp},
+		{"无效价格", SideBuy, 0, 10, ErrInvalidPrice},
+		{"缺少前收盘价不限制", SideBuy, 100, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := rules.CheckPrice("600000", false, tt.side, tt.price, tt.prevClose)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("CheckPrice = %v，期望%v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoundLot(t *testing.T) {
+	rules := DefaultRules()
+	rules.Boards[BoardBSE] = BoardRules{PriceLimit: 0.3, STPriceLimit: 0.3, LotSize: 1, Fees: defaultFees}
+
+	tests := []struct {
+		code   string
+		shares int64
+		want   int64
+	}{
+		{"600000", 250, 200},
+		{"600000", 99, 0},
+		{"600000", 100, 100},
+		{"830799", 157, 157},
+	}
+	for _, tt := range tests {
+		if got := rules.RoundLot(tt.code, tt.shares); got != tt.want {
+			t.Errorf("RoundLot(%s, %d) = %d，期望%d", tt.code, tt.shares, got, tt.want)
+		}
+	}
+}
+
+func TestCalculateFee(t *testing.T) {
+	rules := DefaultRules()
+	tests := []struct {
+		name   string
+		side   Side
+		amount float64
+		want   Fee
+	}{
+		// 佣金2.5元低于最低5元
+		{"小额买入按最低佣金", SideBuy, 10000, Fee{Commission: 5, TransferFee: 0.1, Total: 5.1}},
+		{"卖出收印花税", SideSell, 100000, Fee{Commission: 25, StampDuty: 50, TransferFee: 1, Total: 76}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rules.CalculateFee("600000", tt.side, tt.amount); got != tt.want {
+				t.Errorf("CalculateFee = %+v，期望%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxBuyShares(t *testing.T) {
+	rules := DefaultRules()
+	// 1000股需要10000+5.1元，资金不足时退到900股
+	if got := rules.MaxBuyShares("600000", 10000, 10); got != 900 {
+		t.Errorf("MaxBuyShares = %d，期望900", got)
+	}
+	if got := rules.MaxBuyShares("600000", 500, 10); got != 0 {
+		t.Errorf("资金不足一手时MaxBuyShares = %d，期望0", got)
+	}
+}
+
+func TestCanSell(t *testing.T) {
+	buy := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC) // 周五
+	tplus0 := 0
+	tests := []struct {
+		name  string
+		rules *Rules
+		sell  time.Time
+		want  bool
+	}{
+		{"T+1当日不能卖出", DefaultRules(), buy.Add(5 * time.Hour), false},
+		{"T+1次日可以卖出", DefaultRules(), time.Date(2024, 3, 9, 9, 30, 0, 0, time.UTC), true},
+		{"未配置settlement_days时为T+1", &Rules{}, buy.Add(time.Hour), false},
+		{"显式T+0当日可以卖出", &Rules{SettlementDays: &tplus0}, buy.Add(time.Hour), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rules.CanSell(buy, tt.sell); got != tt.want {
+				t.Errorf("CanSell = %v，期望%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecute(t *testing.T) {
+	rules := DefaultRules()
+	buyTime := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+
+	fill, err := rules.Execute(Order{Code: "600000", Side: SideBuy, Price: 10, Shares: 150, PrevClose: 10, Time: buyTime})
+	if err != nil {
+		t.Fatalf("买入失败: %v", err)
+	}
+	if fill.Shares != 100 || fill.Amount != 1000 || fill.NetAmount() != -1005.01 {
+		t.Errorf("买入成交 = %+v，NetAmount=%v", fill, fill.NetAmount())
+	}
+
+	_, err = rules.Execute(Order{Code: "600000", Side: SideBuy, Price: 10, Shares: 50, PrevClose: 10, Time: buyTime})
+	if !errors.Is(err, ErrLotSize) {
+		t.Errorf("不足一手买入 = %v，期望ErrLotSize", err)
+	}
+
+	_, err = rules.Execute(Order{Code: "600000", Side: SideSell, Price: 10.2, Shares: 100, PrevClose: 10,
+		Time: buyTime.Add(3 * time.Hour), BuyTime: buyTime})
+	if !errors.Is(err, ErrTPlusOne) {
+		t.Errorf("当日卖出 = %v，期望ErrTPlusOne", err)
+	}
+}
+
+func TestLoadRulesPartialConfig(t *testing.T) {
+	tests := []struct {
+		name       string
+		json       string
+		settlement int
+		want       FeeConfig
+	}{
+		{
+			name:       "只配置佣金费率",
+			json:       `{"boards": {"main": {"fees": {"commission_rate": 0.0001}}}}`,
+			settlement: 1,
+			want:       FeeConfig{CommissionRate: 0.0001, MinCommission: 5, StampDutyRate: 0.0005, TransferFeeRate: 0.00001},
+		},
+		{
+			name:       "显式免五和T+0",
+			json:       `{"settlement_days": 0, "boards": {"main": {"fees": {"commission_rate": 0.0001, "min_commission": 0}}}}`,
+			settlement: 0,
+			want:       FeeConfig{CommissionRate: 0.0001, MinCommission: 0, StampDutyRate: 0.0005, TransferFeeRate: 0.00001},
+		},
+		{
+			name:       "未配置fees",
+			json:       `{"boards": {"main": {"price_limit": 0.1}}}`,
+			settlement: 1,
+			want:       defaultFees,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "rules.json")
+			if err := os.WriteFile(path, []byte(tt.json), 0644); err != nil {
+				t.Fatal(err)
+			}
+			rules, err := LoadRules(path)
+			if err != nil {
+				t.Fatalf("LoadRules失败: %v", err)
+			}
+			if got := rules.Settlement(); got != tt.settlement {
+				t.Errorf("Settlement = %d，期望%d", got, tt.settlement)
+			}
+			if got := rules.Boards[BoardMain].Fees; got != tt.want {
+				t.Errorf("主板费用 = %+v，期望%+v", got, tt.want)
+			}
+			if got := rules.Boards[BoardChiNext]; got.PriceLimit != 0.2 || got.Fees != defaultFees {
+				t.Errorf("未配置的创业板应使用默认规则: %+v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,369 @@
+package stock
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"nofx/jsonfile"
+	"nofx/notifier"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DigestConfig 通知汇总与静默配置
+type DigestConfig struct {
+	UrgentConfidence int      // 信心度达到该值的信号立即发送，低于该值的信号在汇总时间合并发送；0表示所有信号都立即发送
+	DigestTimes      []string // 发送汇总的时间（HH:MM），如 ["11:35", "15:05"]
+	QuietHours       []string // 静默时段（HH:MM-HH:MM，可跨午夜），期间不发送任何通知，结束后合并发送
+	StoreFile        string   // 待汇总通知的保存文件，为空时只保存在内存中
+}
+
+// digestEntry 待汇总的信号（同一股票只保留最新的一条）
+type digestEntry struct {
+	Signal    *notifier.TradingSignal `json:"signal"`
+	Count     int                     `json:"count"` // 汇总周期内出现的次数
+	FirstSeen time.Time               `json:"first_seen"`
+}
+
+// digestState 持久化的待发送内容
+type digestState struct {
+	Entries  []*digestEntry `json:"entries"`
+	Messages []string       `json:"messages"` // 静默时段内暂存的普通消息
+}
+
+// DigestNotifier 通知汇总与静默
+// 包装Notifier：高信心信号立即发送，其余信号暂存并在汇总时间（如午间休市、收盘后）合并成一条消息发送；
+// 静默时段内所有通知都暂存，静默结束后合并发送
+type DigestNotifier struct {
+	Notifier           notifier.Notifier
+	TradingTimeChecker *TradingTimeChecker
+	Config             DigestConfig
+
+	mutex    sync.Mutex
+	file     jsonfile.File
+	state    digestState
+	sentSlot map[string]string // 汇总时间 → 最近一次发送的日期
+	wasQuiet bool
+}
+
+// NewDigestNotifier 创建通知汇总器，并从StoreFile恢复待发送内容
+func NewDigestNotifier(n notifier.Notifier, tradingTimeChecker *TradingTimeChecker, config DigestConfig) (*DigestNotifier, error) {
+	d := &DigestNotifier{
+		Notifier:           n,
+		TradingTimeChecker: tradingTimeChecker,
+		Config:             config,
+		sentSlot:           make(map[string]string),
+	}
+	for _, slot := range config.DigestTimes {
+		if _, err := parseClock(slot); err != nil {
+			return nil, fmt.Errorf("汇总时间%w", err)
+		}
+	}
+	for _, period := range config.QuietHours {
+		if _, _, err := parseClockPeriod(period); err != nil {
+			return nil, fmt.Errorf("静默时段%w", err)
+		}
+	}
+	if err := d.load(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// SendSignal 发送或暂存交易信号
+func (d *DigestNotifier) SendSignal(signal *notifier.TradingSignal) error {
+	return d.SendSignalContext(context.Background(), signal)
+}
+
+// SendSignalContext 发送或暂存交易信号（支持取消）
+func (d *DigestNotifier) SendSignalContext(ctx context.Context, signal *notifier.TradingSignal) error {
+	now := time.Now()
+	urgent := d.Config.UrgentConfidence <= 0 || len(d.Config.DigestTimes) == 0 ||
+		signal.Confidence >= d.Config.UrgentConfidence
+	if urgent && !d.InQuietHours(now) {
+		return notifier.SendSignalContext(ctx, d.Notifier, signal)
+	}
+
+	copied := *signal
+	d.mutex.Lock()
+	var entry *digestEntry
+	for _, e := range d.state.Entries {
+		if e.Signal.StockCode == signal.StockCode {
+			entry = e
+			break
+		}
+	}
+	if entry == nil {
+		entry = &digestEntry{FirstSeen: now}
+		d.state.Entries = append(d.state.Entries, entry)
+	}
+	entry.Signal = &copied
+	entry.Count++
+	d.mutex.Unlock()
+
+	log.Printf("🗂️  %s(%s) %s信号已加入汇总", signal.StockName, signal.StockCode, signal.Signal)
+	d.saveLogged()
+	return nil
+}
+
+// SendMessage 发送或暂存普通消息
+func (d *DigestNotifier) SendMessage(message string) error {
+	return d.SendMessageContext(context.Background(), message)
+}
+
+// SendMessageContext 发送或暂存普通消息（支持取消），静默时段内暂存
+func (d *DigestNotifier) SendMessageContext(ctx context.Context, message string) error {
+	if !d.InQuietHours(time.Now()) {
+		return notifier.SendMessageContext(ctx, d.Notifier, message)
+	}
+
+	d.mutex.Lock()
+	d.state.Messages = append(d.state.Messages, message)
+	d.mutex.Unlock()
+	d.saveLogged()
+	return nil
+}
+
+// InQuietHours 是否处于静默时段
+func (d *DigestNotifier) InQuietHours(now time.Time) bool {
+	current := clockMinutes(now.In(d.location()))
+	for _, period := range d.Config.QuietHours {
+		if inClockPeriod(current, period) {
+			return true
+		}
+	}
+	return false
+}
+
+// Start 定时检查汇总时间和静默时段，stopChan关闭时退出（未发送的内容保留在StoreFile中）
+func (d *DigestNotifier) Start(stopChan <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stopChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	log.Printf("🗂️  通知汇总已启动，汇总时间: %v，静默时段: %v", d.Config.DigestTimes, d.Config.QuietHours)
+	for {
+		d.tick(ctx, time.Now())
+
+		select {
+		case <-ticker.C:
+		case <-stopChan:
+			return
+		}
+	}
+}
+
+// tick 到达汇总时间或静默结束时发送暂存的内容
+func (d *DigestNotifier) tick(ctx context.Context, now time.Time) {
+	if d.InQuietHours(now) {
+		d.wasQuiet = true
+		return
+	}
+
+	now = now.In(d.location())
+	today := now.Format("2006-01-02")
+	current := clockMinutes(now)
+
+	due := false
+	if d.wasQuiet {
+		due = true
+		log.Printf("🔔 静默时段结束，发送暂存的通知")
+	}
+	for _, slot := range d.Config.DigestTimes {
+		if d.slotReached(slot, current) && d.sentSlot[slot] != today {
+			due = true
+		}
+	}
+	if !due {
+		return
+	}
+
+	if err := d.Flush(ctx, now); err != nil {
+		log.Printf("❌ 发送通知汇总失败: %v", err)
+		return
+	}
+	d.wasQuiet = false
+	for _, slot := range d.Config.DigestTimes {
+		if d.slotReached(slot, current) {
+			d.sentSlot[slot] = today
+		}
+	}
+}
+
+// slotReached 当天是否已到达汇总时间
+func (d *DigestNotifier) slotReached(slot string, current int) bool {
+	minutes, err := parseClock(slot)
+	return err == nil && current >= minutes
+}
+
+// Flush 立即发送所有暂存的普通消息和信号汇总，发送失败的内容继续保留
+func (d *DigestNotifier) Flush(ctx context.Context, now time.Time) error {
+	d.mutex.Lock()
+	messages := d.state.Messages
+	entries := d.state.Entries
+	d.state = digestState{}
+	d.mutex.Unlock()
+
+	var sendErr error
+	for i, message := range messages {
+		if err := notifier.SendMessageContext(ctx, d.Notifier, message); err != nil {
+			d.requeue(messages[i:], entries)
+			return err
+		}
+	}
+	if len(entries) > 0 {
+		if err := notifier.SendMessageContext(ctx, d.Notifier, formatDigest(entries, now.In(d.location()))); err != nil {
+			d.requeue(nil, entries)
+			sendErr = err
+		} else {
+			log.Printf("✅ 已发送信号汇总（%d只股票）", len(entries))
+		}
+	}
+
+	d.saveLogged()
+	return sendErr
+}
+
+// requeue 将发送失败的内容放回暂存区（排在新暂存内容之前）
+func (d *DigestNotifier) requeue(messages []string, entries []*digestEntry) {
+	d.mutex.Lock()
+	d.state.Messages = append(append([]string{}, messages...), d.state.Messages...)
+	for _, entry := range entries {
+		merged := false
+		for _, e := range d.state.Entries {
+			if e.Signal.StockCode == entry.Signal.StockCode {
+				e.Count += entry.Count
+				e.FirstSeen = entry.FirstSeen
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			d.state.Entries = append(d.state.Entries, entry)
+		}
+	}
+	d.mutex.Unlock()
+	d.saveLogged()
+}
+
+// Pending 返回暂存的信号数和消息数
+func (d *DigestNotifier) Pending() (int, int) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return len(d.state.Entries), len(d.state.Messages)
+}
+
+// formatDigest 格式化信号汇总为一条表格消息
+func formatDigest(entries []*digestEntry, now time.Time) string {
+	sorted := append([]*digestEntry{}, entries...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].FirstSeen.Before(sorted[j].FirstSeen)
+	})
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("📋 信号汇总 %s（%d只股票）\n\n", now.Format("01-02 15:04"), len(sorted)))
+	b.WriteString("股票 | 信号 | 信心度 | 价格 | 目标价 | 止损价 | 时间\n")
+	for _, entry := range sorted {
+		s := entry.Signal
+		line := fmt.Sprintf("%s(%s) | %s | %d%% | %.2f | %s | %s | %s",
+			s.StockName, s.StockCode, s.Signal, s.Confidence, s.Price,
+			formatDigestPrice(s.TargetPrice), formatDigestPrice(s.StopLoss),
+			s.Timestamp.In(now.Location()).Format("15:04"))
+		if entry.Count > 1 {
+			line += fmt.Sprintf("（%d次）", entry.Count)
+		}
+		b.WriteString(line + "\n")
+	}
+	b.WriteString("\n同一股票只列出最新一次信号，AI分析仅供参考")
+	return b.String()
+}
+
+// formatDigestPrice 格式化价格，无效价格显示为"-"
+func formatDigestPrice(price float64) string {
+	if price <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f", price)
+}
+
+// inClockPeriod 判断当天的分钟数是否在时段（HH:MM-HH:MM）内，结束时间早于开始时间时表示跨午夜
+func inClockPeriod(current int, period string) bool {
+	start, end, err := parseClockPeriod(period)
+	if err != nil {
+		return false
+	}
+	if start <= end {
+		return current >= start && current < end
+	}
+	return current >= start || current < end
+}
+
+// parseClockPeriod 解析时段（HH:MM-HH:MM），返回开始和结束时间在当天的分钟数
+func parseClockPeriod(period string) (int, int, error) {
+	parts := strings.SplitN(period, "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("时段格式错误（应为HH:MM-HH:MM）: %s", period)
+	}
+	start, err := parseClock(parts[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := parseClock(parts[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	return start, end, nil
+}
+
+// parseClock 解析时间（HH:MM，小时可为一位），返回当天的分钟数
+func parseClock(clock string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(clock))
+	if err != nil {
+		return 0, fmt.Errorf("时间格式错误（应为HH:MM）: %s", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// clockMinutes 时间在当天的分钟数
+func clockMinutes(t time.Time) int {
+	return t.Hour()*60 + t.Minute()
+}
+
+// location 汇总时间使用的时区
+func (d *DigestNotifier) location() *time.Location {
+	if d.TradingTimeChecker != nil && d.TradingTimeChecker.Location != nil {
+		return d.TradingTimeChecker.Location
+	}
+	return time.Local
+}
+
+// load 从StoreFile加载暂存内容
+func (d *DigestNotifier) load() error {
+	if err := jsonfile.Load(d.Config.StoreFile, &d.state); err != nil {
+		return fmt.Errorf("加载通知汇总记录失败: %w", err)
+	}
+	return nil
+}
+
+// saveLogged 将暂存内容写入StoreFile，失败时只记录日志
+func (d *DigestNotifier) saveLogged() {
+	err := d.file.Save(d.Config.StoreFile, func() ([]byte, error) {
+		d.mutex.Lock()
+		defer d.mutex.Unlock()
+		return jsonfile.Marshal(d.state)
+	})
+	if err != nil {
+		log.Printf("⚠️  保存通知汇总记录失败: %v", err)
+	}
+}
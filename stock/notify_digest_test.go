@@ -0,0 +1,207 @@
+package stock
+
+import (
+	"context"
+	"errors"
+	"nofx/notifier"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingNotifier 记录发送内容的测试通知器，fail为true时所有发送都返回错误
+type recordingNotifier struct {
+	mu       sync.Mutex
+	fail     bool
+	signals  []*notifier.TradingSignal
+	messages []string
+}
+
+func (r *recordingNotifier) SendSignal(signal *notifier.TradingSignal) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fail {
+		return errors.New("发送失败")
+	}
+	r.signals = append(r.signals, signal)
+	return nil
+}
+
+func (r *recordingNotifier) SendMessage(message string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fail {
+		return errors.New("发送失败")
+	}
+	r.messages = append(r.messages, message)
+	return nil
+}
+
+// sent 已成功发送的消息数
+func (r *recordingNotifier) sent() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.messages)
+}
+
+// newTestDigest 使用东八区时间的通知汇总器
+func newTestDigest(t *testing.T, n notifier.Notifier, config DigestConfig) *DigestNotifier {
+	t.Helper()
+	d, err := NewDigestNotifier(n, &TradingTimeChecker{Location: cst}, config)
+	if err != nil {
+		t.Fatalf("创建通知汇总器失败: %v", err)
+	}
+	return d
+}
+
+func TestInClockPeriod(t *testing.T) {
+	tests := []struct {
+		clock  string
+		period string
+		want   bool
+	}{
+		{"12:00", "11:30-13:00", true},
+		{"11:30", "11:30-13:00", true},
+		{"13:00", "11:30-13:00", false}, // 结束时间不含
+		{"23:30", "22:00-07:00", true},  // 跨午夜
+		{"00:00", "22:00-07:00", true},
+		{"06:59", "22:00-07:00", true},
+		{"07:00", "22:00-07:00", false},
+		{"12:00", "22:00-07:00", false},
+		{"09:30", "9:00-10:00", true}, // 一位小时
+		{"09:30", " 09:00 - 10:00 ", true},
+		{"09:30", "09:00", false},
+		{"09:30", "9点-10点", false},
+	}
+	for _, tt := range tests {
+		clock, _ := parseClock(tt.clock)
+		if got := inClockPeriod(clock, tt.period); got != tt.want {
+			t.Errorf("inClockPeriod(%s, %q) = %v，期望%v", tt.clock, tt.period, got, tt.want)
+		}
+	}
+}
+
+func TestNewDigestNotifierRejectsBadClock(t *testing.T) {
+	for _, config := range []DigestConfig{
+		{DigestTimes: []string{"11点35"}},
+		{DigestTimes: []string{"25:00"}},
+		{QuietHours: []string{"22:00"}},
+		{QuietHours: []string{"22:00-7"}},
+	} {
+		if _, err := NewDigestNotifier(&recordingNotifier{}, nil, config); err == nil {
+			t.Errorf("%+v 应返回格式错误", config)
+		}
+	}
+}
+
+func TestDigestQuietHoursAcrossMidnight(t *testing.T) {
+	rec := &recordingNotifier{}
+	d := newTestDigest(t, rec, DigestConfig{QuietHours: []string{"22:00-07:00"}})
+
+	for _, tt := range []struct {
+		at   time.Time
+		want bool
+	}{
+		{time.Date(2024, 3, 7, 21, 59, 0, 0, cst), false},
+		{time.Date(2024, 3, 7, 22, 0, 0, 0, cst), true},
+		{time.Date(2024, 3, 8, 3, 0, 0, 0, cst), true},
+		{time.Date(2024, 3, 7, 20, 0, 0, 0, time.UTC), true}, // 东八区次日04:00
+		{time.Date(2024, 3, 8, 7, 0, 0, 0, cst), false},
+	} {
+		if got := d.InQuietHours(tt.at); got != tt.want {
+			t.Errorf("InQuietHours(%s) = %v，期望%v", tt.at, got, tt.want)
+		}
+	}
+
+	// 静默期间暂存，静默结束后的第一次tick合并发送
+	d.state.Messages = []string{"夜间消息1", "夜间消息2"}
+	d.tick(context.Background(), time.Date(2024, 3, 8, 3, 0, 0, 0, cst))
+	if rec.sent() != 0 {
+		t.Fatalf("静默时段内不应发送")
+	}
+	d.tick(context.Background(), time.Date(2024, 3, 8, 7, 0, 0, 0, cst))
+	if rec.sent() != 2 || rec.messages[0] != "夜间消息1" {
+		t.Errorf("静默结束后应按顺序发送暂存消息，实际: %v", rec.messages)
+	}
+	if signals, messages := d.Pending(); signals != 0 || messages != 0 {
+		t.Errorf("发送后仍有暂存: %d信号 %d消息", signals, messages)
+	}
+}
+
+func TestDigestSlotSentOncePerDay(t *testing.T) {
+	rec := &recordingNotifier{}
+	d := newTestDigest(t, rec, DigestConfig{UrgentConfidence: 90, DigestTimes: []string{"11:35", "15:05"}})
+	queue := func(code string) {
+		d.state.Entries = append(d.state.Entries, &digestEntry{
+			Signal:    &notifier.TradingSignal{StockCode: code, StockName: code, Signal: "BUY", Confidence: 70},
+			Count:     1,
+			FirstSeen: time.Date(2024, 3, 7, 10, 0, 0, 0, cst),
+		})
+	}
+
+	queue("600519")
+	d.tick(context.Background(), time.Date(2024, 3, 7, 11, 34, 0, 0, cst))
+	if rec.sent() != 0 {
+		t.Fatalf("未到汇总时间不应发送")
+	}
+	d.tick(context.Background(), time.Date(2024, 3, 7, 11, 35, 0, 0, cst))
+	if rec.sent() != 1 || !strings.Contains(rec.messages[0], "600519") {
+		t.Fatalf("到达汇总时间应发送一次汇总，实际: %v", rec.messages)
+	}
+
+	// 同一汇总时间当天只触发一次
+	queue("000001")
+	d.tick(context.Background(), time.Date(2024, 3, 7, 11, 36, 0, 0, cst))
+	d.tick(context.Background(), time.Date(2024, 3, 7, 14, 0, 0, 0, cst))
+	if rec.sent() != 1 {
+		t.Fatalf("11:35的汇总当天只应发送一次，实际发送%d次", rec.sent())
+	}
+	d.tick(context.Background(), time.Date(2024, 3, 7, 15, 5, 0, 0, cst))
+	if rec.sent() != 2 || !strings.Contains(rec.messages[1], "000001") {
+		t.Fatalf("15:05应发送第二次汇总，实际: %v", rec.messages)
+	}
+
+	// 次日重新触发
+	queue("600036")
+	d.tick(context.Background(), time.Date(2024, 3, 8, 11, 35, 0, 0, cst))
+	if rec.sent() != 3 {
+		t.Errorf("次日应重新发送汇总，实际发送%d次", rec.sent())
+	}
+}
+
+func TestDigestRequeueOnFailedFlush(t *testing.T) {
+	rec := &recordingNotifier{fail: true}
+	d := newTestDigest(t, rec, DigestConfig{UrgentConfidence: 90, DigestTimes: []string{"15:05"}})
+	d.state.Messages = []string{"暂存消息"}
+	d.state.Entries = []*digestEntry{{
+		Signal:    &notifier.TradingSignal{StockCode: "600519", Signal: "BUY", Confidence: 70},
+		Count:     2,
+		FirstSeen: time.Date(2024, 3, 7, 10, 0, 0, 0, cst),
+	}}
+
+	at := time.Date(2024, 3, 7, 15, 5, 0, 0, cst)
+	d.tick(context.Background(), at)
+	if signals, messages := d.Pending(); signals != 1 || messages != 1 {
+		t.Fatalf("发送失败后应保留暂存内容，实际: %d信号 %d消息", signals, messages)
+	}
+	if d.sentSlot["15:05"] != "" {
+		t.Fatalf("发送失败时不应标记汇总时间已发送")
+	}
+
+	// 失败期间新加入的同一股票信号与放回的合并
+	d.SendSignal(&notifier.TradingSignal{StockCode: "600519", Signal: "SELL", Confidence: 60})
+	if d.state.Entries[0].Count != 3 || d.state.Entries[0].Signal.Signal != "SELL" {
+		t.Errorf("合并后 = %d次 %s，期望3次 SELL", d.state.Entries[0].Count, d.state.Entries[0].Signal.Signal)
+	}
+
+	// 恢复后下一次tick重试
+	rec.fail = false
+	d.tick(context.Background(), at.Add(30*time.Second))
+	if rec.sent() != 2 || rec.messages[0] != "暂存消息" || !strings.Contains(rec.messages[1], "（3次）") {
+		t.Errorf("恢复后应重发暂存消息和汇总，实际: %v", rec.messages)
+	}
+	if signals, messages := d.Pending(); signals != 0 || messages != 0 {
+		t.Errorf("重发后仍有暂存: %d信号 %d消息", signals, messages)
+	}
+}
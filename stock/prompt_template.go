@@ -0,0 +1,165 @@
+package stock
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"nofx/stock/indicators"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// DefaultSystemPrompt 默认系统提示词
+const DefaultSystemPrompt = "你是一位专业的A股分析师，精通技术分析和市场研判。"
+
+// PromptData 提示词模板可访问的数据
+type PromptData struct {
+	StockCode    string
+	StockName    string
+	AnalysisTime time.Time
+	Quote        *QuoteData
+	DayKline     *KlineData
+	Min30Kline   *KlineData
+	Minute       *MinuteData // 非交易时间可能为nil
+	Technical    *TechnicalSnapshot
+}
+
+// promptFuncs 提示词模板可用的函数
+var promptFuncs = template.FuncMap{
+	// yuan 将厘转换为元
+	"yuan": PriceToYuan,
+	// wan 将元转换为万元
+	"wan": func(v float64) float64 { return v / 10000 },
+	// opt 格式化可选指标，缺失时输出"数据不足"
+	"opt": indicators.FormatOptional,
+	// recentKlines 获取最近n条K线（从新到旧）
+	"recentKlines": func(list []KlineItem, n int) []KlineItem {
+		result := []KlineItem{}
+		for i := len(list) - 1; i >= 0 && len(result) < n; i-- {
+			result = append(result, list[i])
+		}
+		return result
+	},
+	// recentMinutes 获取最近n条分时数据（从新到旧）
+	"recentMinutes": func(data *MinuteData, n int) []MinuteItem {
+		result := []MinuteItem{}
+		if data == nil {
+			return result
+		}
+		for i := len(data.List) - 1; i >= 0 && len(result) < n; i-- {
+			result = append(result, data.List[i])
+		}
+		return result
+	},
+	"add":   func(a, b int) int { return a + b },
+	"upper": strings.ToUpper,
+}
+
+// PromptTemplate 从文件加载的提示词模板
+// 文件修改后下一次渲染时自动重新加载，便于不重启调整提示词
+type PromptTemplate struct {
+	SystemFile string // 系统提示词模板文件（为空时使用DefaultSystemPrompt）
+	UserFile   string // 分析提示词模板文件（为空时使用内置提示词）
+
+	mutex       sync.Mutex
+	system      *template.Template
+	user        *template.Template
+	systemMtime time.Time
+	userMtime   time.Time
+}
+
+// LoadPromptTemplate 加载提示词模板，两个文件都为空时返回nil（使用内置提示词）
+func LoadPromptTemplate(systemFile, userFile string) (*PromptTemplate, error) {
+	if systemFile == "" && userFile == "" {
+		return nil, nil
+	}
+
+	pt := &PromptTemplate{
+		SystemFile: systemFile,
+		UserFile:   userFile,
+	}
+	if err := pt.reload(); err != nil {
+		return nil, err
+	}
+	return pt, nil
+}
+
+// Render 渲染提示词，模板文件未配置的部分返回空字符串
+func (pt *PromptTemplate) Render(data *PromptData) (systemPrompt string, userPrompt string, err error) {
+	pt.mutex.Lock()
+	defer pt.mutex.Unlock()
+
+	if err := pt.reload(); err != nil {
+		return "", "", err
+	}
+
+	if pt.system != nil {
+		if systemPrompt, err = executePromptTemplate(pt.system, data); err != nil {
+			return "", "", fmt.Errorf("渲染系统提示词失败: %w", err)
+		}
+		systemPrompt = strings.TrimSpace(systemPrompt)
+	}
+	if pt.user != nil {
+		if userPrompt, err = executePromptTemplate(pt.user, data); err != nil {
+			return "", "", fmt.Errorf("渲染分析提示词失败: %w", err)
+		}
+	}
+	return systemPrompt, userPrompt, nil
+}
+
+// reload 文件有变化时重新解析模板（调用方需持有mutex或处于初始化阶段）
+func (pt *PromptTemplate) reload() error {
+	if pt.SystemFile != "" {
+		tmpl, mtime, err := parseIfChanged(pt.SystemFile, pt.systemMtime, pt.system)
+		if err != nil {
+			return err
+		}
+		pt.system, pt.systemMtime = tmpl, mtime
+	}
+	if pt.UserFile != "" {
+		tmpl, mtime, err := parseIfChanged(pt.UserFile, pt.userMtime, pt.user)
+		if err != nil {
+			return err
+		}
+		pt.user, pt.userMtime = tmpl, mtime
+	}
+	return nil
+}
+
+// parseIfChanged 文件修改时间变化时重新解析模板
+func parseIfChanged(file string, lastMtime time.Time, current *template.Template) (*template.Template, time.Time, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, lastMtime, fmt.Errorf("读取提示词模板失败: %w", err)
+	}
+	if current != nil && info.ModTime().Equal(lastMtime) {
+		return current, lastMtime, nil
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, lastMtime, fmt.Errorf("读取提示词模板失败: %w", err)
+	}
+	tmpl, err := template.New(file).Funcs(promptFuncs).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		// 解析失败时保留旧模板，避免编辑中途的错误导致分析中断
+		if current != nil {
+			log.Printf("⚠️  提示词模板 %s 解析失败，继续使用上一版本: %v", file, err)
+			return current, lastMtime, nil
+		}
+		return nil, lastMtime, fmt.Errorf("解析提示词模板 %s 失败: %w", file, err)
+	}
+	return tmpl, info.ModTime(), nil
+}
+
+// executePromptTemplate 执行提示词模板
+func executePromptTemplate(tmpl *template.Template, data *PromptData) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
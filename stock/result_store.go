@@ -0,0 +1,261 @@
+package stock
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ResultStore 分析结果存储接口
+type ResultStore interface {
+	// Save 保存一条分析结果
+	Save(result *AnalysisResult) error
+	// Latest 获取指定股票最新的分析结果，没有记录时返回nil
+	Latest(stockCode string) (*AnalysisResult, error)
+	// Query 按条件查询分析结果（按时间倒序），返回当前页记录和符合条件的总数
+	Query(query ResultQuery) ([]*AnalysisResult, int, error)
+}
+
+// ResultQuery 分析结果查询条件
+type ResultQuery struct {
+	StockCode string    // 股票代码（必填）
+	Signals   []string  // 信号过滤（BUY/SELL/HOLD），为空表示不过滤
+	Start     time.Time // 起始时间（含），零值表示不限制
+	End       time.Time // 结束时间（不含），零值表示不限制
+	Offset    int       // 跳过条数
+	Limit     int       // 返回条数，<=0表示不限制
+}
+
+// Match 判断分析结果是否满足查询条件
+func (q *ResultQuery) Match(result *AnalysisResult) bool {
+	if q.StockCode != "" && result.StockCode != q.StockCode {
+		return false
+	}
+	if !q.Start.IsZero() && result.Timestamp.Before(q.Start) {
+		return false
+	}
+	if !q.End.IsZero() && !result.Timestamp.Before(q.End) {
+		return false
+	}
+	if len(q.Signals) > 0 {
+		matched := false
+		for _, signal := range q.Signals {
+			if strings.EqualFold(signal, result.Signal) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// paginate 按时间倒序排序并分页
+func (q *ResultQuery) paginate(results []*AnalysisResult) ([]*AnalysisResult, int) {
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Timestamp.After(results[j].Timestamp)
+	})
+
+	total := len(results)
+	if q.Offset > 0 {
+		if q.Offset >= total {
+			return []*AnalysisResult{}, total
+		}
+		results = results[q.Offset:]
+	}
+	if q.Limit > 0 && len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
+	return results, total
+}
+
+// MemoryResultStore 内存分析结果存储（重启后丢失，适合回测和调试）
+type MemoryResultStore struct {
+	mutex   sync.RWMutex
+	results map[string][]*AnalysisResult
+}
+
+// NewMemoryResultStore 创建内存分析结果存储
+func NewMemoryResultStore() *MemoryResultStore {
+	return &MemoryResultStore{
+		results: make(map[string][]*AnalysisResult),
+	}
+}
+
+// Save 保存一条分析结果
+func (s *MemoryResultStore) Save(result *AnalysisResult) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.results[result.StockCode] = append(s.results[result.StockCode], result)
+	return nil
+}
+
+// Latest 获取指定股票最新的分析结果
+func (s *MemoryResultStore) Latest(stockCode string) (*AnalysisResult, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	list := s.results[stockCode]
+	if len(list) == 0 {
+		return nil, nil
+	}
+	return list[len(list)-1], nil
+}
+
+// Query 按条件查询分析结果
+func (s *MemoryResultStore) Query(query ResultQuery) ([]*AnalysisResult, int, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	matched := []*AnalysisResult{}
+	for _, result := range s.results[query.StockCode] {
+		if query.Match(result) {
+			matched = append(matched, result)
+		}
+	}
+
+	page, total := query.paginate(matched)
+	return page, total, nil
+}
+
+// JSONLResultStore 追加写入的JSONL文件分析结果存储
+// 每只股票一个文件：<Dir>/<股票代码>.jsonl，每行一条AnalysisResult
+type JSONLResultStore struct {
+	Dir string
+
+	mutex  sync.RWMutex
+	latest map[string]*AnalysisResult // 最新结果缓存
+}
+
+// NewJSONLResultStore 创建JSONL分析结果存储
+func NewJSONLResultStore(dir string) (*JSONLResultStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建结果存储目录失败: %w", err)
+	}
+
+	return &JSONLResultStore{
+		Dir:    dir,
+		latest: make(map[string]*AnalysisResult),
+	}, nil
+}
+
+// filePath 获取股票对应的存储文件路径
+func (s *JSONLResultStore) filePath(stockCode string) string {
+	// 防止股票代码中包含路径分隔符
+	name := strings.NewReplacer("/", "_", "\\", "_", "..", "_").Replace(stockCode)
+	return filepath.Join(s.Dir, name+".jsonl")
+}
+
+// Save 追加保存一条分析结果
+func (s *JSONLResultStore) Save(result *AnalysisResult) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("序列化分析结果失败: %w", err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	f, err := os.OpenFile(s.filePath(result.StockCode), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("打开结果文件失败: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("写入分析结果失败: %w", err)
+	}
+
+	s.latest[result.StockCode] = result
+	return nil
+}
+
+// Latest 获取指定股票最新的分析结果
+func (s *JSONLResultStore) Latest(stockCode string) (*AnalysisResult, error) {
+	s.mutex.RLock()
+	cached := s.latest[stockCode]
+	s.mutex.RUnlock()
+	if cached != nil {
+		return cached, nil
+	}
+
+	// 缓存未命中（如刚重启），从文件读取
+	var latest *AnalysisResult
+	err := s.scan(stockCode, func(result *AnalysisResult) {
+		if latest == nil || !result.Timestamp.Before(latest.Timestamp) {
+			latest = result
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if latest != nil {
+		s.mutex.Lock()
+		if s.latest[stockCode] == nil {
+			s.latest[stockCode] = latest
+		}
+		s.mutex.Unlock()
+	}
+	return latest, nil
+}
+
+// Query 按条件查询分析结果
+func (s *JSONLResultStore) Query(query ResultQuery) ([]*AnalysisResult, int, error) {
+	matched := []*AnalysisResult{}
+	err := s.scan(query.StockCode, func(result *AnalysisResult) {
+		if query.Match(result) {
+			matched = append(matched, result)
+		}
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	page, total := query.paginate(matched)
+	return page, total, nil
+}
+
+// scan 逐行读取股票的结果文件
+func (s *JSONLResultStore) scan(stockCode string, fn func(result *AnalysisResult)) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	f, err := os.Open(s.filePath(stockCode))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("打开结果文件失败: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	// reasoning可能很长，放宽单行长度限制
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var result AnalysisResult
+		if err := json.Unmarshal(line, &result); err != nil {
+			// 跳过损坏的行（如写入中途进程退出）
+			continue
+		}
+		fn(&result)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取结果文件失败: %w", err)
+	}
+	return nil
+}
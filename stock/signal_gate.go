@@ -0,0 +1,157 @@
+package stock
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"nofx/jsonfile"
+	"sync"
+	"time"
+)
+
+// SignalGateConfig 信号去重配置
+type SignalGateConfig struct {
+	Cooldown       time.Duration // 同一股票两次通知的最短间隔（方向反转不受限制）
+	PriceChangePct float64       // 目标价或止损价变化超过该百分比时重新通知
+	ConfidenceJump int           // 信心度比上次通知时上升达到该值时重新通知
+	StateFile      string        // 状态文件，为空时只保存在内存中
+}
+
+// signalGateState 单只股票最近一次通知的状态
+type signalGateState struct {
+	Signal      string    `json:"signal"`
+	Confidence  int       `json:"confidence"`
+	TargetPrice float64   `json:"target_price"`
+	StopLoss    float64   `json:"stop_loss"`
+	NotifiedAt  time.Time `json:"notified_at"`
+	Interrupted bool      `json:"interrupted"` // 通知后信号是否中断过（转为HOLD或信心度不足）
+}
+
+// SignalGate 信号去重与冷却
+// 每次扫描都会得到信号，持续的BUY/SELL不应每次都通知。规则：
+//   - 股票首次出现信号时通知
+//   - 冷却期内不再通知，但BUY与SELL之间的方向反转总是通知
+//   - 冷却期过后，信号变化（包括中断后重新出现）、目标价/止损价明显变化、信心度明显上升时重新通知
+type SignalGate struct {
+	Config SignalGateConfig
+
+	mutex  sync.Mutex
+	file   jsonfile.File
+	states map[string]*signalGateState
+}
+
+// NewSignalGate 创建信号去重器，并从StateFile恢复状态
+func NewSignalGate(config SignalGateConfig) (*SignalGate, error) {
+	g := &SignalGate{
+		Config: config,
+		states: make(map[string]*signalGateState),
+	}
+	if err := g.load(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Check 判断信号是否需要通知，返回原因（用于日志）
+func (g *SignalGate) Check(result *AnalysisResult) (bool, string) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	state, ok := g.states[result.StockCode]
+	if !ok {
+		return true, "首次信号"
+	}
+
+	if isReversal(state.Signal, result.Signal) {
+		return true, fmt.Sprintf("信号反转 %s → %s", state.Signal, result.Signal)
+	}
+
+	if elapsed := result.Timestamp.Sub(state.NotifiedAt); elapsed < g.Config.Cooldown {
+		return false, fmt.Sprintf("冷却中（距上次通知%s）", elapsed.Round(time.Minute))
+	}
+
+	switch {
+	case state.Signal != result.Signal:
+		return true, fmt.Sprintf("信号变化 %s → %s", state.Signal, result.Signal)
+	case state.Interrupted:
+		return true, "信号重新出现"
+	}
+
+	if g.Config.ConfidenceJump > 0 && result.Confidence-state.Confidence >= g.Config.ConfidenceJump {
+		return true, fmt.Sprintf("信心度上升 %d%% → %d%%", state.Confidence, result.Confidence)
+	}
+	if pct := changePercent(state.TargetPrice, result.TargetPrice); pct >= g.Config.PriceChangePct {
+		return true, fmt.Sprintf("目标价变化 %.2f → %.2f", state.TargetPrice, result.TargetPrice)
+	}
+	if pct := changePercent(state.StopLoss, result.StopLoss); pct >= g.Config.PriceChangePct {
+		return true, fmt.Sprintf("止损价变化 %.2f → %.2f", state.StopLoss, result.StopLoss)
+	}
+
+	return false, "信号无明显变化"
+}
+
+// Record 记录已发送的通知
+func (g *SignalGate) Record(result *AnalysisResult) {
+	g.mutex.Lock()
+	g.states[result.StockCode] = &signalGateState{
+		Signal:      result.Signal,
+		Confidence:  result.Confidence,
+		TargetPrice: result.TargetPrice,
+		StopLoss:    result.StopLoss,
+		NotifiedAt:  result.Timestamp,
+	}
+	g.mutex.Unlock()
+
+	g.saveLogged()
+}
+
+// Observe 记录不需要通知的分析结果（HOLD或信心度不足），标记信号已中断
+func (g *SignalGate) Observe(result *AnalysisResult) {
+	g.mutex.Lock()
+	state, ok := g.states[result.StockCode]
+	changed := ok && !state.Interrupted
+	if changed {
+		state.Interrupted = true
+	}
+	g.mutex.Unlock()
+
+	if changed {
+		g.saveLogged()
+	}
+}
+
+// isReversal 是否为买卖方向反转
+func isReversal(from string, to string) bool {
+	return (from == "BUY" && to == "SELL") || (from == "SELL" && to == "BUY")
+}
+
+// changePercent 价格变化百分比（任一价格无效时返回0）
+func changePercent(from float64, to float64) float64 {
+	if from <= 0 || to <= 0 {
+		return 0
+	}
+	return math.Abs(to-from) / from * 100
+}
+
+// load 从StateFile加载状态
+func (g *SignalGate) load() error {
+	if err := jsonfile.Load(g.Config.StateFile, &g.states); err != nil {
+		return fmt.Errorf("加载通知去重状态失败: %w", err)
+	}
+	if g.states == nil {
+		g.states = make(map[string]*signalGateState)
+	}
+	return nil
+}
+
+// saveLogged 将状态写入StateFile，失败时只记录日志
+func (g *SignalGate) saveLogged() {
+	err := g.file.Save(g.Config.StateFile, func() ([]byte, error) {
+		g.mutex.Lock()
+		defer g.mutex.Unlock()
+		return jsonfile.Marshal(g.states)
+	})
+	if err != nil {
+		log.Printf("⚠️  保存通知去重状态失败: %v", err)
+	}
+}
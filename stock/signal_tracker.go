@@ -0,0 +1,539 @@
+package stock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"nofx/jsonfile"
+	"nofx/notifier"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OutcomeStatus 信号结果状态
+type OutcomeStatus string
+
+const (
+	OutcomeOpen    OutcomeStatus = "open"     // 跟踪中
+	OutcomeTarget  OutcomeStatus = "target"   // 先触及目标价
+	OutcomeStop    OutcomeStatus = "stop"     // 先触及止损价
+	OutcomeTimeout OutcomeStatus = "timeout"  // 超时未触及目标价和止损价
+	OutcomeDataGap OutcomeStatus = "data_gap" // 1分钟K线缺失（如长时间停机超出接口可返回的范围），无法判断结果
+)
+
+// SignalOutcome 一条已发出信号的后续表现
+type SignalOutcome struct {
+	ID          string        `json:"id"`
+	StockCode   string        `json:"stock_code"`
+	StockName   string        `json:"stock_name"`
+	Signal      string        `json:"signal"`
+	Confidence  int           `json:"confidence"`
+	EntryPrice  float64       `json:"entry_price"` // 发出信号时的价格
+	TargetPrice float64       `json:"target_price"`
+	StopLoss    float64       `json:"stop_loss"`
+	SignalTime  time.Time     `json:"signal_time"`
+	Deadline    time.Time     `json:"deadline"` // 超时时间
+	Status      OutcomeStatus `json:"status"`
+	ExitPrice   float64       `json:"exit_price,omitempty"`
+	ExitTime    *time.Time    `json:"exit_time,omitempty"`
+
+	HoldingMinutes        float64   `json:"holding_minutes"`         // 从发出信号到结束（跟踪中为到最新行情）的分钟数
+	LowestPrice           float64   `json:"lowest_price"`            // 跟踪期间最低价
+	HighestPrice          float64   `json:"highest_price"`           // 跟踪期间最高价
+	MaxAdverseExcursion   float64   `json:"max_adverse_excursion"`   // 最大不利偏移（%）：相对入场价的最大跌幅
+	MaxFavorableExcursion float64   `json:"max_favorable_excursion"` // 最大有利偏移（%）：相对入场价的最大涨幅
+	ReturnPct             float64   `json:"return_pct"`              // 结束价（跟踪中为最新价）相对入场价的收益率（%）
+	LastPrice             float64   `json:"last_price"`
+	CheckedAt             time.Time `json:"checked_at"` // 已处理到的行情时间
+}
+
+// Closed 是否已有结果
+func (o *SignalOutcome) Closed() bool {
+	return o.Status != OutcomeOpen
+}
+
+// applyBar 用一根K线更新跟踪状态，触及止损或目标价时返回true
+// 同一根K线同时触及时保守地认为先触及止损
+func (o *SignalOutcome) applyBar(t time.Time, open, high, low, closePrice float64) bool {
+	if o.LowestPrice == 0 || low < o.LowestPrice {
+		o.LowestPrice = low
+	}
+	if high > o.HighestPrice {
+		o.HighestPrice = high
+	}
+	o.LastPrice = closePrice
+	o.CheckedAt = t
+	o.updateStats(t)
+
+	switch {
+	case low <= o.StopLoss:
+		o.close(OutcomeStop, math.Min(open, o.StopLoss), t)
+		return true
+	case high >= o.TargetPrice:
+		o.close(OutcomeTarget, math.Max(open, o.TargetPrice), t)
+		return true
+	}
+	return false
+}
+
+// close 记录结果
+func (o *SignalOutcome) close(status OutcomeStatus, price float64, t time.Time) {
+	o.Status = status
+	o.ExitPrice = price
+	o.ExitTime = &t
+	o.LastPrice = price
+	o.updateStats(t)
+}
+
+// updateStats 更新持有时长、最大不利/有利偏移和收益率
+func (o *SignalOutcome) updateStats(now time.Time) {
+	if o.EntryPrice <= 0 {
+		return
+	}
+	o.HoldingMinutes = math.Round(now.Sub(o.SignalTime).Minutes())
+	if o.LowestPrice > 0 {
+		o.MaxAdverseExcursion = roundPercent(math.Max(0, (o.EntryPrice-o.LowestPrice)/o.EntryPrice*100))
+	}
+	if o.HighestPrice > 0 {
+		o.MaxFavorableExcursion = roundPercent(math.Max(0, (o.HighestPrice-o.EntryPrice)/o.EntryPrice*100))
+	}
+	o.ReturnPct = roundPercent((o.LastPrice/o.EntryPrice - 1) * 100)
+}
+
+// roundPercent 百分比保留两位小数
+func roundPercent(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// OutcomeSummary 单只股票的信号结果统计
+type OutcomeSummary struct {
+	StockCode         string  `json:"stock_code"`
+	StockName         string  `json:"stock_name"`
+	Total             int     `json:"total"`               // 跟踪的信号总数
+	Open              int     `json:"open"`                // 跟踪中
+	Target            int     `json:"target"`              // 触及目标价
+	Stop              int     `json:"stop"`                // 触及止损价
+	Timeout           int     `json:"timeout"`             // 超时
+	DataGap           int     `json:"data_gap"`            // 行情缺失无法判断，不计入命中率和平均值
+	HitRate           float64 `json:"hit_rate"`            // 目标价命中率（%）= 触及目标价 / 已有结果
+	AvgReturn         float64 `json:"avg_return"`          // 已有结果信号的平均收益率（%）
+	AvgHoldingMinutes float64 `json:"avg_holding_minutes"` // 已有结果信号的平均持有分钟数
+	AvgMAE            float64 `json:"avg_mae"`             // 已有结果信号的平均最大不利偏移（%）
+}
+
+// SignalTrackerConfig 信号跟踪配置
+type SignalTrackerConfig struct {
+	CheckInterval time.Duration // 行情检查间隔，默认5分钟
+	TimeoutDays   int           // 信号发出后经过多少个交易日仍未触及目标价或止损价视为超时，默认5
+	SummaryTime   string        // 每个交易日发送统计通知的时间（HH:MM），为空不发送
+	StoreFile     string        // 跟踪记录保存文件，为空时只保存在内存中
+}
+
+// SignalTracker 信号结果跟踪器
+// 对发出通知的BUY信号，用信号之后的1分钟K线判断先触及目标价、止损价还是超时
+type SignalTracker struct {
+	TDXClient          *TDXClient
+	Notifier           notifier.Notifier
+	TradingTimeChecker *TradingTimeChecker
+	Config             SignalTrackerConfig
+
+	mutex           sync.RWMutex
+	outcomes        map[string]*SignalOutcome
+	file            jsonfile.File
+	lastSummaryDate string
+}
+
+// NewSignalTracker 创建信号跟踪器，配置了StoreFile时加载已保存的记录
+func NewSignalTracker(tdxClient *TDXClient, notif notifier.Notifier, tradingTimeChecker *TradingTimeChecker, config SignalTrackerConfig) (*SignalTracker, error) {
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = 5 * time.Minute
+	}
+	if config.TimeoutDays <= 0 {
+		config.TimeoutDays = 5
+	}
+	if config.SummaryTime != "" {
+		if _, err := time.Parse("15:04", config.SummaryTime); err != nil {
+			return nil, fmt.Errorf("统计通知时间格式错误（应为HH:MM）: %s", config.SummaryTime)
+		}
+	}
+
+	t := &SignalTracker{
+		TDXClient:          tdxClient,
+		Notifier:           notif,
+		TradingTimeChecker: tradingTimeChecker,
+		Config:             config,
+		outcomes:           make(map[string]*SignalOutcome),
+	}
+	if err := t.load(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// location 行情时间使用的时区
+func (t *SignalTracker) location() *time.Location {
+	if t.TradingTimeChecker != nil && t.TradingTimeChecker.Location != nil {
+		return t.TradingTimeChecker.Location
+	}
+	return time.Local
+}
+
+// isTradingDay 是否交易日（没有交易时间检查器时只排除周末）
+func (t *SignalTracker) isTradingDay(day time.Time) bool {
+	if t.TradingTimeChecker != nil {
+		return t.TradingTimeChecker.IsTradingDay(day)
+	}
+	weekday := day.Weekday()
+	return weekday != time.Saturday && weekday != time.Sunday
+}
+
+// deadline 计算超时时间：信号发出后第TimeoutDays个交易日收盘（15:00）
+func (t *SignalTracker) deadline(signalTime time.Time) time.Time {
+	day := signalTime.In(t.location())
+	for count := 0; count < t.Config.TimeoutDays; {
+		day = day.AddDate(0, 0, 1)
+		if t.isTradingDay(day) {
+			count++
+		}
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), 15, 0, 0, 0, day.Location())
+}
+
+// Track 开始跟踪一条分析结果，只跟踪目标价和止损价有效的BUY信号
+func (t *SignalTracker) Track(result *AnalysisResult) (*SignalOutcome, bool) {
+	if result == nil || result.Signal != "BUY" || result.CurrentPrice <= 0 ||
+		result.TargetPrice <= result.CurrentPrice || result.StopLoss <= 0 || result.StopLoss >= result.CurrentPrice {
+		return nil, false
+	}
+
+	outcome := &SignalOutcome{
+		ID:           fmt.Sprintf("%s-%d", result.StockCode, result.Timestamp.Unix()),
+		StockCode:    result.StockCode,
+		StockName:    result.StockName,
+		Signal:       result.Signal,
+		Confidence:   result.Confidence,
+		EntryPrice:   result.CurrentPrice,
+		TargetPrice:  result.TargetPrice,
+		StopLoss:     result.StopLoss,
+		SignalTime:   result.Timestamp,
+		Deadline:     t.deadline(result.Timestamp),
+		Status:       OutcomeOpen,
+		LowestPrice:  result.CurrentPrice,
+		HighestPrice: result.CurrentPrice,
+		LastPrice:    result.CurrentPrice,
+		CheckedAt:    result.Timestamp,
+	}
+
+	t.mutex.Lock()
+	t.outcomes[outcome.ID] = outcome
+	t.mutex.Unlock()
+
+	log.Printf("🎯 开始跟踪%s(%s)信号 | 目标价: %.2f | 止损价: %.2f | 截止: %s",
+		outcome.StockName, outcome.StockCode, outcome.TargetPrice, outcome.StopLoss,
+		outcome.Deadline.Format("2006-01-02 15:04"))
+	t.saveLogged()
+	return t.copyOutcome(outcome), true
+}
+
+// Update 拉取行情更新所有跟踪中的信号
+func (t *SignalTracker) Update(ctx context.Context) error {
+	t.mutex.RLock()
+	open := []SignalOutcome{}
+	for _, outcome := range t.outcomes {
+		if !outcome.Closed() {
+			open = append(open, *outcome)
+		}
+	}
+	t.mutex.RUnlock()
+
+	if len(open) == 0 {
+		return nil
+	}
+
+	var errs []error
+	changed := false
+	for i := range open {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		outcome := &open[i]
+		if err := t.updateOutcome(ctx, outcome, time.Now()); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", outcome.StockCode, err))
+			continue
+		}
+
+		t.mutex.Lock()
+		if current, ok := t.outcomes[outcome.ID]; ok && !current.Closed() {
+			*current = *outcome
+			changed = true
+		}
+		t.mutex.Unlock()
+
+		if outcome.Closed() {
+			log.Printf("🏁 %s(%s)信号结果: %s | 出场价: %.2f | 收益: %+.2f%% | 最大不利偏移: %.2f%%",
+				outcome.StockName, outcome.StockCode, outcome.Status, outcome.ExitPrice,
+				outcome.ReturnPct, outcome.MaxAdverseExcursion)
+		}
+	}
+
+	if changed {
+		t.saveLogged()
+	}
+	return errors.Join(errs...)
+}
+
+// updateOutcome 用上次处理之后的1分钟K线更新单条跟踪记录
+func (t *SignalTracker) updateOutcome(ctx context.Context, outcome *SignalOutcome, now time.Time) error {
+	// 每个交易日240根1分钟K线，按自然日估算需要的数量（接口最多返回24000条）
+	days := int(now.Sub(outcome.CheckedAt).Hours()/24) + 1
+	limit := days * 240
+	if limit > 24000 {
+		limit = 24000
+	}
+
+	kline, err := t.TDXClient.GetKlineContext(ctx, outcome.StockCode, "minute1", limit)
+	if err != nil {
+		return fmt.Errorf("获取1分钟K线失败: %w", err)
+	}
+
+	bars := kline.List
+	sort.SliceStable(bars, func(i, j int) bool {
+		return bars[i].Time.Before(bars[j].Time)
+	})
+
+	// 返回的最早一根K线晚于上次处理位置之后应有的K线时，中间的行情已无法获取
+	if len(bars) > 0 && bars[0].Time.After(t.expectedBarAfter(outcome.CheckedAt)) {
+		log.Printf("⚠️  %s(%s)信号跟踪行情缺失: %s 至 %s 的1分钟K线无法获取，标记为无法判断",
+			outcome.StockName, outcome.StockCode,
+			outcome.CheckedAt.In(t.location()).Format("2006-01-02 15:04"),
+			bars[0].Time.In(t.location()).Format("2006-01-02 15:04"))
+		outcome.Status = OutcomeDataGap
+		outcome.updateStats(now)
+		return nil
+	}
+
+	for _, bar := range bars {
+		if !bar.Time.After(outcome.CheckedAt) {
+			continue
+		}
+		if bar.Time.After(outcome.Deadline) {
+			break
+		}
+		if outcome.applyBar(bar.Time, PriceToYuan(bar.Open), PriceToYuan(bar.High), PriceToYuan(bar.Low), PriceToYuan(bar.Close)) {
+			return nil
+		}
+	}
+
+	if !now.Before(outcome.Deadline) {
+		outcome.close(OutcomeTimeout, outcome.LastPrice, outcome.Deadline)
+	} else {
+		outcome.updateStats(now)
+	}
+	return nil
+}
+
+// expectedBarAfter 推算after之后应出现的第一根1分钟K线的时间（K线时间为该分钟结束时间）
+// 按交易日和交易时段推算，跳过午休、收盘后和非交易日
+func (t *SignalTracker) expectedBarAfter(after time.Time) time.Time {
+	hours := DefaultTradingTimeConfig().TradingHours
+	if t.TradingTimeChecker != nil && len(t.TradingTimeChecker.Config.TradingHours) > 0 {
+		hours = t.TradingTimeChecker.Config.TradingHours
+	}
+
+	after = after.In(t.location())
+	next := after.Truncate(time.Minute).Add(time.Minute)
+	day := time.Date(after.Year(), after.Month(), after.Day(), 0, 0, 0, 0, after.Location())
+	for i := 0; i < 30; i++ {
+		if t.isTradingDay(day) {
+			for _, period := range hours {
+				parts := strings.SplitN(period, "-", 2)
+				if len(parts) != 2 {
+					continue
+				}
+				start, err1 := time.ParseInLocation("2006-01-02 15:04", day.Format("2006-01-02")+" "+strings.TrimSpace(parts[0]), day.Location())
+				end, err2 := time.ParseInLocation("2006-01-02 15:04", day.Format("2006-01-02")+" "+strings.TrimSpace(parts[1]), day.Location())
+				if err1 != nil || err2 != nil {
+					continue
+				}
+				if first := start.Add(time.Minute); first.After(after) {
+					return first
+				}
+				if !next.After(end) {
+					return next
+				}
+			}
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// Outcomes 查询跟踪记录，stockCode和status为空时不过滤，按信号时间倒序
+func (t *SignalTracker) Outcomes(stockCode string, status OutcomeStatus) []*SignalOutcome {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	list := []*SignalOutcome{}
+	for _, outcome := range t.outcomes {
+		if stockCode != "" && outcome.StockCode != stockCode {
+			continue
+		}
+		if status != "" && outcome.Status != status {
+			continue
+		}
+		list = append(list, t.copyOutcome(outcome))
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].SignalTime.After(list[j].SignalTime)
+	})
+	return list
+}
+
+// Summary 按股票统计信号结果，stockCode为空时统计所有股票
+func (t *SignalTracker) Summary(stockCode string) []OutcomeSummary {
+	summaries := make(map[string]*OutcomeSummary)
+	for _, outcome := range t.Outcomes(stockCode, "") {
+		s, ok := summaries[outcome.StockCode]
+		if !ok {
+			s = &OutcomeSummary{StockCode: outcome.StockCode, StockName: outcome.StockName}
+			summaries[outcome.StockCode] = s
+		}
+		s.Total++
+		switch outcome.Status {
+		case OutcomeOpen:
+			s.Open++
+			continue
+		case OutcomeTarget:
+			s.Target++
+		case OutcomeStop:
+			s.Stop++
+		case OutcomeTimeout:
+			s.Timeout++
+		case OutcomeDataGap:
+			s.DataGap++
+			continue
+		}
+		s.AvgReturn += outcome.ReturnPct
+		s.AvgHoldingMinutes += outcome.HoldingMinutes
+		s.AvgMAE += outcome.MaxAdverseExcursion
+	}
+
+	list := make([]OutcomeSummary, 0, len(summaries))
+	for _, s := range summaries {
+		if closed := s.Target + s.Stop + s.Timeout; closed > 0 {
+			s.HitRate = float64(s.Target) / float64(closed) * 100
+			s.AvgReturn /= float64(closed)
+			s.AvgHoldingMinutes /= float64(closed)
+			s.AvgMAE /= float64(closed)
+		}
+		list = append(list, *s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].StockCode < list[j].StockCode
+	})
+	return list
+}
+
+// FormatSummary 生成统计通知文本
+func FormatSummary(summaries []OutcomeSummary) string {
+	var b strings.Builder
+	b.WriteString("📊 AI信号跟踪统计\n\n")
+	if len(summaries) == 0 {
+		b.WriteString("暂无跟踪的信号")
+		return b.String()
+	}
+	for _, s := range summaries {
+		line := fmt.Sprintf("%s(%s): 共%d条 | 目标%d 止损%d 超时%d 跟踪中%d",
+			s.StockName, s.StockCode, s.Total, s.Target, s.Stop, s.Timeout, s.Open)
+		if s.DataGap > 0 {
+			line += fmt.Sprintf(" 行情缺失%d", s.DataGap)
+		}
+		b.WriteString(line + "\n")
+		if closed := s.Target + s.Stop + s.Timeout; closed > 0 {
+			b.WriteString(fmt.Sprintf("  命中率: %.1f%% | 平均收益: %+.2f%% | 平均最大不利偏移: %.2f%% | 平均持有: %.0f分钟\n",
+				s.HitRate, s.AvgReturn, s.AvgMAE, s.AvgHoldingMinutes))
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// sendSummaryIfDue 交易日到达SummaryTime后发送一次统计通知
+func (t *SignalTracker) sendSummaryIfDue(ctx context.Context, now time.Time) {
+	if t.Notifier == nil || t.Config.SummaryTime == "" {
+		return
+	}
+	now = now.In(t.location())
+	today := now.Format("2006-01-02")
+	if t.lastSummaryDate == today || !t.isTradingDay(now) || now.Format("15:04") < t.Config.SummaryTime {
+		return
+	}
+	t.lastSummaryDate = today
+
+	if err := notifier.SendMessageContext(ctx, t.Notifier, FormatSummary(t.Summary(""))); err != nil {
+		log.Printf("❌ 发送信号跟踪统计失败: %v", err)
+		return
+	}
+	log.Printf("✅ 已发送信号跟踪统计")
+}
+
+// Start 定时更新跟踪记录并发送统计通知，stopChan关闭时退出
+func (t *SignalTracker) Start(stopChan <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stopChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	ticker := time.NewTicker(t.Config.CheckInterval)
+	defer ticker.Stop()
+
+	log.Printf("🎯 信号跟踪已启动，检查间隔: %v，超时: %d个交易日", t.Config.CheckInterval, t.Config.TimeoutDays)
+	for {
+		if err := t.Update(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("⚠️  更新信号跟踪失败: %v", err)
+		}
+		t.sendSummaryIfDue(ctx, time.Now())
+
+		select {
+		case <-ticker.C:
+		case <-stopChan:
+			return
+		}
+	}
+}
+
+// copyOutcome 复制跟踪记录，避免调用方修改内部状态
+func (t *SignalTracker) copyOutcome(outcome *SignalOutcome) *SignalOutcome {
+	cp := *outcome
+	return &cp
+}
+
+// load 从StoreFile加载跟踪记录
+func (t *SignalTracker) load() error {
+	var list []*SignalOutcome
+	if err := jsonfile.Load(t.Config.StoreFile, &list); err != nil {
+		return fmt.Errorf("加载信号跟踪记录失败: %w", err)
+	}
+	for _, outcome := range list {
+		t.outcomes[outcome.ID] = outcome
+	}
+	return nil
+}
+
+// saveLogged 将跟踪记录写入StoreFile，失败时只记录日志
+func (t *SignalTracker) saveLogged() {
+	err := t.file.Save(t.Config.StoreFile, func() ([]byte, error) {
+		return jsonfile.Marshal(t.Outcomes("", ""))
+	})
+	if err != nil {
+		log.Printf("⚠️  保存信号跟踪记录失败: %v", err)
+	}
+}
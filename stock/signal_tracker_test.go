@@ -0,0 +1,262 @@
+package stock
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// cst 测试使用的北京时间（固定时区，不依赖系统时区数据）
+var cst = time.FixedZone("CST", 8*3600)
+
+// newTestTracker 创建使用默认A股交易时段的跟踪器
+func newTestTracker(t *testing.T, tdxURL string) *SignalTracker {
+	t.Helper()
+	checker := &TradingTimeChecker{Config: DefaultTradingTimeConfig(), Location: cst}
+	tracker, err := NewSignalTracker(NewTDXClient(tdxURL), nil, checker, SignalTrackerConfig{TimeoutDays: 2})
+	if err != nil {
+		t.Fatalf("创建跟踪器失败: %v", err)
+	}
+	return tracker
+}
+
+// newTestOutcome 入场价10、目标价11、止损价9.5的跟踪记录
+func newTestOutcome(signalTime time.Time, deadline time.Time) *SignalOutcome {
+	return &SignalOutcome{
+		ID:           "600000-test",
+		StockCode:    "600000",
+		Signal:       "BUY",
+		EntryPrice:   10,
+		TargetPrice:  11,
+		StopLoss:     9.5,
+		SignalTime:   signalTime,
+		Deadline:     deadline,
+		Status:       OutcomeOpen,
+		LowestPrice:  10,
+		HighestPrice: 10,
+		LastPrice:    10,
+		CheckedAt:    signalTime,
+	}
+}
+
+// klineServer 返回指定1分钟K线的TDX接口stub（价格单位：元）
+func klineServer(t *testing.T, bars [][5]float64, times []time.Time) *httptest.Server {
+	t.Helper()
+	list := make([]KlineItem, len(bars))
+	for i, bar := range bars {
+		list[i] = KlineItem{
+			Open:  int(math.Round(bar[0] * 1000)),
+			High:  int(math.Round(bar[1] * 1000)),
+			Low:   int(math.Round(bar[2] * 1000)),
+			Close: int(math.Round(bar[3] * 1000)),
+			Time:  times[i],
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/kline" || r.URL.Query().Get("type") != "minute1" {
+			t.Errorf("意外的请求: %s", r.URL)
+		}
+		data, _ := json.Marshal(KlineData{Count: len(list), List: list})
+		json.NewEncoder(w).Encode(APIResponse{Code: 0, Data: data})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestApplyBar(t *testing.T) {
+	signalTime := time.Date(2024, 3, 7, 10, 0, 0, 0, cst)
+	barTime := signalTime.Add(time.Minute)
+	tests := []struct {
+		name      string
+		bar       [4]float64 // open, high, low, close
+		status    OutcomeStatus
+		exitPrice float64
+	}{
+		{"同一根K线同时触及时止损优先", [4]float64{10, 11.2, 9.4, 10}, OutcomeStop, 9.5},
+		{"跳空低开按开盘价止损", [4]float64{9.3, 9.4, 9.2, 9.3}, OutcomeStop, 9.3},
+		{"触及目标价", [4]float64{10.5, 11.1, 10.4, 11}, OutcomeTarget, 11},
+		{"跳空高开按开盘价止盈", [4]float64{11.3, 11.5, 11.2, 11.4}, OutcomeTarget, 11.3},
+		{"未触及", [4]float64{10, 10.8, 9.6, 10.2}, OutcomeOpen, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outcome := newTestOutcome(signalTime, signalTime.Add(48*time.Hour))
+			closed := outcome.applyBar(barTime, tt.bar[0], tt.bar[1], tt.bar[2], tt.bar[3])
+			if closed != (tt.status != OutcomeOpen) || outcome.Status != tt.status || outcome.ExitPrice != tt.exitPrice {
+				t.Errorf("applyBar = %v，状态%s，出场价%v；期望状态%s，出场价%v",
+					closed, outcome.Status, outcome.ExitPrice, tt.status, tt.exitPrice)
+			}
+			if !outcome.CheckedAt.Equal(barTime) {
+				t.Errorf("CheckedAt = %v，期望%v", outcome.CheckedAt, barTime)
+			}
+		})
+	}
+}
+
+func TestExcursions(t *testing.T) {
+	signalTime := time.Date(2024, 3, 7, 10, 0, 0, 0, cst)
+	outcome := newTestOutcome(signalTime, signalTime.Add(48*time.Hour))
+	outcome.applyBar(signalTime.Add(time.Minute), 10, 10.8, 9.9, 10.5)
+	outcome.applyBar(signalTime.Add(2*time.Minute), 10.5, 10.6, 9.6, 9.7)
+	outcome.applyBar(signalTime.Add(3*time.Minute), 9.7, 10.2, 9.7, 10.1)
+
+	if outcome.Closed() {
+		t.Fatalf("未触及目标价和止损价时不应结束: %s", outcome.Status)
+	}
+	if outcome.LowestPrice != 9.6 || outcome.HighestPrice != 10.8 {
+		t.Errorf("最低/最高价 = %v/%v，期望9.6/10.8", outcome.LowestPrice, outcome.HighestPrice)
+	}
+	if outcome.MaxAdverseExcursion != 4 || outcome.MaxFavorableExcursion != 8 {
+		t.Errorf("MAE/MFE = %v/%v，期望4/8", outcome.MaxAdverseExcursion, outcome.MaxFavorableExcursion)
+	}
+	if outcome.ReturnPct != 1 || outcome.HoldingMinutes != 3 {
+		t.Errorf("收益/持有 = %v/%v，期望1/3", outcome.ReturnPct, outcome.HoldingMinutes)
+	}
+}
+
+func TestDeadlineSkipsNonTradingDays(t *testing.T) {
+	tracker := newTestTracker(t, "")
+	tests := []struct {
+		name   string
+		signal time.Time
+		want   time.Time
+	}{
+		{"周四信号跨周末", time.Date(2024, 3, 7, 10, 0, 0, 0, cst), time.Date(2024, 3, 11, 15, 0, 0, 0, cst)},
+		{"周五信号跨周末", time.Date(2024, 3, 8, 14, 30, 0, 0, cst), time.Date(2024, 3, 12, 15, 0, 0, 0, cst)},
+		{"国庆假期", time.Date(2025, 9, 30, 10, 0, 0, 0, cst), time.Date(2025, 10, 9, 15, 0, 0, 0, cst)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tracker.deadline(tt.signal); !got.Equal(tt.want) {
+				t.Errorf("deadline = %v，期望%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpectedBarAfter(t *testing.T) {
+	tracker := newTestTracker(t, "")
+	tests := []struct {
+		name  string
+		after time.Time
+		want  time.Time
+	}{
+		{"盘中", time.Date(2024, 3, 7, 14, 0, 0, 0, cst), time.Date(2024, 3, 7, 14, 1, 0, 0, cst)},
+		{"盘中非整分钟", time.Date(2024, 3, 7, 14, 0, 30, 0, cst), time.Date(2024, 3, 7, 14, 1, 0, 0, cst)},
+		{"开盘前", time.Date(2024, 3, 7, 9, 0, 0, 0, cst), time.Date(2024, 3, 7, 9, 31, 0, 0, cst)},
+		{"午间休市", time.Date(2024, 3, 7, 11, 30, 0, 0, cst), time.Date(2024, 3, 7, 13, 1, 0, 0, cst)},
+		{"周五收盘后", time.Date(2024, 3, 8, 15, 0, 0, 0, cst), time.Date(2024, 3, 11, 9, 31, 0, 0, cst)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tracker.expectedBarAfter(tt.after); !got.Equal(tt.want) {
+				t.Errorf("expectedBarAfter = %v，期望%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateOutcome(t *testing.T) {
+	thursday := time.Date(2024, 3, 7, 14, 0, 0, 0, cst)
+	friday := time.Date(2024, 3, 8, 9, 31, 0, 0, cst)
+	tests := []struct {
+		name      string
+		checkedAt time.Time
+		now       time.Time
+		bars      [][5]float64
+		times     []time.Time
+		status    OutcomeStatus
+		lastPrice float64
+	}{
+		{
+			name:      "处理上次之后的K线并触及止损",
+			checkedAt: thursday,
+			now:       friday.Add(time.Hour),
+			bars:      [][5]float64{{10, 10, 9, 9}, {10, 10.2, 9.9, 10.1}, {10, 10, 9.4, 9.5}},
+			times:     []time.Time{thursday.Add(-time.Minute), thursday.Add(time.Minute), friday},
+			status:    OutcomeStop,
+			lastPrice: 9.5,
+		},
+		{
+			name:      "收盘后到次日开盘不算缺失",
+			checkedAt: time.Date(2024, 3, 7, 15, 0, 0, 0, cst),
+			now:       friday.Add(time.Hour),
+			bars:      [][5]float64{{10, 10.3, 9.9, 10.2}},
+			times:     []time.Time{friday},
+			status:    OutcomeOpen,
+			lastPrice: 10.2,
+		},
+		{
+			name:      "最早的K线晚于上次处理位置时标记行情缺失",
+			checkedAt: thursday,
+			now:       friday.Add(time.Hour),
+			bars:      [][5]float64{{10, 10.3, 9.9, 10.2}},
+			times:     []time.Time{friday},
+			status:    OutcomeDataGap,
+			lastPrice: 10,
+		},
+		{
+			name:      "超过截止时间未触及",
+			checkedAt: thursday,
+			now:       time.Date(2024, 3, 12, 10, 0, 0, 0, cst),
+			bars:      [][5]float64{{10, 10, 10, 10}, {10, 10.5, 9.8, 10.3}, {10.3, 10.9, 10.3, 10.9}},
+			times:     []time.Time{thursday, thursday.Add(time.Minute), time.Date(2024, 3, 11, 15, 1, 0, 0, cst)},
+			status:    OutcomeTimeout,
+			lastPrice: 10.3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := klineServer(t, tt.bars, tt.times)
+			tracker := newTestTracker(t, server.URL)
+			outcome := newTestOutcome(thursday.Add(-time.Hour), tracker.deadline(thursday.Add(-time.Hour)))
+			outcome.CheckedAt = tt.checkedAt
+
+			if err := tracker.updateOutcome(context.Background(), outcome, tt.now); err != nil {
+				t.Fatalf("updateOutcome失败: %v", err)
+			}
+			if outcome.Status != tt.status || outcome.LastPrice != tt.lastPrice {
+				t.Errorf("状态%s，最新价%v；期望状态%s，最新价%v", outcome.Status, outcome.LastPrice, tt.status, tt.lastPrice)
+			}
+		})
+	}
+}
+
+func TestSummary(t *testing.T) {
+	tracker := newTestTracker(t, "")
+	base := time.Date(2024, 3, 7, 10, 0, 0, 0, cst)
+	add := func(id string, status OutcomeStatus, ret, holding, mae float64) {
+		tracker.outcomes[id] = &SignalOutcome{
+			ID: id, StockCode: "600000", StockName: "浦发银行", Status: status,
+			SignalTime: base, ReturnPct: ret, HoldingMinutes: holding, MaxAdverseExcursion: mae,
+		}
+	}
+	add("a", OutcomeTarget, 10, 60, 1)
+	add("b", OutcomeStop, -5, 30, 5)
+	add("c", OutcomeTimeout, 1, 120, 3)
+	add("d", OutcomeTarget, 8, 90, 0)
+	add("e", OutcomeOpen, 50, 999, 50)
+	add("f", OutcomeDataGap, -50, 999, 50)
+	tracker.outcomes["g"] = &SignalOutcome{ID: "g", StockCode: "000001", Status: OutcomeStop, ReturnPct: -2}
+
+	summaries := tracker.Summary("600000")
+	if len(summaries) != 1 {
+		t.Fatalf("按股票过滤后应只有1条统计，实际%d条", len(summaries))
+	}
+	s := summaries[0]
+	if s.Total != 6 || s.Target != 2 || s.Stop != 1 || s.Timeout != 1 || s.Open != 1 || s.DataGap != 1 {
+		t.Errorf("计数错误: %+v", s)
+	}
+	// 跟踪中和行情缺失的记录不计入命中率和平均值：4条已有结果中2条命中
+	if s.HitRate != 50 || s.AvgReturn != 3.5 || s.AvgHoldingMinutes != 75 || s.AvgMAE != 2.25 {
+		t.Errorf("统计错误: 命中率%v 平均收益%v 平均持有%v 平均MAE%v", s.HitRate, s.AvgReturn, s.AvgHoldingMinutes, s.AvgMAE)
+	}
+
+	if all := tracker.Summary(""); len(all) != 2 || all[0].StockCode != "000001" || all[0].HitRate != 0 {
+		t.Errorf("全部股票统计错误: %+v", all)
+	}
+}
@@ -1,11 +1,14 @@
 package stock
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"nofx/stock/indicators"
 	"strings"
 	"time"
 )
@@ -106,31 +109,64 @@ type SearchResult struct {
 	Name string `json:"name"`
 }
 
+// 逐笔成交方向
+const (
+	TradeStatusBuy     = 0 // 主动买入
+	TradeStatusSell    = 1 // 主动卖出
+	TradeStatusNeutral = 2 // 中性
+)
+
+// TradeData 分时成交数据
+type TradeData struct {
+	Count int         `json:"Count"`
+	List  []TradeItem `json:"List"`
+}
+
+// TradeItem 逐笔成交单条数据
+type TradeItem struct {
+	Time   time.Time `json:"Time"`
+	Price  int       `json:"Price"`  // 成交价（厘）
+	Volume int       `json:"Volume"` // 成交量（手）
+	Status int       `json:"Status"` // 0=主动买入, 1=主动卖出, 2=中性
+	Number int       `json:"Number"` // 成交单数
+}
+
+// StockInfo 股票综合信息（五档行情+日K线+分时）
+type StockInfo struct {
+	Quote    *QuoteData  `json:"quote"`
+	KlineDay *KlineData  `json:"kline_day"`
+	Minute   *MinuteData `json:"minute"`
+}
+
+// CodeItem 股票列表单条数据
+type CodeItem struct {
+	Code     string `json:"code"`
+	Name     string `json:"name"`
+	Exchange string `json:"exchange"` // sh/sz/bj
+}
+
+// CodesData 股票列表数据
+type CodesData struct {
+	Total     int            `json:"total"`
+	Exchanges map[string]int `json:"exchanges"` // 各交易所股票数量
+	Codes     []CodeItem     `json:"codes"`
+}
+
 // GetQuote 获取五档行情
 func (c *TDXClient) GetQuote(code string) (*QuoteData, error) {
-	url := fmt.Sprintf("%s/api/quote?code=%s", c.BaseURL, code)
-	resp, err := c.HTTPClient.Get(url)
-	if err != nil {
-		return nil, fmt.Errorf("请求失败: %w", err)
-	}
-	defer resp.Body.Close()
+	return c.GetQuoteContext(context.Background(), code)
+}
 
-	body, err := io.ReadAll(resp.Body)
+// GetQuoteContext 获取五档行情（支持取消）
+func (c *TDXClient) GetQuoteContext(ctx context.Context, code string) (*QuoteData, error) {
+	urlStr := fmt.Sprintf("%s/api/quote?code=%s", c.BaseURL, code)
+	data, err := c.doRequest(ctx, http.MethodGet, urlStr, nil)
 	if err != nil {
-		return nil, fmt.Errorf("读取响应失败: %w", err)
-	}
-
-	var apiResp APIResponse
-	if err := json.Unmarshal(body, &apiResp); err != nil {
-		return nil, fmt.Errorf("解析响应失败: %w", err)
-	}
-
-	if apiResp.Code != 0 {
-		return nil, fmt.Errorf("API错误: %s", apiResp.Message)
+		return nil, err
 	}
 
 	var quotes []QuoteData
-	if err := json.Unmarshal(apiResp.Data, &quotes); err != nil {
+	if err := json.Unmarshal(data, &quotes); err != nil {
 		return nil, fmt.Errorf("解析行情数据失败: %w", err)
 	}
 
@@ -145,29 +181,19 @@ func (c *TDXClient) GetQuote(code string) (*QuoteData, error) {
 // adjust参数: 0=不复权(默认), 1=前复权, 2=后复权
 // 为了与实时行情价格一致，默认使用不复权数据(adjust=0)
 func (c *TDXClient) GetKline(code string, klineType string, limit int) (*KlineData, error) {
-	url := fmt.Sprintf("%s/api/kline?code=%s&type=%s&adjust=0", c.BaseURL, code, klineType)
-	resp, err := c.HTTPClient.Get(url)
-	if err != nil {
-		return nil, fmt.Errorf("请求失败: %w", err)
-	}
-	defer resp.Body.Close()
+	return c.GetKlineContext(context.Background(), code, klineType, limit)
+}
 
-	body, err := io.ReadAll(resp.Body)
+// GetKlineContext 获取K线数据（支持取消）
+func (c *TDXClient) GetKlineContext(ctx context.Context, code string, klineType string, limit int) (*KlineData, error) {
+	urlStr := fmt.Sprintf("%s/api/kline?code=%s&type=%s&adjust=0", c.BaseURL, code, klineType)
+	data, err := c.doRequest(ctx, http.MethodGet, urlStr, nil)
 	if err != nil {
-		return nil, fmt.Errorf("读取响应失败: %w", err)
-	}
-
-	var apiResp APIResponse
-	if err := json.Unmarshal(body, &apiResp); err != nil {
-		return nil, fmt.Errorf("解析响应失败: %w", err)
-	}
-
-	if apiResp.Code != 0 {
-		return nil, fmt.Errorf("API错误: %s", apiResp.Message)
+		return nil, err
 	}
 
 	var klineData KlineData
-	if err := json.Unmarshal(apiResp.Data, &klineData); err != nil {
+	if err := json.Unmarshal(data, &klineData); err != nil {
 		return nil, fmt.Errorf("解析K线数据失败: %w", err)
 	}
 
@@ -182,77 +208,280 @@ func (c *TDXClient) GetKline(code string, klineType string, limit int) (*KlineDa
 
 // GetMinute 获取分时数据
 func (c *TDXClient) GetMinute(code string, date string) (*MinuteData, error) {
+	return c.GetMinuteContext(context.Background(), code, date)
+}
+
+// GetMinuteContext 获取分时数据（支持取消）
+func (c *TDXClient) GetMinuteContext(ctx context.Context, code string, date string) (*MinuteData, error) {
 	urlStr := fmt.Sprintf("%s/api/minute?code=%s", c.BaseURL, code)
 	if date != "" {
 		urlStr += "&date=" + date
 	}
 
-	resp, err := c.HTTPClient.Get(urlStr)
+	data, err := c.doRequest(ctx, http.MethodGet, urlStr, nil)
 	if err != nil {
-		return nil, fmt.Errorf("请求失败: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	var minuteData MinuteData
+	if err := json.Unmarshal(data, &minuteData); err != nil {
+		return nil, fmt.Errorf("解析分时数据失败: %w", err)
+	}
+
+	return &minuteData, nil
+}
+
+// SearchStock 搜索股票
+func (c *TDXClient) SearchStock(keyword string) ([]SearchResult, error) {
+	return c.SearchStockContext(context.Background(), keyword)
+}
+
+// SearchStockContext 搜索股票（支持取消）
+func (c *TDXClient) SearchStockContext(ctx context.Context, keyword string) ([]SearchResult, error) {
+	urlStr := fmt.Sprintf("%s/api/search?keyword=%s", c.BaseURL, url.QueryEscape(keyword))
+	data, err := c.doRequest(ctx, http.MethodGet, urlStr, nil)
 	if err != nil {
-		return nil, fmt.Errorf("读取响应失败: %w", err)
+		return nil, err
 	}
 
-	var apiResp APIResponse
-	if err := json.Unmarshal(body, &apiResp); err != nil {
-		return nil, fmt.Errorf("解析响应失败: %w", err)
+	var results []SearchResult
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("解析搜索结果失败: %w", err)
 	}
 
-	if apiResp.Code != 0 {
-		return nil, fmt.Errorf("API错误: %s", apiResp.Message)
+	return results, nil
+}
+
+// BatchGetQuote 批量获取行情
+func (c *TDXClient) BatchGetQuote(codes []string) ([]QuoteData, error) {
+	return c.BatchGetQuoteContext(context.Background(), codes)
+}
+
+// BatchGetQuoteContext 批量获取行情（支持取消）
+func (c *TDXClient) BatchGetQuoteContext(ctx context.Context, codes []string) ([]QuoteData, error) {
+	// 使用逗号分隔的方式批量获取
+	codeStr := strings.Join(codes, ",")
+	urlStr := fmt.Sprintf("%s/api/quote?code=%s", c.BaseURL, codeStr)
+
+	data, err := c.doRequest(ctx, http.MethodGet, urlStr, nil)
+	if err != nil {
+		return nil, err
 	}
 
-	var minuteData MinuteData
-	if err := json.Unmarshal(apiResp.Data, &minuteData); err != nil {
-		return nil, fmt.Errorf("解析分时数据失败: %w", err)
+	var quotes []QuoteData
+	if err := json.Unmarshal(data, &quotes); err != nil {
+		return nil, fmt.Errorf("解析行情数据失败: %w", err)
 	}
 
-	return &minuteData, nil
+	return quotes, nil
 }
 
-// SearchStock 搜索股票
-func (c *TDXClient) SearchStock(keyword string) ([]SearchResult, error) {
-	urlStr := fmt.Sprintf("%s/api/search?keyword=%s", c.BaseURL, url.QueryEscape(keyword))
-	resp, err := c.HTTPClient.Get(urlStr)
+// GetTrade 获取分时成交（逐笔成交明细）
+// date为空时获取当日数据，否则为YYYYMMDD格式的历史日期
+func (c *TDXClient) GetTrade(code string, date string) (*TradeData, error) {
+	return c.GetTradeContext(context.Background(), code, date)
+}
+
+// GetTradeContext 获取分时成交（支持取消）
+func (c *TDXClient) GetTradeContext(ctx context.Context, code string, date string) (*TradeData, error) {
+	urlStr := fmt.Sprintf("%s/api/trade?code=%s", c.BaseURL, code)
+	if date != "" {
+		urlStr += "&date=" + date
+	}
+
+	data, err := c.doRequest(ctx, http.MethodGet, urlStr, nil)
 	if err != nil {
-		return nil, fmt.Errorf("请求失败: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	var tradeData TradeData
+	if err := json.Unmarshal(data, &tradeData); err != nil {
+		return nil, fmt.Errorf("解析成交数据失败: %w", err)
+	}
+
+	return &tradeData, nil
+}
+
+// GetStockInfo 获取股票综合信息（五档行情+最近30天日K线+今日分时）
+func (c *TDXClient) GetStockInfo(code string) (*StockInfo, error) {
+	return c.GetStockInfoContext(context.Background(), code)
+}
+
+// GetStockInfoContext 获取股票综合信息（支持取消）
+func (c *TDXClient) GetStockInfoContext(ctx context.Context, code string) (*StockInfo, error) {
+	urlStr := fmt.Sprintf("%s/api/stock-info?code=%s", c.BaseURL, code)
+	data, err := c.doRequest(ctx, http.MethodGet, urlStr, nil)
 	if err != nil {
-		return nil, fmt.Errorf("读取响应失败: %w", err)
+		return nil, err
 	}
 
-	var apiResp APIResponse
-	if err := json.Unmarshal(body, &apiResp); err != nil {
-		return nil, fmt.Errorf("解析响应失败: %w", err)
+	var raw struct {
+		Quote    json.RawMessage `json:"quote"`
+		KlineDay *KlineData      `json:"kline_day"`
+		Minute   *MinuteData     `json:"minute"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("解析综合信息失败: %w", err)
 	}
 
-	if apiResp.Code != 0 {
-		return nil, fmt.Errorf("API错误: %s", apiResp.Message)
+	info := &StockInfo{
+		KlineDay: raw.KlineDay,
+		Minute:   raw.Minute,
 	}
 
-	var results []SearchResult
-	if err := json.Unmarshal(apiResp.Data, &results); err != nil {
-		return nil, fmt.Errorf("解析搜索结果失败: %w", err)
+	// quote字段与/api/quote一致，可能是数组也可能是单个对象
+	quote := bytes.TrimSpace(raw.Quote)
+	if len(quote) > 0 && !bytes.Equal(quote, []byte("null")) {
+		if quote[0] == '[' {
+			var quotes []QuoteData
+			if err := json.Unmarshal(quote, &quotes); err != nil {
+				return nil, fmt.Errorf("解析行情数据失败: %w", err)
+			}
+			if len(quotes) > 0 {
+				info.Quote = &quotes[0]
+			}
+		} else {
+			var q QuoteData
+			if err := json.Unmarshal(quote, &q); err != nil {
+				return nil, fmt.Errorf("解析行情数据失败: %w", err)
+			}
+			info.Quote = &q
+		}
 	}
 
-	return results, nil
+	return info, nil
 }
 
-// BatchGetQuote 批量获取行情
-func (c *TDXClient) BatchGetQuote(codes []string) ([]QuoteData, error) {
-	// 使用逗号分隔的方式批量获取
-	codeStr := strings.Join(codes, ",")
-	url := fmt.Sprintf("%s/api/quote?code=%s", c.BaseURL, codeStr)
+// GetCodes 获取股票代码列表
+// exchange参数: sh/sz/bj/all，为空时默认all
+func (c *TDXClient) GetCodes(exchange string) (*CodesData, error) {
+	return c.GetCodesContext(context.Background(), exchange)
+}
+
+// GetCodesContext 获取股票代码列表（支持取消）
+func (c *TDXClient) GetCodesContext(ctx context.Context, exchange string) (*CodesData, error) {
+	urlStr := fmt.Sprintf("%s/api/codes", c.BaseURL)
+	if exchange != "" {
+		urlStr += "?exchange=" + url.QueryEscape(exchange)
+	}
 
-	resp, err := c.HTTPClient.Get(url)
+	data, err := c.doRequest(ctx, http.MethodGet, urlStr, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var codesData CodesData
+	if err := json.Unmarshal(data, &codesData); err != nil {
+		return nil, fmt.Errorf("解析股票列表失败: %w", err)
+	}
+
+	return &codesData, nil
+}
+
+// PostBatchQuote 通过POST /api/batch-quote批量获取行情
+// 与BatchGetQuote相比不受URL长度限制，适合一次查询大量股票
+func (c *TDXClient) PostBatchQuote(codes []string) ([]QuoteData, error) {
+	return c.PostBatchQuoteContext(context.Background(), codes)
+}
+
+// PostBatchQuoteContext 通过POST批量获取行情（支持取消）
+func (c *TDXClient) PostBatchQuoteContext(ctx context.Context, codes []string) ([]QuoteData, error) {
+	reqBody, err := json.Marshal(map[string][]string{"codes": codes})
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求失败: %w", err)
+	}
+
+	urlStr := fmt.Sprintf("%s/api/batch-quote", c.BaseURL)
+	data, err := c.doRequest(ctx, http.MethodPost, urlStr, reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	var quotes []QuoteData
+	if err := json.Unmarshal(data, &quotes); err != nil {
+		return nil, fmt.Errorf("解析行情数据失败: %w", err)
+	}
+
+	return quotes, nil
+}
+
+// GetKlineHistory 获取指定时间范围的历史K线
+// startDate/endDate为YYYYMMDD格式，可为空；limit<=0时使用服务端默认值（100，最大800）
+func (c *TDXClient) GetKlineHistory(code string, klineType string, startDate string, endDate string, limit int) (*KlineData, error) {
+	return c.GetKlineHistoryContext(context.Background(), code, klineType, startDate, endDate, limit)
+}
+
+// GetKlineHistoryContext 获取指定时间范围的历史K线（支持取消）
+func (c *TDXClient) GetKlineHistoryContext(ctx context.Context, code string, klineType string, startDate string, endDate string, limit int) (*KlineData, error) {
+	params := url.Values{}
+	params.Set("code", code)
+	params.Set("type", klineType)
+	if startDate != "" {
+		params.Set("start_date", startDate)
+	}
+	if endDate != "" {
+		params.Set("end_date", endDate)
+	}
+	if limit > 0 {
+		params.Set("limit", fmt.Sprintf("%d", limit))
+	}
+
+	urlStr := fmt.Sprintf("%s/api/kline-history?%s", c.BaseURL, params.Encode())
+	data, err := c.doRequest(ctx, http.MethodGet, urlStr, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var klineData KlineData
+	if err := json.Unmarshal(data, &klineData); err != nil {
+		return nil, fmt.Errorf("解析K线数据失败: %w", err)
+	}
+
+	return &klineData, nil
+}
+
+// GetIndex 获取指数K线数据
+// code为带交易所前缀的指数代码（如sh000001上证指数、sz399006创业板指），klineType为空时默认day
+func (c *TDXClient) GetIndex(code string, klineType string) (*KlineData, error) {
+	return c.GetIndexContext(context.Background(), code, klineType)
+}
+
+// GetIndexContext 获取指数K线数据（支持取消）
+func (c *TDXClient) GetIndexContext(ctx context.Context, code string, klineType string) (*KlineData, error) {
+	urlStr := fmt.Sprintf("%s/api/index?code=%s", c.BaseURL, code)
+	if klineType != "" {
+		urlStr += "&type=" + klineType
+	}
+
+	data, err := c.doRequest(ctx, http.MethodGet, urlStr, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var klineData KlineData
+	if err := json.Unmarshal(data, &klineData); err != nil {
+		return nil, fmt.Errorf("解析指数数据失败: %w", err)
+	}
+
+	return &klineData, nil
+}
+
+// doRequest 发送请求并解析统一响应格式，返回data字段
+func (c *TDXClient) doRequest(ctx context.Context, method string, urlStr string, reqBody []byte) (json.RawMessage, error) {
+	var bodyReader io.Reader
+	if reqBody != nil {
+		bodyReader = bytes.NewReader(reqBody)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, urlStr, bodyReader)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	if reqBody != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("请求失败: %w", err)
 	}
@@ -272,12 +501,7 @@ func (c *TDXClient) BatchGetQuote(codes []string) ([]QuoteData, error) {
 		return nil, fmt.Errorf("API错误: %s", apiResp.Message)
 	}
 
-	var quotes []QuoteData
-	if err := json.Unmarshal(apiResp.Data, &quotes); err != nil {
-		return nil, fmt.Errorf("解析行情数据失败: %w", err)
-	}
-
-	return quotes, nil
+	return apiResp.Data, nil
 }
 
 // PriceToYuan 将厘转换为元
@@ -290,6 +514,21 @@ func VolumeToShares(hands int64) int64 {
 	return hands * 100
 }
 
+// KlineToBars 将K线转换为指标计算使用的浮点K线（价格单位：元）
+func KlineToBars(klines []KlineItem) []indicators.Bar {
+	bars := make([]indicators.Bar, len(klines))
+	for i, k := range klines {
+		bars[i] = indicators.Bar{
+			Open:   PriceToYuan(k.Open),
+			High:   PriceToYuan(k.High),
+			Low:    PriceToYuan(k.Low),
+			Close:  PriceToYuan(k.Close),
+			Volume: float64(k.Volume),
+		}
+	}
+	return bars
+}
+
 // AmountToYuan 将成交额（厘）转换为元
 func AmountToYuan(amount float64) float64 {
 	return amount / 1000.0
@@ -0,0 +1,331 @@
+package stock
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"nofx/jsonfile"
+	"nofx/mcp"
+	"nofx/notifier"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrBudgetExceeded 当日AI费用已超出预算
+var ErrBudgetExceeded = errors.New("今日AI费用已超出预算")
+
+// ModelPrice 模型价格（每百万token）
+type ModelPrice struct {
+	Input  float64 `json:"input"`  // 输入（prompt）token价格
+	Output float64 `json:"output"` // 输出（completion）token价格
+}
+
+// UsageTrackerConfig AI用量统计配置
+type UsageTrackerConfig struct {
+	Prices      map[string]ModelPrice // 模型名称或提供商名称 → 价格（先按模型查找，再按提供商查找）
+	DailyBudget float64               // 每日费用预算，超出后暂停AI分析直到次日，0表示不限制
+	StoreFile   string                // 用量记录保存文件，为空时只保存在内存中
+	KeepDays    int                   // 保留最近多少天的记录，默认90
+}
+
+// UsageStats token用量和费用
+type UsageStats struct {
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// add 累加用量
+func (s *UsageStats) add(other UsageStats) {
+	s.Calls += other.Calls
+	s.PromptTokens += other.PromptTokens
+	s.CompletionTokens += other.CompletionTokens
+	s.TotalTokens += other.TotalTokens
+	s.Cost = roundCost(s.Cost + other.Cost)
+}
+
+// UsageRecord 按日期、股票、提供商和模型聚合的用量
+type UsageRecord struct {
+	Date      string `json:"date"` // YYYY-MM-DD
+	StockCode string `json:"stock_code"`
+	Provider  string `json:"provider"`
+	Model     string `json:"model"`
+	UsageStats
+}
+
+// UsageGroup 按某一维度汇总的用量
+type UsageGroup struct {
+	Key string `json:"key"`
+	UsageStats
+}
+
+// UsageSummary 用量统计
+type UsageSummary struct {
+	Date           string       `json:"date"`
+	Today          UsageStats   `json:"today"`
+	DailyBudget    float64      `json:"daily_budget"`
+	BudgetExceeded bool         `json:"budget_exceeded"`
+	Days           int          `json:"days"`  // 统计的天数（含今天）
+	Total          UsageStats   `json:"total"` // 统计区间内的合计
+	ByDay          []UsageGroup `json:"by_day"`
+	ByStock        []UsageGroup `json:"by_stock"`
+	ByProvider     []UsageGroup `json:"by_provider"`
+	UnpricedModels []string     `json:"unpriced_models,omitempty"` // 没有配置价格的模型，费用按0计算
+}
+
+// usageState 持久化的用量记录
+type usageState struct {
+	Records        []*UsageRecord `json:"records"`
+	BudgetNotified string         `json:"budget_notified,omitempty"` // 最近一次发送超预算通知的日期
+}
+
+// UsageTracker AI token用量和费用统计
+// 按日期/股票/提供商/模型累计每次调用的token用量，按价格表计算费用；当日费用超出预算时暂停AI分析并发送一次通知
+type UsageTracker struct {
+	Notifier notifier.Notifier
+	Config   UsageTrackerConfig
+	Location *time.Location // 按该时区划分日期，为nil时使用本地时区
+
+	mutex    sync.Mutex
+	file     jsonfile.File
+	state    usageState
+	unpriced map[string]bool
+}
+
+// NewUsageTracker 创建用量统计，并从StoreFile恢复记录
+func NewUsageTracker(notif notifier.Notifier, config UsageTrackerConfig) (*UsageTracker, error) {
+	if config.KeepDays <= 0 {
+		config.KeepDays = 90
+	}
+	t := &UsageTracker{
+		Notifier: notif,
+		Config:   config,
+		unpriced: make(map[string]bool),
+	}
+	if err := t.load(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Record 记录一次AI调用的用量，返回本次费用
+func (t *UsageTracker) Record(stockCode, provider, model string, usage mcp.Usage) float64 {
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	cost := t.cost(provider, model, usage)
+	date := t.today()
+
+	t.mutex.Lock()
+	var record *UsageRecord
+	for _, r := range t.state.Records {
+		if r.Date == date && r.StockCode == stockCode && r.Provider == provider && r.Model == model {
+			record = r
+			break
+		}
+	}
+	if record == nil {
+		record = &UsageRecord{Date: date, StockCode: stockCode, Provider: provider, Model: model}
+		t.state.Records = append(t.state.Records, record)
+	}
+	record.add(UsageStats{
+		Calls:            1,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+		Cost:             cost,
+	})
+	t.prune(date)
+
+	todayCost := t.costOn(date)
+	notify := t.Config.DailyBudget > 0 && todayCost >= t.Config.DailyBudget && t.state.BudgetNotified != date
+	if notify {
+		t.state.BudgetNotified = date
+	}
+	t.mutex.Unlock()
+
+	t.saveLogged()
+	if notify {
+		t.notifyBudgetExceeded(todayCost)
+	}
+	return cost
+}
+
+// BudgetExceeded 当日费用是否已达到预算
+func (t *UsageTracker) BudgetExceeded() bool {
+	if t.Config.DailyBudget <= 0 {
+		return false
+	}
+	date := t.today()
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.costOn(date) >= t.Config.DailyBudget
+}
+
+// Summary 统计最近days天（含今天）的用量，days<=0时为7天
+func (t *UsageTracker) Summary(days int) *UsageSummary {
+	if days <= 0 {
+		days = 7
+	}
+	now := time.Now().In(t.location())
+	date := now.Format("2006-01-02")
+	since := now.AddDate(0, 0, -(days - 1)).Format("2006-01-02")
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	summary := &UsageSummary{
+		Date:        date,
+		DailyBudget: t.Config.DailyBudget,
+		Days:        days,
+	}
+	byDay := make(map[string]*UsageStats)
+	byStock := make(map[string]*UsageStats)
+	byProvider := make(map[string]*UsageStats)
+	group := func(groups map[string]*UsageStats, key string, stats UsageStats) {
+		if groups[key] == nil {
+			groups[key] = &UsageStats{}
+		}
+		groups[key].add(stats)
+	}
+	for _, r := range t.state.Records {
+		if r.Date == date {
+			summary.Today.add(r.UsageStats)
+		}
+		if r.Date < since {
+			continue
+		}
+		summary.Total.add(r.UsageStats)
+		group(byDay, r.Date, r.UsageStats)
+		group(byStock, r.StockCode, r.UsageStats)
+		group(byProvider, r.Provider, r.UsageStats)
+	}
+	summary.BudgetExceeded = t.Config.DailyBudget > 0 && summary.Today.Cost >= t.Config.DailyBudget
+
+	summary.ByDay = sortedUsageGroups(byDay)
+	sort.Slice(summary.ByDay, func(i, j int) bool { return summary.ByDay[i].Key > summary.ByDay[j].Key })
+	summary.ByStock = sortedUsageGroups(byStock)
+	summary.ByProvider = sortedUsageGroups(byProvider)
+	for model := range t.unpriced {
+		summary.UnpricedModels = append(summary.UnpricedModels, model)
+	}
+	sort.Strings(summary.UnpricedModels)
+	return summary
+}
+
+// sortedUsageGroups 按费用（相同时按token数）从高到低排列
+func sortedUsageGroups(groups map[string]*UsageStats) []UsageGroup {
+	result := make([]UsageGroup, 0, len(groups))
+	for key, stats := range groups {
+		result = append(result, UsageGroup{Key: key, UsageStats: *stats})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Cost != result[j].Cost {
+			return result[i].Cost > result[j].Cost
+		}
+		if result[i].TotalTokens != result[j].TotalTokens {
+			return result[i].TotalTokens > result[j].TotalTokens
+		}
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
+
+// cost 按价格表计算费用，没有配置价格时为0
+func (t *UsageTracker) cost(provider, model string, usage mcp.Usage) float64 {
+	price, ok := t.Config.Prices[model]
+	if !ok {
+		price, ok = t.Config.Prices[provider]
+	}
+	if !ok {
+		t.mutex.Lock()
+		if !t.unpriced[model] {
+			t.unpriced[model] = true
+			log.Printf("⚠️  模型 %s(%s) 未配置价格，费用按0计算", model, provider)
+		}
+		t.mutex.Unlock()
+		return 0
+	}
+	return roundCost((float64(usage.PromptTokens)*price.Input + float64(usage.CompletionTokens)*price.Output) / 1e6)
+}
+
+// costOn 指定日期的总费用（调用方需持有mutex）
+func (t *UsageTracker) costOn(date string) float64 {
+	total := 0.0
+	for _, r := range t.state.Records {
+		if r.Date == date {
+			total += r.Cost
+		}
+	}
+	return roundCost(total)
+}
+
+// prune 删除超过KeepDays的记录（调用方需持有mutex）
+func (t *UsageTracker) prune(date string) {
+	today, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return
+	}
+	cutoff := today.AddDate(0, 0, -(t.Config.KeepDays - 1)).Format("2006-01-02")
+	kept := t.state.Records[:0]
+	for _, r := range t.state.Records {
+		if r.Date >= cutoff {
+			kept = append(kept, r)
+		}
+	}
+	t.state.Records = kept
+}
+
+// notifyBudgetExceeded 发送超预算通知
+func (t *UsageTracker) notifyBudgetExceeded(cost float64) {
+	message := fmt.Sprintf("⚠️ 今日AI费用 %.4f 已达到每日预算 %.4f，AI分析已暂停，明日自动恢复", cost, t.Config.DailyBudget)
+	log.Printf("%s", message)
+	if t.Notifier == nil {
+		return
+	}
+	if err := t.Notifier.SendMessage(message); err != nil {
+		log.Printf("❌ 发送AI费用预算通知失败: %v", err)
+	}
+}
+
+// today 当前日期（YYYY-MM-DD）
+func (t *UsageTracker) today() string {
+	return time.Now().In(t.location()).Format("2006-01-02")
+}
+
+// location 划分日期使用的时区
+func (t *UsageTracker) location() *time.Location {
+	if t.Location != nil {
+		return t.Location
+	}
+	return time.Local
+}
+
+// roundCost 费用保留6位小数
+func roundCost(v float64) float64 {
+	return math.Round(v*1e6) / 1e6
+}
+
+// load 从StoreFile加载用量记录
+func (t *UsageTracker) load() error {
+	if err := jsonfile.Load(t.Config.StoreFile, &t.state); err != nil {
+		return fmt.Errorf("加载AI用量记录失败: %w", err)
+	}
+	return nil
+}
+
+// saveLogged 将用量记录写入StoreFile，失败时只记录日志
+func (t *UsageTracker) saveLogged() {
+	err := t.file.Save(t.Config.StoreFile, func() ([]byte, error) {
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		return jsonfile.Marshal(t.state)
+	})
+	if err != nil {
+		log.Printf("⚠️  保存AI用量记录失败: %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package stock
+
+import (
+	"nofx/mcp"
+	"nofx/notifier"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testPrices 测试价格表：deepseek-chat按模型定价，anthropic按提供商定价
+var testPrices = map[string]ModelPrice{
+	"deepseek-chat": {Input: 1, Output: 2},
+	"anthropic":     {Input: 3, Output: 15},
+	"qwen":          {Input: 100, Output: 100}, // 模型价格优先于提供商价格
+	"qwen-plus":     {Input: 0.8, Output: 2},
+}
+
+// newTestUsageTracker 使用北京时间划分日期的用量统计
+func newTestUsageTracker(t *testing.T, n notifier.Notifier, config UsageTrackerConfig) *UsageTracker {
+	t.Helper()
+	if config.Prices == nil {
+		config.Prices = testPrices
+	}
+	tracker, err := NewUsageTracker(n, config)
+	if err != nil {
+		t.Fatalf("创建用量统计失败: %v", err)
+	}
+	tracker.Location = cst
+	return tracker
+}
+
+// daysAgo n天前的日期（北京时间）
+func daysAgo(n int) string {
+	return time.Now().In(cst).AddDate(0, 0, -n).Format("2006-01-02")
+}
+
+func TestUsageCost(t *testing.T) {
+	usage := mcp.Usage{PromptTokens: 2000, CompletionTokens: 500}
+	tests := []struct {
+		name     string
+		provider string
+		model    string
+		want     float64
+		unpriced bool
+	}{
+		{"按模型定价", "deepseek", "deepseek-chat", 0.003, false},
+		{"按提供商定价", "anthropic", "claude-3-5-sonnet-latest", 0.0135, false},
+		{"模型价格优先", "qwen", "qwen-plus", 0.0026, false},
+		{"未配置价格", "ollama", "qwen2.5:14b", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := newTestUsageTracker(t, nil, UsageTrackerConfig{})
+			if got := tracker.Record("600519", tt.provider, tt.model, usage); got != tt.want {
+				t.Errorf("费用 = %v，期望%v", got, tt.want)
+			}
+			unpriced := tracker.Summary(1).UnpricedModels
+			if (len(unpriced) == 1 && unpriced[0] == tt.model) != tt.unpriced {
+				t.Errorf("未定价模型 = %v", unpriced)
+			}
+		})
+	}
+}
+
+func TestUsageDailyAggregation(t *testing.T) {
+	tracker := newTestUsageTracker(t, nil, UsageTrackerConfig{})
+	tracker.state.Records = []*UsageRecord{
+		{Date: daysAgo(1), StockCode: "600519", Provider: "deepseek", Model: "deepseek-chat", UsageStats: UsageStats{Calls: 3, TotalTokens: 3000, Cost: 0.5}},
+		{Date: daysAgo(10), StockCode: "600519", Provider: "deepseek", Model: "deepseek-chat", UsageStats: UsageStats{Calls: 1, TotalTokens: 1000, Cost: 9}},
+	}
+
+	tracker.Record("600519", "deepseek", "deepseek-chat", mcp.Usage{PromptTokens: 2000, CompletionTokens: 500})
+	tracker.Record("600519", "deepseek", "deepseek-chat", mcp.Usage{PromptTokens: 2000, CompletionTokens: 500})
+	tracker.Record("000001", "anthropic", "claude-3-5-sonnet-latest", mcp.Usage{PromptTokens: 2000, CompletionTokens: 500, TotalTokens: 2600})
+
+	if len(tracker.state.Records) != 4 {
+		t.Fatalf("同一天同一股票、提供商、模型应合并为一条记录，实际%d条", len(tracker.state.Records))
+	}
+
+	summary := tracker.Summary(7)
+	today := summary.Today
+	if today.Calls != 3 || today.PromptTokens != 6000 || today.CompletionTokens != 1500 || today.TotalTokens != 7600 || today.Cost != 0.0195 {
+		t.Errorf("今日用量 = %+v", today)
+	}
+	// 10天前的记录不在最近7天内
+	if summary.Total.Calls != 6 || summary.Total.Cost != 0.5195 {
+		t.Errorf("7天合计 = %+v", summary.Total)
+	}
+	if len(summary.ByDay) != 2 || summary.ByDay[0].Key != daysAgo(0) || summary.ByDay[1].Key != daysAgo(1) {
+		t.Errorf("按日汇总应从近到远排列: %+v", summary.ByDay)
+	}
+	if len(summary.ByStock) != 2 || summary.ByStock[0].Key != "600519" || summary.ByStock[0].Cost != 0.506 {
+		t.Errorf("按股票汇总应按费用从高到低排列: %+v", summary.ByStock)
+	}
+	if len(summary.ByProvider) != 2 || summary.ByProvider[1].Key != "anthropic" || summary.ByProvider[1].Cost != 0.0135 {
+		t.Errorf("按提供商汇总 = %+v", summary.ByProvider)
+	}
+	if all := tracker.Summary(30); all.Total.Calls != 7 || len(all.ByDay) != 3 {
+		t.Errorf("30天合计 = %+v", all.Total)
+	}
+}
+
+func TestUsagePrune(t *testing.T) {
+	tracker := newTestUsageTracker(t, nil, UsageTrackerConfig{KeepDays: 3})
+	for n := 5; n >= 1; n-- {
+		tracker.state.Records = append(tracker.state.Records, &UsageRecord{Date: daysAgo(n), StockCode: "600519"})
+	}
+
+	tracker.Record("600519", "deepseek", "deepseek-chat", mcp.Usage{PromptTokens: 100})
+	var dates []string
+	for _, r := range tracker.state.Records {
+		dates = append(dates, r.Date)
+	}
+	if len(dates) != 3 || dates[0] != daysAgo(2) || dates[2] != daysAgo(0) {
+		t.Errorf("KeepDays=3时应保留今天和前2天的记录，实际: %v", dates)
+	}
+}
+
+func TestUsageBudget(t *testing.T) {
+	rec := &recordingNotifier{}
+	tracker := newTestUsageTracker(t, rec, UsageTrackerConfig{DailyBudget: 0.02})
+	// 昨天的费用不计入今天的预算
+	tracker.state.Records = []*UsageRecord{{Date: daysAgo(1), StockCode: "600519", UsageStats: UsageStats{Cost: 10}}}
+	tracker.state.BudgetNotified = daysAgo(1)
+	if tracker.BudgetExceeded() {
+		t.Fatalf("今天尚无费用，不应超出预算")
+	}
+
+	usage := mcp.Usage{PromptTokens: 2000, CompletionTokens: 500} // anthropic每次0.0135
+	tracker.Record("600519", "anthropic", "claude", usage)
+	if tracker.BudgetExceeded() || rec.sent() != 0 {
+		t.Fatalf("0.0135未达到预算0.02")
+	}
+	tracker.Record("600519", "anthropic", "claude", usage)
+	if !tracker.BudgetExceeded() || !tracker.Summary(1).BudgetExceeded {
+		t.Fatalf("0.027应超出预算0.02")
+	}
+	tracker.Record("600519", "anthropic", "claude", usage)
+	if rec.sent() != 1 {
+		t.Fatalf("超出预算当天只应通知一次，实际%d次", rec.sent())
+	}
+	if tracker.state.BudgetNotified != daysAgo(0) {
+		t.Errorf("BudgetNotified = %s，期望今天", tracker.state.BudgetNotified)
+	}
+
+	unlimited := newTestUsageTracker(t, rec, UsageTrackerConfig{})
+	unlimited.Record("600519", "anthropic", "claude", mcp.Usage{PromptTokens: 1e7})
+	if unlimited.BudgetExceeded() || rec.sent() != 1 {
+		t.Errorf("DailyBudget为0时不限制")
+	}
+}
+
+func TestUsageBudgetNotifiedSurvivesRestart(t *testing.T) {
+	storeFile := filepath.Join(t.TempDir(), "ai_usage.json")
+	rec := &recordingNotifier{}
+	config := UsageTrackerConfig{DailyBudget: 0.01, StoreFile: storeFile}
+	usage := mcp.Usage{PromptTokens: 2000, CompletionTokens: 500}
+
+	tracker := newTestUsageTracker(t, rec, config)
+	tracker.Record("600519", "anthropic", "claude", usage)
+	if rec.sent() != 1 {
+		t.Fatalf("超出预算时应发送通知")
+	}
+
+	restarted := newTestUsageTracker(t, rec, config)
+	if !restarted.BudgetExceeded() {
+		t.Errorf("重启后应恢复今日费用")
+	}
+	restarted.Record("600519", "anthropic", "claude", usage)
+	if rec.sent() != 1 {
+		t.Errorf("重启后当天不应重复通知，实际%d次", rec.sent())
+	}
+	if s := restarted.Summary(1); s.Today.Calls != 2 || s.Today.Cost != 0.027 {
+		t.Errorf("重启后今日用量 = %+v", s.Today)
+	}
+}